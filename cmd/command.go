@@ -4,22 +4,25 @@ import (
 	"fmt"
 	"os"
 	"runtime/debug"
+	"strings"
 
 	"github.com/mhersson/mpls/internal/mpls"
 	"github.com/mhersson/mpls/internal/previewserver"
+	"github.com/mhersson/mpls/pkg/linkcheck"
 	"github.com/mhersson/mpls/pkg/parser"
 	"github.com/mhersson/mpls/pkg/plantuml"
 	"github.com/spf13/cobra"
 )
 
 var (
-	noAuto     bool
-	enableTabs bool
-	listThemes bool
-	darkMode   bool
-	Version    = "dev"
-	CommitSHA  = "unknown"
-	BuildTime  = "unknown"
+	noAuto          bool
+	enableTabs      bool
+	listThemes      bool
+	darkMode        bool
+	plantumlHeaders []string
+	Version         = "dev"
+	CommitSHA       = "unknown"
+	BuildTime       = "unknown"
 )
 
 var command = &cobra.Command{
@@ -33,30 +36,50 @@ var command = &cobra.Command{
 			return
 		}
 
-		// Handle deprecated --dark-mode flag for backward compatibility
-		if darkMode && !cmd.Flags().Changed("theme") {
-			previewserver.Theme = "dark"
-		}
+		applyFlags(cmd)
 
-		// Auto-set code-style based on theme only if:
-		// 1. User explicitly set --theme (or --dark-mode)
-		// 2. User didn't explicitly set --code-style
-		// 3. There's a matching chroma style for the theme
-		if (cmd.Flags().Changed("theme") || darkMode) && !cmd.Flags().Changed("code-style") {
-			if chromaStyle := previewserver.GetChromaStyleForTheme(previewserver.Theme); chromaStyle != "" {
-				parser.CodeHighlightingStyle = chromaStyle
-			}
+		cmd.Printf("mpls %s - press Ctrl+D to quit.\n", cmd.Version)
+
+		mpls.Run()
+	},
+}
+
+// applyFlags sets the settings that depend on more than one flag.
+func applyFlags(cmd *cobra.Command) {
+	// Handle deprecated --dark-mode flag for backward compatibility
+	if darkMode && !cmd.Flags().Changed("theme") {
+		previewserver.Theme = "dark"
+	}
+
+	// Auto-set code-style based on theme only if:
+	// 1. User explicitly set --theme (or --dark-mode)
+	// 2. User didn't explicitly set --code-style
+	// 3. There's a matching chroma style for the theme
+	if (cmd.Flags().Changed("theme") || darkMode) && !cmd.Flags().Changed("code-style") {
+		if chromaStyle := previewserver.GetChromaStyleForTheme(previewserver.Theme); chromaStyle != "" {
+			parser.CodeHighlightingStyle = chromaStyle
 		}
+	}
 
-		// Set preview mode
-		previewserver.EnableTabs = enableTabs
+	// Set preview mode
+	previewserver.EnableTabs = enableTabs
 
-		cmd.Printf("mpls %s - press Ctrl+D to quit.\n", cmd.Version)
+	previewserver.OpenBrowserOnStartup = !noAuto
 
-		previewserver.OpenBrowserOnStartup = !noAuto
+	if len(plantumlHeaders) > 0 {
+		plantuml.Headers = make(map[string]string)
 
-		mpls.Run()
-	},
+		for _, header := range plantumlHeaders {
+			name, value, ok := strings.Cut(header, ":")
+			if !ok {
+				cmd.PrintErrf("Ignoring --plantuml-header %q, expected \"Name: value\"\n", header)
+
+				continue
+			}
+
+			plantuml.Headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
 }
 
 func getVersionInfo() string {
@@ -94,28 +117,42 @@ func Execute() {
 }
 
 func init() {
-	// Persistent flags available to all subcommands
+	// Persistent flags available to all subcommands, so that config print
+	// sees the same settings as the server
 	command.PersistentFlags().StringVar(&previewserver.Browser, "browser", "", "Specify the web browser to use for the preview")
 	command.PersistentFlags().StringVar(&previewserver.Theme, "theme", "light", "Set the preview theme (light, dark, or any of the provided themes)")
 	command.PersistentFlags().IntVar(&previewserver.FixedPort, "port", 0, "Set a fixed port for the preview server")
+	command.PersistentFlags().StringVar(&parser.CodeHighlightingStyle, "code-style", "catppuccin-mocha", "Higlighting style for code blocks")
+	command.PersistentFlags().BoolVar(&darkMode, "dark-mode", false, "Enable dark mode (deprecated: use --theme dark instead)")
+	command.PersistentFlags().BoolVar(&parser.EnableEmoji, "enable-emoji", false, "Enable emoji support")
+	command.PersistentFlags().BoolVar(&parser.EnableFootnotes, "enable-footnotes", false, "Enable footnotes")
+	command.PersistentFlags().BoolVar(&parser.EnableWikiLinks, "enable-wikilinks", false, "Enable [[wiki]] style links")
+	command.PersistentFlags().BoolVar(&mpls.CheckExternalLinks, "check-external-links", false, "Report broken http(s) links as diagnostics")
+	command.PersistentFlags().DurationVar(&mpls.ExternalLinkTimeout, "external-link-timeout", linkcheck.DefaultTimeout, "Timeout for each external link check")
+	command.PersistentFlags().DurationVar(&mpls.RenderTimeout, "render-timeout", mpls.DefaultRenderTimeout, "Time allowed for rendering a document (0 for no limit)")
+	command.PersistentFlags().BoolVar(&mpls.TextDocumentUseFullSync, "full-sync", false, "Sync entire document for every change")
+	command.PersistentFlags().BoolVar(&mpls.DiskCache, "disk-cache", false, "Keep rendered diagrams and math on disk between sessions")
+	command.PersistentFlags().IntVar(&mpls.DiskCacheSize, "disk-cache-size", mpls.DefaultDiskCacheSize, "Size limit of the disk cache in MB")
+	command.PersistentFlags().BoolVar(&noAuto, "no-auto", false, "Don't open preview automatically")
+	command.PersistentFlags().StringVar(&plantuml.BasePath, "plantuml-path", "plantuml", "Specify the base path for the plantuml server")
+	command.PersistentFlags().StringVar(&plantuml.Server, "plantuml-server", "www.plantuml.com", "Specify the host for the plantuml server")
+	command.PersistentFlags().BoolVar(&plantuml.DisableTLS, "plantuml-disable-tls", false, "Disable encryption on requests to the plantuml server")
+	command.PersistentFlags().StringVar(&plantuml.Command, "plantuml-command", "", "Render plantuml diagrams with a local command, e.g. \"plantuml -pipe\", instead of the server")
+	command.PersistentFlags().DurationVar(&plantuml.Timeout, "plantuml-timeout", plantuml.DefaultTimeout, "Timeout for each plantuml request or command")
+	command.PersistentFlags().IntVar(&plantuml.Concurrency, "plantuml-concurrency", plantuml.DefaultConcurrency, "Maximum number of plantuml diagrams requested or rendered at once")
+	command.PersistentFlags().StringArrayVar(&plantumlHeaders, "plantuml-header", nil, "Add a header to requests to the plantuml server, as \"Name: value\"")
+	command.PersistentFlags().StringVar(&plantuml.Format, "plantuml-format", plantuml.DefaultFormat, "Image format of plantuml diagrams (png or svg)")
+	command.PersistentFlags().BoolVar(&mpls.MatchPlantUMLTheme, "plantuml-match-theme", false, "Style plantuml diagrams to match the preview theme")
+	command.PersistentFlags().BoolVar(&enableTabs, "tabs", false, "Enable multi-tab preview mode (default: single-page)")
+	command.PersistentFlags().StringSliceVar(&previewserver.CustomCSS, "css", nil, "Add stylesheets to the preview, after the theme")
 
 	// Local flags for main LSP command only
-	command.Flags().StringVar(&parser.CodeHighlightingStyle, "code-style", "catppuccin-mocha", "Higlighting style for code blocks")
-	command.Flags().BoolVar(&darkMode, "dark-mode", false, "Enable dark mode (deprecated: use --theme dark instead)")
 	command.Flags().BoolVar(&listThemes, "list-themes", false, "List all available themes and exit")
-	command.Flags().BoolVar(&parser.EnableEmoji, "enable-emoji", false, "Enable emoji support")
-	command.Flags().BoolVar(&parser.EnableFootnotes, "enable-footnotes", false, "Enable footnotes")
-	command.Flags().BoolVar(&parser.EnableWikiLinks, "enable-wikilinks", false, "Enable [[wiki]] style links")
-	command.Flags().BoolVar(&mpls.TextDocumentUseFullSync, "full-sync", false, "Sync entire document for every change")
-	command.Flags().BoolVar(&noAuto, "no-auto", false, "Don't open preview automatically")
-	command.Flags().StringVar(&plantuml.BasePath, "plantuml-path", "plantuml", "Specify the base path for the plantuml server")
-	command.Flags().StringVar(&plantuml.Server, "plantuml-server", "www.plantuml.com", "Specify the host for the plantuml server")
-	command.Flags().BoolVar(&plantuml.DisableTLS, "plantuml-disable-tls", false, "Disable encryption on requests to the plantuml server")
-	command.Flags().BoolVar(&enableTabs, "tabs", false, "Enable multi-tab preview mode (default: single-page)")
 
 	// Mark deprecated flags
-	_ = command.Flags().MarkDeprecated("dark-mode", "use --theme dark instead")
+	_ = command.PersistentFlags().MarkDeprecated("dark-mode", "use --theme dark instead")
 
 	// Add subcommands
 	command.AddCommand(demoCmd)
+	command.AddCommand(configCmd)
 }
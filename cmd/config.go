@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mhersson/mpls/internal/mpls"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the mpls configuration",
+}
+
+var configPrintCmd = &cobra.Command{
+	Use:   "print",
+	Short: "Print the effective configuration",
+	Long: `Print the configuration mpls would start with in the current directory:
+the flags, merged with the user configuration file and then the project
+configuration file (.mpls.yaml).`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		applyFlags(cmd)
+
+		root, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+
+		// Like the server, print what could be loaded and report the rest
+		settings, err := mpls.LoadSettings([]string{root})
+		if err != nil {
+			fmt.Fprintln(cmd.ErrOrStderr(), "Warning:", err)
+		}
+
+		encoder := yaml.NewEncoder(cmd.OutOrStdout())
+		encoder.SetIndent(2)
+
+		if err := encoder.Encode(settings); err != nil {
+			return fmt.Errorf("failed to encode configuration: %w", err)
+		}
+
+		return encoder.Close()
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configPrintCmd)
+}
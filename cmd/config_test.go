@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mhersson/mpls/pkg/parser"
+	"github.com/mhersson/mpls/pkg/plantuml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigPrint_Flags(t *testing.T) { //nolint:paralleltest // Flags set global settings
+	wikiLinks, format := parser.EnableWikiLinks, plantuml.Format
+
+	t.Cleanup(func() {
+		parser.EnableWikiLinks, plantuml.Format = wikiLinks, format
+	})
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Chdir(t.TempDir())
+
+	var out bytes.Buffer
+
+	command.SetOut(&out)
+	command.SetArgs([]string{"config", "print", "--enable-wikilinks", "--plantuml-format", "svg"})
+
+	t.Cleanup(func() {
+		command.SetOut(nil)
+		command.SetArgs(nil)
+	})
+
+	require.NoError(t, command.Execute())
+
+	assert.Contains(t, out.String(), "enableWikiLinks: true")
+	assert.Contains(t, out.String(), "format: svg")
+}
@@ -115,7 +115,7 @@ Example:
 		parser.EnableEmoji = true
 
 		// Set workspace root for relative path resolution
-		parser.WorkspaceRoot = cwd
+		parser.SetWorkspaceRoots([]string{cwd})
 
 		// Render demo markdown
 		demoURI := "file://" + cwd + "/demo.md"
@@ -123,7 +123,7 @@ Example:
 
 		// Create and configure preview server
 		server := previewserver.New()
-		server.SetWorkspaceRoot(cwd)
+		server.SetWorkspaceRoots([]string{cwd})
 
 		// Pre-populate content so it's available when browser connects
 		server.Update("demo.md", html, meta)
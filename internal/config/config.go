@@ -0,0 +1,343 @@
+// Package config defines the settings that can be given to mpls in
+// configuration files, as initializationOptions or while it is running.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// Section is the name of the settings section read from the client.
+	Section = "mpls"
+	// ProjectFile is the name of the configuration file in a workspace root.
+	ProjectFile = ".mpls.yaml"
+)
+
+// Extensions are the optional Markdown extensions that can be listed under
+// "extensions".
+var Extensions = []string{"emoji", "footnotes", "wikilinks"}
+
+// Settings is the "mpls" settings section, with one field for every
+// command-line option. Fields that are not given are nil and keep their
+// current value.
+type Settings struct {
+	Theme     *string `json:"theme,omitempty"     yaml:"theme,omitempty"`
+	CodeStyle *string `json:"codeStyle,omitempty" yaml:"codeStyle,omitempty"`
+	Browser   *string `json:"browser,omitempty"   yaml:"browser,omitempty"`
+	// CSS lists stylesheets added to the preview after the theme.
+	CSS []string `json:"css,omitempty" yaml:"css,omitempty"`
+	// Extensions lists the optional Markdown extensions to enable, as an
+	// alternative to the Enable* settings.
+	Extensions          []string  `json:"extensions,omitempty"          yaml:"extensions,omitempty"`
+	EnableEmoji         *bool     `json:"enableEmoji,omitempty"         yaml:"enableEmoji,omitempty"`
+	EnableFootnotes     *bool     `json:"enableFootnotes,omitempty"     yaml:"enableFootnotes,omitempty"`
+	EnableWikiLinks     *bool     `json:"enableWikiLinks,omitempty"     yaml:"enableWikiLinks,omitempty"`
+	CheckExternalLinks  *bool     `json:"checkExternalLinks,omitempty"  yaml:"checkExternalLinks,omitempty"`
+	ExternalLinkTimeout *Duration `json:"externalLinkTimeout,omitempty" yaml:"externalLinkTimeout,omitempty"`
+	RenderTimeout       *Duration `json:"renderTimeout,omitempty"       yaml:"renderTimeout,omitempty"`
+	PlantUML            PlantUML  `json:"plantuml"                      yaml:"plantuml,omitempty"`
+
+	// Only read at startup
+	Port     *int  `json:"port,omitempty"     yaml:"port,omitempty"`
+	Tabs     *bool `json:"tabs,omitempty"     yaml:"tabs,omitempty"`
+	NoAuto   *bool `json:"noAuto,omitempty"   yaml:"noAuto,omitempty"`
+	FullSync *bool `json:"fullSync,omitempty" yaml:"fullSync,omitempty"`
+	// DiskCache keeps rendered diagrams and math on disk between sessions,
+	// within DiskCacheSize MB.
+	DiskCache     *bool `json:"diskCache,omitempty"     yaml:"diskCache,omitempty"`
+	DiskCacheSize *int  `json:"diskCacheSize,omitempty" yaml:"diskCacheSize,omitempty"`
+}
+
+// PlantUML configures the server used to render PlantUML diagrams.
+type PlantUML struct {
+	Server     *string `json:"server,omitempty"     yaml:"server,omitempty"`
+	Path       *string `json:"path,omitempty"       yaml:"path,omitempty"`
+	DisableTLS *bool   `json:"disableTLS,omitempty" yaml:"disableTLS,omitempty"`
+	// Command renders diagrams locally instead of on the server.
+	Command *string `json:"command,omitempty" yaml:"command,omitempty"`
+	// Timeout limits each diagram request.
+	Timeout *Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	// Concurrency limits the diagrams requested or rendered at once.
+	Concurrency *int `json:"concurrency,omitempty" yaml:"concurrency,omitempty"`
+	// Headers are added to every diagram request, e.g. for authentication.
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	// Format is the image format of the diagrams, "png" or "svg".
+	Format *string `json:"format,omitempty" yaml:"format,omitempty"`
+	// MatchTheme styles the diagrams to match the preview theme.
+	MatchTheme *bool `json:"matchTheme,omitempty" yaml:"matchTheme,omitempty"`
+}
+
+// Duration is a time.Duration written as a string such as "10s".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return errors.New("duration must be a string such as \"10s\"")
+	}
+
+	return d.parse(s)
+}
+
+func (d Duration) MarshalYAML() (any, error) {
+	return time.Duration(d).String(), nil
+}
+
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	return d.parse(value.Value)
+}
+
+func (d *Duration) parse(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(v)
+
+	return nil
+}
+
+// Decode reads Settings from the settings sent by a client. Clients either
+// send the "mpls" section itself or an object containing it.
+func Decode(raw any) (Settings, error) {
+	var s Settings
+
+	if raw == nil {
+		return s, nil
+	}
+
+	if m, ok := raw.(map[string]any); ok {
+		if section, ok := m[Section]; ok {
+			raw = section
+		}
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return s, fmt.Errorf("invalid %s settings: %w", Section, err)
+	}
+
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("invalid %s settings: %w", Section, err)
+	}
+
+	if err := s.expandExtensions(); err != nil {
+		return s, fmt.Errorf("invalid %s settings: %w", Section, err)
+	}
+
+	return s, nil
+}
+
+// Load reads Settings from a YAML configuration file. A missing file gives
+// empty settings. Relative CSS paths are resolved against the directory of
+// the file.
+func Load(path string) (Settings, error) {
+	var s Settings
+
+	f, err := os.Open(path) //nolint:gosec // Configuration files are chosen by the user
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	} else if err != nil {
+		return s, err
+	}
+
+	defer f.Close() //nolint:errcheck
+
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(&s); err != nil && !errors.Is(err, io.EOF) {
+		return s, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if err := s.expandExtensions(); err != nil {
+		return s, fmt.Errorf("%s: %w", path, err)
+	}
+
+	for i, css := range s.CSS {
+		if !filepath.IsAbs(css) {
+			s.CSS[i] = filepath.Join(filepath.Dir(path), css)
+		}
+	}
+
+	return s, nil
+}
+
+// RestrictProject removes the settings that a project configuration file is
+// not allowed to set, because it comes with the repository rather than from
+// the user: the browser command, the command that renders PlantUML diagrams,
+// the server diagrams are sent to and the headers sent with them, and
+// stylesheets outside the workspace roots. The error names the settings
+// that were removed.
+func (s *Settings) RestrictProject(roots []string) error {
+	var errs []error
+
+	if s.CSS != nil {
+		css := make([]string, 0, len(s.CSS))
+
+		for _, path := range s.CSS {
+			if !withinRoots(roots, path) {
+				errs = append(errs, fmt.Errorf("css %s is outside the workspace", path))
+
+				continue
+			}
+
+			css = append(css, path)
+		}
+
+		s.CSS = css
+	}
+
+	if s.Browser != nil {
+		s.Browser = nil
+
+		errs = append(errs, errors.New("browser cannot be set in a project file"))
+	}
+
+	if s.PlantUML.Command != nil {
+		s.PlantUML.Command = nil
+
+		errs = append(errs, errors.New("plantuml.command cannot be set in a project file"))
+	}
+
+	if s.PlantUML.Headers != nil {
+		s.PlantUML.Headers = nil
+
+		errs = append(errs, errors.New("plantuml.headers cannot be set in a project file"))
+	}
+
+	if s.PlantUML.Server != nil {
+		s.PlantUML.Server = nil
+
+		errs = append(errs, errors.New("plantuml.server cannot be set in a project file"))
+	}
+
+	if s.PlantUML.DisableTLS != nil {
+		s.PlantUML.DisableTLS = nil
+
+		errs = append(errs, errors.New("plantuml.disableTLS cannot be set in a project file"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// withinRoots reports whether path lies within one of roots, after following
+// symbolic links.
+func withinRoots(roots []string, path string) bool {
+	path = evalSymlinks(path)
+
+	for _, root := range roots {
+		if root == "" {
+			continue
+		}
+
+		rel, err := filepath.Rel(evalSymlinks(root), path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// evalSymlinks returns path with the symbolic links in it followed, as far
+// as the path exists.
+func evalSymlinks(path string) string {
+	path = filepath.Clean(path)
+
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+
+	dir := filepath.Dir(path)
+	if dir == path {
+		return path
+	}
+
+	return filepath.Join(evalSymlinks(dir), filepath.Base(path))
+}
+
+// UserFile returns the path of the user's configuration file,
+// $XDG_CONFIG_HOME/mpls/config.yaml, or "" if it cannot be determined.
+func UserFile() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+
+		dir = filepath.Join(home, ".config")
+	}
+
+	return filepath.Join(dir, "mpls", "config.yaml")
+}
+
+// Merge overrides the settings in s with the ones given in other. A layer
+// that switches to another theme without giving a code style clears the
+// code style, so that it follows the new theme.
+func (s *Settings) Merge(other Settings) {
+	if other.Theme != nil && other.CodeStyle == nil && (s.Theme == nil || *s.Theme != *other.Theme) {
+		s.CodeStyle = nil
+	}
+
+	merge(reflect.ValueOf(s).Elem(), reflect.ValueOf(other))
+}
+
+func merge(dst, src reflect.Value) {
+	for i := range dst.NumField() {
+		switch v := src.Field(i); v.Kind() { //nolint:exhaustive // Settings only holds these kinds
+		case reflect.Struct:
+			merge(dst.Field(i), v)
+		case reflect.Pointer, reflect.Slice, reflect.Map:
+			if !v.IsNil() {
+				dst.Field(i).Set(v)
+			}
+		}
+	}
+}
+
+// expandExtensions turns the extensions list into Enable* settings. Enable*
+// settings given alongside the list take precedence.
+func (s *Settings) expandExtensions() error {
+	if s.Extensions == nil {
+		return nil
+	}
+
+	for _, name := range s.Extensions {
+		if !slices.Contains(Extensions, name) {
+			return fmt.Errorf("unknown extension %q", name)
+		}
+	}
+
+	for name, setting := range map[string]**bool{
+		"emoji":     &s.EnableEmoji,
+		"footnotes": &s.EnableFootnotes,
+		"wikilinks": &s.EnableWikiLinks,
+	} {
+		if *setting == nil {
+			enabled := slices.Contains(s.Extensions, name)
+			*setting = &enabled
+		}
+	}
+
+	s.Extensions = nil
+
+	return nil
+}
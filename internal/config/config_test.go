@@ -0,0 +1,221 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		raw  any
+	}{
+		{"section", map[string]any{"theme": "dark", "enableEmoji": true, "plantuml": map[string]any{"server": "localhost:8080"}}},
+		{"wrapped", map[string]any{"mpls": map[string]any{"theme": "dark", "enableEmoji": true, "plantuml": map[string]any{"server": "localhost:8080"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			s, err := Decode(tt.raw)
+			require.NoError(t, err)
+
+			require.NotNil(t, s.Theme)
+			assert.Equal(t, "dark", *s.Theme)
+			require.NotNil(t, s.EnableEmoji)
+			assert.True(t, *s.EnableEmoji)
+			require.NotNil(t, s.PlantUML.Server)
+			assert.Equal(t, "localhost:8080", *s.PlantUML.Server)
+
+			assert.Nil(t, s.CodeStyle)
+			assert.Nil(t, s.EnableFootnotes)
+			assert.Nil(t, s.PlantUML.DisableTLS)
+		})
+	}
+}
+
+func TestDecode_StartupOptions(t *testing.T) {
+	t.Parallel()
+
+	s, err := Decode(map[string]any{
+		"port":                8080,
+		"tabs":                true,
+		"browser":             "firefox",
+		"externalLinkTimeout": "2s",
+		"renderTimeout":       "30s",
+	})
+	require.NoError(t, err)
+
+	require.NotNil(t, s.Port)
+	assert.Equal(t, 8080, *s.Port)
+	require.NotNil(t, s.Tabs)
+	assert.True(t, *s.Tabs)
+	require.NotNil(t, s.Browser)
+	assert.Equal(t, "firefox", *s.Browser)
+	require.NotNil(t, s.ExternalLinkTimeout)
+	assert.Equal(t, 2*time.Second, time.Duration(*s.ExternalLinkTimeout))
+	require.NotNil(t, s.RenderTimeout)
+	assert.Equal(t, 30*time.Second, time.Duration(*s.RenderTimeout))
+	assert.Nil(t, s.NoAuto)
+
+	_, err = Decode(map[string]any{"externalLinkTimeout": 10})
+	require.Error(t, err)
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	t.Parallel()
+
+	_, err := Decode(map[string]any{"theme": 42})
+	require.Error(t, err)
+
+	s, err := Decode(nil)
+	require.NoError(t, err)
+	assert.Nil(t, s.Theme)
+}
+
+func TestDecode_Extensions(t *testing.T) {
+	t.Parallel()
+
+	s, err := Decode(map[string]any{"extensions": []any{"emoji", "wikilinks"}, "enableWikiLinks": false})
+	require.NoError(t, err)
+
+	require.NotNil(t, s.EnableEmoji)
+	assert.True(t, *s.EnableEmoji)
+	require.NotNil(t, s.EnableFootnotes)
+	assert.False(t, *s.EnableFootnotes, "extensions not listed are disabled")
+	require.NotNil(t, s.EnableWikiLinks)
+	assert.False(t, *s.EnableWikiLinks, "enable settings take precedence over the list")
+	assert.Nil(t, s.Extensions)
+
+	_, err = Decode(map[string]any{"extensions": []any{"tables"}})
+	require.Error(t, err)
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, ProjectFile)
+
+	require.NoError(t, os.WriteFile(path, []byte(`theme: nord
+externalLinkTimeout: 3s
+extensions: [footnotes]
+css:
+  - custom.css
+  - /abs/print.css
+plantuml:
+  server: localhost:8080
+`), 0o600))
+
+	s, err := Load(path)
+	require.NoError(t, err)
+
+	require.NotNil(t, s.Theme)
+	assert.Equal(t, "nord", *s.Theme)
+	require.NotNil(t, s.ExternalLinkTimeout)
+	assert.Equal(t, 3*time.Second, time.Duration(*s.ExternalLinkTimeout))
+	require.NotNil(t, s.EnableFootnotes)
+	assert.True(t, *s.EnableFootnotes)
+	require.NotNil(t, s.PlantUML.Server)
+	assert.Equal(t, "localhost:8080", *s.PlantUML.Server)
+	assert.Equal(t, []string{filepath.Join(dir, "custom.css"), "/abs/print.css"}, s.CSS)
+	assert.Nil(t, s.CodeStyle)
+}
+
+func TestLoad_MissingOrInvalid(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	s, err := Load(filepath.Join(dir, "missing.yaml"))
+	require.NoError(t, err)
+	assert.Nil(t, s.Theme)
+
+	empty := filepath.Join(dir, "empty.yaml")
+	require.NoError(t, os.WriteFile(empty, nil, 0o600))
+
+	_, err = Load(empty)
+	require.NoError(t, err)
+
+	unknown := filepath.Join(dir, "unknown.yaml")
+	require.NoError(t, os.WriteFile(unknown, []byte("colour: red\n"), 0o600))
+
+	_, err = Load(unknown)
+	require.ErrorContains(t, err, unknown)
+}
+
+func TestRestrictProject(t *testing.T) {
+	t.Parallel()
+
+	theme, command := "nord", "plantuml -pipe"
+
+	root, other := t.TempDir(), t.TempDir()
+	roots := []string{t.TempDir(), root}
+
+	s := Settings{Theme: &theme, CSS: []string{filepath.Join(root, "docs", "preview.css")}}
+	require.NoError(t, s.RestrictProject(roots))
+	assert.Equal(t, "nord", *s.Theme)
+	assert.Equal(t, []string{filepath.Join(root, "docs", "preview.css")}, s.CSS)
+
+	server, disableTLS := "evil.example", true
+
+	s.Browser = &command
+	s.PlantUML = PlantUML{
+		Server:     &server,
+		DisableTLS: &disableTLS,
+		Command:    &command,
+		Headers:    map[string]string{"Authorization": "Bearer token"},
+	}
+	require.Error(t, s.RestrictProject(roots))
+	assert.Nil(t, s.Browser)
+	assert.Nil(t, s.PlantUML.Server)
+	assert.Nil(t, s.PlantUML.DisableTLS)
+	assert.Nil(t, s.PlantUML.Command)
+	assert.Nil(t, s.PlantUML.Headers)
+	assert.Equal(t, "nord", *s.Theme, "other settings are kept")
+
+	// Stylesheets must stay in the workspace, also through symbolic links
+	link := filepath.Join(root, "link")
+	require.NoError(t, os.Symlink(other, link))
+
+	s.CSS = []string{
+		filepath.Join(root, "ok.css"),
+		filepath.Join(root, "..", "escape.css"),
+		"/etc/passwd",
+		filepath.Join(link, "secret.css"),
+	}
+	require.ErrorContains(t, s.RestrictProject(roots), "outside the workspace")
+	assert.Equal(t, []string{filepath.Join(root, "ok.css")}, s.CSS)
+}
+
+func TestMerge(t *testing.T) {
+	t.Parallel()
+
+	light, dark, style, server := "light", "dark", "github", "localhost"
+	emoji := true
+
+	s := Settings{Theme: &light, CodeStyle: &style, CSS: []string{"a.css"}}
+	s.Merge(Settings{EnableEmoji: &emoji, PlantUML: PlantUML{Server: &server}})
+
+	assert.Equal(t, "light", *s.Theme)
+	assert.Equal(t, "github", *s.CodeStyle)
+	assert.Equal(t, []string{"a.css"}, s.CSS)
+	assert.True(t, *s.EnableEmoji)
+	assert.Equal(t, "localhost", *s.PlantUML.Server)
+
+	s.Merge(Settings{Theme: &light, CSS: []string{"b.css"}})
+	assert.Equal(t, "github", *s.CodeStyle, "the same theme keeps the code style")
+	assert.Equal(t, []string{"b.css"}, s.CSS)
+
+	s.Merge(Settings{Theme: &dark})
+	assert.Equal(t, "dark", *s.Theme)
+	assert.Nil(t, s.CodeStyle, "a new theme clears the code style")
+}
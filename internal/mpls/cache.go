@@ -0,0 +1,37 @@
+package mpls
+
+import (
+	"github.com/mhersson/mpls/pkg/diskcache"
+	"github.com/mhersson/mpls/pkg/parser"
+	"github.com/mhersson/mpls/pkg/plantuml"
+)
+
+// DefaultDiskCacheSize is the default size limit of the disk cache, in MB.
+const DefaultDiskCacheSize = diskcache.DefaultMaxSize >> 20
+
+var (
+	// DiskCache keeps rendered PlantUML diagrams and math on disk, so that
+	// they are not rendered again after a restart.
+	DiskCache bool
+	// DiskCacheSize is the size limit of the disk cache, in MB.
+	DiskCacheSize = DefaultDiskCacheSize
+)
+
+// openDiskCache opens the disk cache and shares it between diagram and math
+// rendering, if it is enabled. Without it, results are only cached in
+// memory.
+func openDiskCache() error {
+	if !DiskCache {
+		return nil
+	}
+
+	cache, err := diskcache.New(diskcache.Options{MaxSize: int64(DiskCacheSize) << 20})
+	if err != nil {
+		return err
+	}
+
+	plantuml.DiskCache = cache
+	parser.DiskCache = cache
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package mpls
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/mhersson/mpls/pkg/parser"
+	"github.com/mhersson/mpls/pkg/plantuml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenDiskCache(t *testing.T) { //nolint:paralleltest // Sets XDG_CACHE_HOME and the global caches
+	enabled := DiskCache
+
+	t.Cleanup(func() {
+		DiskCache = enabled
+		plantuml.DiskCache, parser.DiskCache = nil, nil
+	})
+
+	dir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", dir)
+
+	DiskCache = false
+
+	require.NoError(t, openDiskCache())
+	assert.Nil(t, plantuml.DiskCache, "the disk cache is optional")
+
+	DiskCache = true
+
+	require.NoError(t, openDiskCache())
+	require.NotNil(t, plantuml.DiskCache)
+	assert.Same(t, plantuml.DiskCache, parser.DiskCache, "diagrams and math share the cache")
+	assert.DirExists(t, filepath.Join(dir, "mpls"))
+}
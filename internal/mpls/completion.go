@@ -0,0 +1,249 @@
+package mpls
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/mhersson/mpls/pkg/parser"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// completionTriggers are the characters after which clients should ask for
+// completions without the user invoking it.
+var completionTriggers = []string{"(", "[", "#", "/", "\"", "'"}
+
+// Patterns matching the text before the cursor when it is inside a link
+// destination. The submatch is the destination typed so far.
+var (
+	imageDestRegex  = regexp.MustCompile(`!\[[^\]]*\]\(([^)\s]*)$`)
+	imgSrcRegex     = regexp.MustCompile(`(?i)<img\b[^>]*?\bsrc\s*=\s*["']([^"']*)$`)
+	linkDestRegex   = regexp.MustCompile(`\]\(([^)\s]*)$`)
+	definitionRegex = regexp.MustCompile(`^ {0,3}\[[^\]]+\]:\s*(\S*)$`)
+	wikiLinkRegex   = regexp.MustCompile(`\[\[([^\]|#]*)(#[^\]|]*)?$`)
+)
+
+func TextDocumentCompletion(_ *glsp.Context, params *protocol.CompletionParams) (any, error) {
+	uri := params.TextDocument.URI
+	if !slices.Contains(validFileExtensions, filepath.Ext(uri)) {
+		return nil, nil
+	}
+
+	content, err := documentContent(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	items := completions(workspaceIndex, uri, content, params.Position)
+	if items == nil {
+		return nil, nil
+	}
+
+	return protocol.CompletionList{Items: items}, nil
+}
+
+// completions returns the link destinations that can be inserted at pos:
+// Markdown files after "](", heading slugs after "#", images inside "![](" or
+// <img src="">, and notes after "[[" when wikilinks are enabled. Paths are
+// relative to the current document.
+func completions(index *WorkspaceIndex, uri, content string, pos protocol.Position) []protocol.CompletionItem {
+	lines := newLineMap(content)
+	offset := lines.offset(pos)
+	prefix := content[lines.offset(protocol.Position{Line: pos.Line}):offset]
+
+	if parser.EnableWikiLinks {
+		if m := wikiLinkRegex.FindStringSubmatch(prefix); m != nil {
+			return wikiLinkCompletions(index, uri, content, m[1], m[2], lines, offset)
+		}
+	}
+
+	image := true
+
+	m := imageDestRegex.FindStringSubmatch(prefix)
+	if m == nil {
+		m = imgSrcRegex.FindStringSubmatch(prefix)
+	}
+
+	if m == nil {
+		image = false
+
+		if m = linkDestRegex.FindStringSubmatch(prefix); m == nil {
+			m = definitionRegex.FindStringSubmatch(prefix)
+		}
+	}
+
+	if m == nil {
+		return nil
+	}
+
+	typed := m[1]
+	if parser.IsExternal(typed) {
+		return nil
+	}
+
+	if path, _, found := strings.Cut(typed, "#"); found && !image {
+		start := lines.position(offset - len(typed) + len(path) + 1)
+
+		return headingCompletions(uri, content, path, protocol.Range{Start: start, End: pos})
+	}
+
+	replace := protocol.Range{Start: lines.position(offset - len(typed)), End: pos}
+	docDir := filepath.Dir(parser.NormalizePath(uri))
+
+	var paths []string
+
+	if image {
+		paths = index.Files(parser.ImageExtensions)
+	} else {
+		for _, d := range index.Documents() {
+			if d.Path != parser.NormalizePath(uri) {
+				paths = append(paths, d.Path)
+			}
+		}
+	}
+
+	items := []protocol.CompletionItem{}
+	kind := protocol.CompletionItemKindFile
+
+	for _, path := range paths {
+		rel, err := filepath.Rel(docDir, path)
+		if err != nil {
+			continue
+		}
+
+		rel = filepath.ToSlash(rel)
+		if strings.HasPrefix(typed, "./") && !strings.HasPrefix(rel, "../") {
+			rel = "./" + rel
+		}
+
+		// Markdown destinations cannot contain spaces
+		insert := strings.ReplaceAll(rel, " ", "%20")
+		detail := index.RelativePath(path)
+
+		items = append(items, protocol.CompletionItem{
+			Label:    rel,
+			Kind:     &kind,
+			Detail:   &detail,
+			TextEdit: protocol.TextEdit{Range: replace, NewText: insert},
+		})
+	}
+
+	return items
+}
+
+// headingCompletions offers the heading slugs of the Markdown file at the
+// link path, or of the current document when path is empty.
+func headingCompletions(uri, content, path string, replace protocol.Range) []protocol.CompletionItem {
+	if path != "" {
+		target, _ := parser.ResolveLink(uri, path)
+		if !slices.Contains(validFileExtensions, filepath.Ext(target)) {
+			return nil
+		}
+
+		var err error
+		if _, content, err = contentForPath(target); err != nil {
+			return nil
+		}
+	}
+
+	items := []protocol.CompletionItem{}
+	kind := protocol.CompletionItemKindReference
+
+	for _, h := range parser.Parse(content).Headings {
+		if h.ID == "" {
+			continue
+		}
+
+		detail := strings.Repeat("#", h.Level) + " " + h.Text
+
+		items = append(items, protocol.CompletionItem{
+			Label:    h.ID,
+			Kind:     &kind,
+			Detail:   &detail,
+			TextEdit: protocol.TextEdit{Range: replace, NewText: h.ID},
+		})
+	}
+
+	return items
+}
+
+// wikiLinkCompletions offers note names after "[[" and the headings of the
+// note after "[[Note#". Notes are inserted by name unless several notes share
+// it, in which case the workspace-relative path is used.
+func wikiLinkCompletions(index *WorkspaceIndex, uri, content, note, fragment string, lines *lineMap,
+	offset int,
+) []protocol.CompletionItem {
+	items := []protocol.CompletionItem{}
+
+	if fragment != "" {
+		replace := protocol.Range{Start: lines.position(offset - len(fragment) + 1), End: lines.position(offset)}
+		kind := protocol.CompletionItemKindReference
+
+		if note != "" {
+			var err error
+			if _, content, err = contentForPath(parser.ResolveWikiLink(uri, note)); err != nil {
+				return items
+			}
+		}
+
+		for _, h := range parser.Parse(content).Headings {
+			if h.Text == "" {
+				continue
+			}
+
+			detail := "#" + h.ID
+
+			items = append(items, protocol.CompletionItem{
+				Label:    h.Text,
+				Kind:     &kind,
+				Detail:   &detail,
+				TextEdit: protocol.TextEdit{Range: replace, NewText: h.Text},
+			})
+		}
+
+		return items
+	}
+
+	docs := index.Documents()
+	names := make(map[string]int, len(docs))
+
+	for _, d := range docs {
+		names[noteName(d.Path)]++
+	}
+
+	replace := protocol.Range{Start: lines.position(offset - len(note)), End: lines.position(offset)}
+	kind := protocol.CompletionItemKindFile
+
+	for _, d := range docs {
+		if d.Path == parser.NormalizePath(uri) {
+			continue
+		}
+
+		name := noteName(d.Path)
+		if names[name] > 1 {
+			rel := index.RelativePath(d.Path)
+			name = strings.TrimSuffix(rel, filepath.Ext(rel))
+		}
+
+		detail := index.RelativePath(d.Path)
+		if title, ok := d.Doc.Meta["title"]; ok {
+			detail = fmt.Sprint(title)
+		}
+
+		items = append(items, protocol.CompletionItem{
+			Label:    name,
+			Kind:     &kind,
+			Detail:   &detail,
+			TextEdit: protocol.TextEdit{Range: replace, NewText: name},
+		})
+	}
+
+	return items
+}
+
+func noteName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
@@ -0,0 +1,150 @@
+package mpls
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/mhersson/mpls/pkg/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func completionEdits(items []protocol.CompletionItem) []string {
+	result := make([]string, 0, len(items))
+	for _, item := range items {
+		result = append(result, item.TextEdit.(protocol.TextEdit).NewText)
+	}
+
+	return result
+}
+
+func TestCompletions_Paths(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"README.md":          "# Readme",
+		"docs/guide.md":      "# Guide",
+		"docs/my notes.md":   "# Notes",
+		"docs/img/arch.svg":  "<svg/>",
+		"assets/logo.PNG":    "png",
+		"assets/data.csv":    "a,b",
+		"node_modules/x.png": "png",
+	})
+
+	index, _ := newTestIndex(root)
+	uri := fileURI(filepath.Join(root, "docs/guide.md"))
+
+	tests := []struct {
+		name     string
+		line     string
+		expected []string
+	}{
+		{"link", "See [the readme](", []string{"../README.md", "my%20notes.md"}},
+		{"link with partial path", "See [x](../R", []string{"../README.md", "my%20notes.md"}},
+		{"dot slash", "See [x](./", []string{"../README.md", "./my%20notes.md"}},
+		{"definition", "[readme]: ", []string{"../README.md", "my%20notes.md"}},
+		{"image", "![Logo](", []string{"../assets/logo.PNG", "img/arch.svg"}},
+		{"html image", `<img width="10" src="`, []string{"../assets/logo.PNG", "img/arch.svg"}},
+		{"external", "[x](https://", nil},
+		{"closed link", "[x](y.md) and more", nil},
+		{"plain text", "Nothing here", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			content := "# Guide\n\n" + tt.line + "\n"
+			pos := protocol.Position{Line: 2, Character: protocol.UInteger(len(tt.line))}
+
+			items := completions(index, uri, content, pos)
+			if tt.expected == nil {
+				assert.Nil(t, items)
+
+				return
+			}
+
+			assert.Equal(t, tt.expected, completionEdits(items))
+		})
+	}
+}
+
+func TestCompletions_ReplaceRange(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"a.md": "", "b.md": ""})
+
+	index, _ := newTestIndex(root)
+
+	items := completions(index, fileURI(filepath.Join(root, "a.md")), "[x](b\n", protocol.Position{Character: 5})
+	require.Len(t, items, 1)
+
+	edit := items[0].TextEdit.(protocol.TextEdit)
+	assert.Equal(t, protocol.Range{
+		Start: protocol.Position{Character: 4},
+		End:   protocol.Position{Character: 5},
+	}, edit.Range)
+}
+
+func TestCompletions_Headings(t *testing.T) { //nolint:paralleltest // Uses global document registry
+	root := setupWorkspace(t, map[string]string{
+		"docs/guide.md": "# Guide\n\n## Rollback procedure\n",
+	})
+
+	index, _ := newTestIndex(root)
+	uri := fileURI(filepath.Join(root, "index.md"))
+	content := "# Index\n\n## Local section\n\n[x](docs/guide.md#ro\n[y](#\n[z](missing.md#\n"
+
+	items := completions(index, uri, content, protocol.Position{Line: 4, Character: 20})
+	assert.Equal(t, []string{"guide", "rollback-procedure"}, completionEdits(items))
+
+	edit := items[1].TextEdit.(protocol.TextEdit)
+	assert.Equal(t, protocol.UInteger(18), edit.Range.Start.Character)
+	assert.Equal(t, "## Rollback procedure", *items[1].Detail)
+
+	items = completions(index, uri, content, protocol.Position{Line: 5, Character: 5})
+	assert.Equal(t, []string{"index", "local-section"}, completionEdits(items))
+
+	items = completions(index, uri, content, protocol.Position{Line: 6, Character: 15})
+	assert.Nil(t, items)
+}
+
+func TestCompletions_WikiLinks(t *testing.T) { //nolint:paralleltest // Modifies global parser settings
+	root := setupWorkspace(t, map[string]string{
+		"index.md":              "",
+		"notes/Design Notes.md": "---\ntitle: Design notes\n---\n\n# Design\n\n## Rollout plan\n",
+		"notes/Todo.md":         "",
+		"archive/Todo.md":       "",
+	})
+
+	parser.EnableWikiLinks = true
+	parser.SetWorkspaceRoots([]string{root})
+
+	t.Cleanup(func() {
+		parser.EnableWikiLinks = false
+		parser.SetWorkspaceRoots(nil)
+	})
+
+	index, _ := newTestIndex(root)
+	uri := fileURI(filepath.Join(root, "index.md"))
+
+	content := "See [[Des\n"
+	items := completions(index, uri, content, protocol.Position{Character: 9})
+	assert.Equal(t, []string{"archive/Todo", "Design Notes", "notes/Todo"}, completionEdits(items))
+	assert.Equal(t, "Design notes", *items[1].Detail)
+
+	edit := items[1].TextEdit.(protocol.TextEdit)
+	assert.Equal(t, protocol.UInteger(6), edit.Range.Start.Character)
+
+	content = "See [[design notes#Roll\n"
+	items = completions(index, uri, content, protocol.Position{Character: 23})
+	assert.Equal(t, []string{"Design", "Rollout plan"}, completionEdits(items))
+	assert.Equal(t, "#rollout-plan", *items[1].Detail)
+
+	parser.EnableWikiLinks = false
+
+	assert.Nil(t, completions(index, uri, "See [[Des\n", protocol.Position{Character: 9}))
+}
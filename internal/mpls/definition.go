@@ -0,0 +1,122 @@
+package mpls
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/mhersson/mpls/pkg/parser"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// linkTarget is the local destination of a link.
+type linkTarget struct {
+	// Path is the absolute path of the target file.
+	Path string
+	// Fragment is the heading anchor, without the "#".
+	Fragment string
+}
+
+// resolveLinkTarget resolves a link in the document at uri to the file and
+// heading it points at, resolving wikilinks with renderer. External links do
+// not resolve.
+func resolveLinkTarget(renderer *parser.Renderer, uri string, l parser.Link) (linkTarget, bool) {
+	if l.Kind == parser.LinkAuto || parser.IsExternal(l.Destination) {
+		return linkTarget{}, false
+	}
+
+	if l.Kind == parser.LinkWiki {
+		target, fragment := parser.SplitFragment(l.Destination)
+		if target == "" {
+			return linkTarget{Path: parser.NormalizePath(uri), Fragment: fragment}, true
+		}
+
+		return linkTarget{Path: renderer.ResolveWikiLink(uri, target), Fragment: fragment}, true
+	}
+
+	path, fragment := parser.ResolveLink(uri, l.Destination)
+	if path == "" {
+		if fragment == "" {
+			return linkTarget{}, false
+		}
+
+		path = parser.NormalizePath(uri)
+	}
+
+	return linkTarget{Path: path, Fragment: fragment}, true
+}
+
+// contentForPath returns the editor's version of the file at path if it is
+// open, and otherwise its content on disk, along with the URI to report
+// locations in.
+func contentForPath(path string) (string, string, error) {
+	if docState, exists := documentRegistry.GetByPath(path); exists {
+		return docState.URI, docState.Content, nil
+	}
+
+	content, err := os.ReadFile(path) //nolint:gosec // Path is resolved from a link in the workspace
+	if err != nil {
+		return "", "", err
+	}
+
+	return fileURI(path), string(content), nil
+}
+
+func TextDocumentDefinition(_ *glsp.Context, params *protocol.DefinitionParams) (any, error) {
+	uri := params.TextDocument.URI
+	if !slices.Contains(validFileExtensions, filepath.Ext(uri)) {
+		return nil, nil
+	}
+
+	content, err := documentContent(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := parser.Parse(content)
+
+	link, ok := doc.LinkAt(newLineMap(content).offset(params.Position))
+	if !ok {
+		return nil, nil
+	}
+
+	target, ok := resolveLinkTarget(currentRenderSettings().renderer, uri, link)
+	if !ok {
+		return nil, nil
+	}
+
+	location, ok := targetLocation(target)
+	if !ok {
+		return nil, nil
+	}
+
+	return location, nil
+}
+
+// targetLocation returns the location of the target file, or of the heading
+// when the target has a fragment that matches one.
+func targetLocation(target linkTarget) (protocol.Location, bool) {
+	if !slices.Contains(validFileExtensions, filepath.Ext(target.Path)) {
+		if info, err := os.Stat(target.Path); err != nil || info.IsDir() {
+			return protocol.Location{}, false
+		}
+
+		return protocol.Location{URI: fileURI(target.Path)}, true
+	}
+
+	uri, content, err := contentForPath(target.Path)
+	if err != nil {
+		return protocol.Location{}, false
+	}
+
+	location := protocol.Location{URI: uri}
+
+	if target.Fragment != "" {
+		if h, ok := parser.Parse(content).FindHeading(target.Fragment); ok {
+			location.Range = newLineMap(content).rangeOf(h.Span)
+		}
+	}
+
+	return location, true
+}
@@ -0,0 +1,101 @@
+package mpls
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// setupWorkspace writes files to a temporary workspace and points the global
+// document registry and workspace index at it.
+func setupWorkspace(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	root := t.TempDir()
+	writeFiles(t, root, files)
+
+	InitializeDocumentRegistry([]string{root})
+	InitializeWorkspaceIndex([]string{root}, documentRegistry)
+
+	return root
+}
+
+func definitionAt(t *testing.T, uri string, line, character int) *protocol.Location {
+	t.Helper()
+
+	result, err := TextDocumentDefinition(nil, &protocol.DefinitionParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Position:     protocol.Position{Line: protocol.UInteger(line), Character: protocol.UInteger(character)},
+		},
+	})
+	require.NoError(t, err)
+
+	if result == nil {
+		return nil
+	}
+
+	location, ok := result.(protocol.Location)
+	require.True(t, ok)
+
+	return &location
+}
+
+func TestTextDocumentDefinition(t *testing.T) { //nolint:paralleltest // Uses global document registry
+	root := setupWorkspace(t, map[string]string{
+		"index.md": "# Index\n\n" +
+			"[Setup](docs/setup.md)\n" +
+			"[Rollback](docs/setup.md#rollback-procedure)\n" +
+			"[Top](#index)\n" +
+			"[Missing](docs/missing.md)\n" +
+			"[Web](https://example.com)\n" +
+			"![Logo](img/logo.png)\n",
+		"docs/setup.md": "# Setup\n\nIntro\n\n## Rollback procedure\n",
+		"img/logo.png":  "png",
+	})
+
+	uri := fileURI(filepath.Join(root, "index.md"))
+	setupURI := fileURI(filepath.Join(root, "docs/setup.md"))
+
+	location := definitionAt(t, uri, 2, 3)
+	require.NotNil(t, location)
+	assert.Equal(t, setupURI, location.URI)
+	assert.Equal(t, protocol.UInteger(0), location.Range.Start.Line)
+
+	location = definitionAt(t, uri, 3, 15)
+	require.NotNil(t, location)
+	assert.Equal(t, setupURI, location.URI)
+	assert.Equal(t, protocol.UInteger(4), location.Range.Start.Line)
+
+	location = definitionAt(t, uri, 4, 2)
+	require.NotNil(t, location)
+	assert.Equal(t, uri, location.URI)
+	assert.Equal(t, protocol.UInteger(0), location.Range.Start.Line)
+
+	assert.Nil(t, definitionAt(t, uri, 5, 2), "missing file")
+	assert.Nil(t, definitionAt(t, uri, 6, 2), "external link")
+	assert.Nil(t, definitionAt(t, uri, 0, 2), "not a link")
+
+	location = definitionAt(t, uri, 7, 2)
+	require.NotNil(t, location)
+	assert.Equal(t, fileURI(filepath.Join(root, "img/logo.png")), location.URI)
+}
+
+func TestTextDocumentDefinition_OpenDocument(t *testing.T) { //nolint:paralleltest // Uses global document registry
+	root := setupWorkspace(t, map[string]string{
+		"a.md": "[B](b.md#new-heading)\n",
+		"b.md": "# Old heading\n",
+	})
+
+	// Unsaved editor content wins over the file on disk
+	bURI := fileURI(filepath.Join(root, "b.md"))
+	documentRegistry.Register(bURI, &DocumentState{Content: "intro\n\n# New heading\n"})
+
+	location := definitionAt(t, fileURI(filepath.Join(root, "a.md")), 0, 1)
+	require.NotNil(t, location)
+	assert.Equal(t, bURI, location.URI)
+	assert.Equal(t, protocol.UInteger(2), location.Range.Start.Line)
+}
@@ -0,0 +1,205 @@
+package mpls
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mhersson/mpls/pkg/linkcheck"
+	"github.com/mhersson/mpls/pkg/parser"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+const diagnosticSource = "mpls"
+
+var (
+	// CheckExternalLinks enables reporting of broken http(s) links. It is off
+	// by default since it makes requests to every linked host.
+	CheckExternalLinks  bool
+	ExternalLinkTimeout time.Duration
+	linkChecker         *linkcheck.Checker
+)
+
+// publishDiagnostics reports broken links in the document at uri.
+func publishDiagnostics(ctx *glsp.Context, uri, content string) {
+	ctx.Notify(protocol.ServerTextDocumentPublishDiagnostics, protocol.PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: linkDiagnostics(uri, content),
+	})
+}
+
+// clearDiagnostics removes all diagnostics for the document at uri.
+func clearDiagnostics(ctx *glsp.Context, uri string) {
+	ctx.Notify(protocol.ServerTextDocumentPublishDiagnostics, protocol.PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: []protocol.Diagnostic{},
+	})
+}
+
+// linkDiagnostics returns a warning for every local link whose file does not
+// exist, every #anchor with no matching heading and every missing image.
+func linkDiagnostics(uri, content string) []protocol.Diagnostic {
+	settings := currentRenderSettings()
+	doc := settings.renderer.Parse(content)
+	lines := newLineMap(content)
+	self := parser.NormalizePath(uri)
+
+	// Linked documents are parsed once no matter how many links point at them
+	targets := map[string]*parser.Document{self: doc}
+
+	diagnostics := []protocol.Diagnostic{}
+	severity := protocol.DiagnosticSeverityWarning
+	source := diagnosticSource
+
+	warn := func(l parser.Link, message string) {
+		span := l.DestSpan
+		if span.Start == span.Stop {
+			span = l.Span
+		}
+
+		diagnostics = append(diagnostics, protocol.Diagnostic{
+			Range:    lines.rangeOf(span),
+			Severity: &severity,
+			Source:   &source,
+			Message:  message,
+		})
+	}
+
+	for _, l := range doc.Links {
+		// Reference-style links are checked through their definition
+		if l.Reference != "" && l.Kind != parser.LinkDefinition {
+			continue
+		}
+
+		target, ok := resolveLinkTarget(settings.renderer, uri, l)
+		if !ok {
+			continue
+		}
+
+		if l.Kind == parser.LinkImage || l.Kind == parser.LinkHTMLImage {
+			path, _ := parser.SplitFragment(l.Destination)
+			if l.Kind == parser.LinkHTMLImage && filepath.IsAbs(path) {
+				target.Path = filepath.Clean(path)
+			}
+
+			if _, err := os.Stat(target.Path); err != nil {
+				warn(l, "Image not found: "+l.Destination)
+			}
+
+			continue
+		}
+
+		if target.Path != self {
+			if _, err := os.Stat(target.Path); err != nil {
+				warn(l, "File not found: "+l.Destination)
+
+				continue
+			}
+		}
+
+		if target.Fragment == "" || !slices.Contains(validFileExtensions, filepath.Ext(target.Path)) {
+			continue
+		}
+
+		targetDoc, ok := targets[target.Path]
+		if !ok {
+			if _, content, err := contentForPath(target.Path); err == nil {
+				targetDoc = settings.renderer.Parse(content)
+			}
+
+			targets[target.Path] = targetDoc
+		}
+
+		if targetDoc == nil {
+			continue
+		}
+
+		if _, ok := targetDoc.FindHeading(target.Fragment); !ok {
+			warn(l, "No heading found for #"+target.Fragment)
+		}
+	}
+
+	if settings.checker != nil {
+		diagnostics = append(diagnostics, externalLinkDiagnostics(settings.checker, doc, lines)...)
+	}
+
+	return diagnostics
+}
+
+// externalLinkDiagnostics returns a warning for every external link that
+// failed its last check. Only cached results are used, so links that have
+// not been checked yet are not reported.
+func externalLinkDiagnostics(checker *linkcheck.Checker, doc *parser.Document, lines *lineMap) []protocol.Diagnostic {
+	severity := protocol.DiagnosticSeverityWarning
+	source := diagnosticSource
+
+	var diagnostics []protocol.Diagnostic
+
+	for _, l := range doc.Links {
+		if !isHTTPLink(l) {
+			continue
+		}
+
+		r, ok := checker.Cached(l.Destination)
+		if !ok || r.OK() {
+			continue
+		}
+
+		span := l.DestSpan
+		if span.Start == span.Stop {
+			span = l.Span
+		}
+
+		diagnostics = append(diagnostics, protocol.Diagnostic{
+			Range:    lines.rangeOf(span),
+			Severity: &severity,
+			Source:   &source,
+			Message:  "External link " + r.Message(),
+		})
+	}
+
+	return diagnostics
+}
+
+// checkExternalLinks checks the external links of the document at uri in the
+// background and publishes its diagnostics again once the results are in.
+func checkExternalLinks(ctx *glsp.Context, uri, content string) {
+	settings := currentRenderSettings()
+	if settings.checker == nil {
+		return
+	}
+
+	var urls []string
+
+	for _, l := range settings.renderer.Parse(content).Links {
+		if isHTTPLink(l) {
+			urls = append(urls, l.Destination)
+		}
+	}
+
+	if len(urls) == 0 {
+		return
+	}
+
+	go func() {
+		settings.checker.CheckAll(serverCtx, urls)
+
+		// The document may have been edited or closed in the meantime
+		if content, exists := documentRegistry.Content(uri); exists {
+			publishDiagnostics(ctx, uri, content)
+		}
+	}()
+}
+
+func isHTTPLink(l parser.Link) bool {
+	if l.Reference != "" && l.Kind != parser.LinkDefinition {
+		return false
+	}
+
+	dest := strings.ToLower(l.Destination)
+
+	return strings.HasPrefix(dest, "http://") || strings.HasPrefix(dest, "https://")
+}
@@ -0,0 +1,142 @@
+package mpls
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/mhersson/mpls/internal/config"
+	"github.com/mhersson/mpls/pkg/linkcheck"
+	"github.com/mhersson/mpls/pkg/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestLinkDiagnostics(t *testing.T) { //nolint:paralleltest // Uses global document registry
+	root := setupWorkspace(t, map[string]string{
+		"docs/guide.md": "# Guide\n\n## Setup\n",
+		"img/logo.png":  "png",
+	})
+
+	content := "# Index\n\n" +
+		"[Guide](docs/guide.md) [Setup](docs/guide.md#setup) [Top](#index)\n" +
+		"[Missing](docs/missing.md)\n" +
+		"[Anchor](docs/guide.md#teardown) [Local](#nowhere)\n" +
+		"![Logo](img/logo.png) ![Gone](img/gone.png)\n" +
+		"<img src=\"img/missing.svg\">\n" +
+		"[Web](https://example.com/missing) [ref][r]\n\n" +
+		"[r]: docs/other.md\n"
+
+	diagnostics := linkDiagnostics(fileURI(filepath.Join(root, "index.md")), content)
+	require.Len(t, diagnostics, 6)
+
+	expected := []struct {
+		line, start, end int
+		message          string
+	}{
+		{3, 10, 25, "File not found: docs/missing.md"},
+		{4, 9, 31, "No heading found for #teardown"},
+		{4, 41, 49, "No heading found for #nowhere"},
+		{5, 30, 42, "Image not found: img/gone.png"},
+		{6, 10, 25, "Image not found: img/missing.svg"},
+		{9, 5, 18, "File not found: docs/other.md"},
+	}
+
+	for i, e := range expected {
+		d := diagnostics[i]
+		assert.Equal(t, e.message, d.Message)
+		assert.Equal(t, e.line, int(d.Range.Start.Line), e.message)
+		assert.Equal(t, e.start, int(d.Range.Start.Character), e.message)
+		assert.Equal(t, e.end, int(d.Range.End.Character), e.message)
+		assert.Equal(t, diagnosticSource, *d.Source)
+	}
+}
+
+func TestLinkDiagnostics_OpenTarget(t *testing.T) { //nolint:paralleltest // Uses global document registry
+	root := setupWorkspace(t, map[string]string{
+		"a.md": "# Old\n",
+	})
+
+	content := "[A](a.md#new)\n"
+	uri := fileURI(filepath.Join(root, "b.md"))
+
+	assert.Len(t, linkDiagnostics(uri, content), 1)
+
+	// The heading only exists in the unsaved editor buffer
+	documentRegistry.Register(fileURI(filepath.Join(root, "a.md")), &DocumentState{Content: "# New\n"})
+	assert.Empty(t, linkDiagnostics(uri, content))
+}
+
+func TestLinkDiagnostics_WikiLinksWhileSettingsChange(t *testing.T) { //nolint:paralleltest // Modifies global parser settings
+	root := setupWorkspace(t, map[string]string{"notes/Ideas.md": "# Ideas\n"})
+
+	wikiLinks, roots := parser.EnableWikiLinks, parser.WorkspaceRoots()
+
+	t.Cleanup(func() {
+		parser.EnableWikiLinks = wikiLinks
+		parser.SetWorkspaceRoots(roots)
+
+		updateRenderSettings()
+	})
+
+	parser.EnableWikiLinks = true
+	parser.SetWorkspaceRoots([]string{root})
+
+	updateRenderSettings()
+
+	uri := fileURI(filepath.Join(root, "index.md"))
+	content := "[[Ideas]] [[Missing]]\n"
+
+	// Diagnostics are published from render workers while settings change
+	// on the LSP goroutine
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for range 20 {
+			diagnostics := linkDiagnostics(uri, content)
+			if assert.Len(t, diagnostics, 1) {
+				assert.Equal(t, "File not found: Missing", diagnostics[0].Message)
+			}
+		}
+	}()
+
+	for range 20 {
+		style := "nord"
+		updateSettings(config.Settings{CodeStyle: &style})
+		parser.CodeHighlightingStyle = "github"
+	}
+
+	<-done
+}
+
+func TestExternalLinkDiagnostics(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ok" {
+			w.WriteHeader(http.StatusOK)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	content := "[Ok](" + server.URL + "/ok) [Gone](" + server.URL + "/gone)\n" +
+		"[Unchecked](" + server.URL + "/later) [Local](other.md)\n"
+
+	doc := parser.Parse(content)
+	checker := linkcheck.New(linkcheck.Options{HostInterval: -1})
+	checker.CheckAll(context.Background(), []string{server.URL + "/ok", server.URL + "/gone"})
+
+	diagnostics := externalLinkDiagnostics(checker, doc, newLineMap(content))
+	require.Len(t, diagnostics, 1)
+	assert.Equal(t, "External link returned 404 Not Found", diagnostics[0].Message)
+	assert.Equal(t, protocol.UInteger(len("[Ok]("+server.URL+"/ok) [Gone](")), diagnostics[0].Range.Start.Character)
+}
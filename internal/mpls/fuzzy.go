@@ -0,0 +1,60 @@
+package mpls
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// fuzzyScore reports whether all characters of pattern appear in candidate
+// in order, ignoring case. Matches on word boundaries and runs of
+// consecutive characters score higher, and a literal substring match beats
+// any scattered one. An empty pattern matches everything.
+func fuzzyScore(pattern, candidate string) (int, bool) {
+	if pattern == "" {
+		return 0, true
+	}
+
+	p := []rune(pattern)
+	pi := 0
+	score := 0
+	streak := 0
+	prev := ' '
+
+	for _, r := range candidate {
+		if pi < len(p) && unicode.ToLower(r) == unicode.ToLower(p[pi]) {
+			score++
+
+			if isWordStart(prev, r) {
+				score += 3
+			}
+
+			streak++
+			score += streak - 1
+			pi++
+		} else {
+			streak = 0
+		}
+
+		prev = r
+	}
+
+	if pi < len(p) {
+		return 0, false
+	}
+
+	if strings.Contains(strings.ToLower(candidate), strings.ToLower(pattern)) {
+		score += 10 * len(p)
+	}
+
+	// Prefer shorter candidates when everything else is equal
+	return score*100 - utf8.RuneCountInString(candidate), true
+}
+
+func isWordStart(prev, r rune) bool {
+	if !unicode.IsLetter(prev) && !unicode.IsDigit(prev) {
+		return true
+	}
+
+	return unicode.IsLower(prev) && unicode.IsUpper(r)
+}
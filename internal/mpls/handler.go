@@ -17,7 +17,22 @@ func init() {
 	Handler.TextDocumentDidClose = TextDocumentDidClose
 	Handler.WorkspaceExecuteCommand = WorkspaceExecuteCommand
 	Handler.WorkspaceDidChangeConfiguration = WorkspaceDidChangeConfiguration
+	Handler.WorkspaceDidChangeWorkspaceFolders = WorkspaceDidChangeWorkspaceFolders
+	Handler.TextDocumentDocumentSymbol = TextDocumentDocumentSymbol
+	Handler.WorkspaceSymbol = WorkspaceSymbol
+	Handler.TextDocumentDefinition = TextDocumentDefinition
+	Handler.TextDocumentReferences = TextDocumentReferences
+	Handler.TextDocumentPrepareRename = TextDocumentPrepareRename
+	Handler.TextDocumentRename = TextDocumentRename
+	Handler.WorkspaceWillRenameFiles = WorkspaceWillRenameFiles
+	Handler.WorkspaceDidRenameFiles = WorkspaceDidRenameFiles
+	Handler.WorkspaceDidCreateFiles = WorkspaceDidCreateFiles
+	Handler.WorkspaceDidDeleteFiles = WorkspaceDidDeleteFiles
+	Handler.WorkspaceDidChangeWatchedFiles = WorkspaceDidChangeWatchedFiles
+	Handler.TextDocumentCompletion = TextDocumentCompletion
+	Handler.TextDocumentHover = TextDocumentHover
 	Handler.CustomRequest = map[string]protocol.CustomRequestHandler{
-		"mpls/editorDidChangeFocus": {Func: editorDidChangeFocus},
+		"mpls/editorDidChangeFocus":  {Func: editorDidChangeFocus},
+		"mpls/editorDidChangeCursor": {Func: editorDidChangeCursor},
 	}
 }
@@ -0,0 +1,113 @@
+package mpls
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mhersson/mpls/pkg/parser"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TextDocumentHover(_ *glsp.Context, params *protocol.HoverParams) (*protocol.Hover, error) {
+	uri := params.TextDocument.URI
+	if !slices.Contains(validFileExtensions, filepath.Ext(uri)) {
+		return nil, nil
+	}
+
+	content, err := documentContent(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	return hover(uri, content, params.Position), nil
+}
+
+// hover describes what is under the cursor: the emoji a shortcode expands
+// to, the body of a referenced footnote, the URL of a reference-style link,
+// and the title and first paragraph of a linked Markdown document.
+func hover(uri, content string, pos protocol.Position) *protocol.Hover {
+	doc := parser.Parse(content)
+	lines := newLineMap(content)
+	offset := lines.offset(pos)
+
+	if e, ok := doc.EmojiAt(offset); ok {
+		return markdownHover(e.Value+" `:"+e.ShortName+":` "+e.Name, lines.rangeOf(e.Span))
+	}
+
+	for _, ref := range doc.FootnoteRefs {
+		if !ref.Span.Contains(offset) {
+			continue
+		}
+
+		if f, ok := doc.FootnoteAt(offset); ok {
+			return markdownHover(f.Text, lines.rangeOf(ref.Span))
+		}
+	}
+
+	link, ok := doc.LinkAt(offset)
+	if !ok {
+		return nil
+	}
+
+	var sections []string
+
+	if link.Reference != "" && link.Kind != parser.LinkDefinition {
+		sections = append(sections, "`"+link.Destination+"`")
+	}
+
+	if target, ok := resolveLinkTarget(currentRenderSettings().renderer, uri, link); ok {
+		if preview := documentPreview(target); preview != "" {
+			sections = append(sections, preview)
+		}
+	}
+
+	if len(sections) == 0 {
+		return nil
+	}
+
+	return markdownHover(strings.Join(sections, "\n\n---\n\n"), lines.rangeOf(link.Span))
+}
+
+// documentPreview returns the title, the targeted heading and the first
+// paragraph of the Markdown document a link points at.
+func documentPreview(target linkTarget) string {
+	if !slices.Contains(validFileExtensions, filepath.Ext(target.Path)) {
+		return ""
+	}
+
+	_, content, err := contentForPath(target.Path)
+	if err != nil {
+		return ""
+	}
+
+	doc := parser.Parse(content)
+
+	title := doc.Title
+	if title == "" {
+		title = filepath.Base(target.Path)
+	}
+
+	heading := ""
+	if target.Fragment != "" {
+		if h, ok := doc.FindHeading(target.Fragment); ok && h.Text != title {
+			heading = " › " + h.Text
+		}
+	}
+
+	title = "**" + title + "**" + heading
+
+	if doc.Summary == "" {
+		return title
+	}
+
+	return title + "\n\n" + doc.Summary
+}
+
+func markdownHover(value string, rng protocol.Range) *protocol.Hover {
+	return &protocol.Hover{
+		Contents: protocol.MarkupContent{Kind: protocol.MarkupKindMarkdown, Value: value},
+		Range:    &rng,
+	}
+}
@@ -0,0 +1,60 @@
+package mpls
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestHover_Links(t *testing.T) { //nolint:paralleltest // Uses global document registry
+	root := setupWorkspace(t, map[string]string{
+		"docs/setup.md": "---\ntitle: Setup guide\n---\n\n# Setup\n\nInstall the *tools*\nfirst.\n\n## Rollback\n",
+		"docs/plain.md": "No title here.\n",
+	})
+
+	uri := fileURI(filepath.Join(root, "index.md"))
+	content := "# Index\n\n" +
+		"[Setup](docs/setup.md)\n" +
+		"[Rollback](docs/setup.md#rollback)\n" +
+		"[Plain](docs/plain.md)\n" +
+		"[Site][site] and [guide][]\n" +
+		"[Web](https://example.com)\n" +
+		"\n" +
+		"[site]: https://example.com/docs\n" +
+		"[guide]: docs/setup.md\n"
+
+	tests := []struct {
+		name     string
+		line     int
+		char     int
+		expected string
+	}{
+		{"title and summary", 2, 3, "**Setup guide**\n\nInstall the *tools*\nfirst."},
+		{"heading", 3, 3, "**Setup guide** › Rollback\n\nInstall the *tools*\nfirst."},
+		{"file name", 4, 3, "**plain.md**\n\nNo title here."},
+		{"reference url", 5, 3, "`https://example.com/docs`"},
+		{"reference to document", 5, 20, "`docs/setup.md`\n\n---\n\n**Setup guide**\n\nInstall the *tools*\nfirst."},
+		{"external", 6, 3, ""},
+		{"heading text", 0, 3, ""},
+	}
+
+	for _, tt := range tests {
+		result := hover(uri, content, protocol.Position{Line: protocol.UInteger(tt.line), Character: protocol.UInteger(tt.char)})
+		if tt.expected == "" {
+			assert.Nil(t, result, tt.name)
+
+			continue
+		}
+
+		require.NotNil(t, result, tt.name)
+
+		markup, ok := result.Contents.(protocol.MarkupContent)
+		require.True(t, ok)
+		assert.Equal(t, protocol.MarkupKindMarkdown, markup.Kind)
+		assert.Equal(t, tt.expected, markup.Value, tt.name)
+		assert.Equal(t, protocol.UInteger(tt.line), result.Range.Start.Line, tt.name)
+	}
+}
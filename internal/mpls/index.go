@@ -0,0 +1,244 @@
+package mpls
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mhersson/mpls/pkg/parser"
+)
+
+// indexedDocument is a parsed Markdown file in the workspace.
+type indexedDocument struct {
+	URI     string
+	Path    string
+	Content string
+	Doc     *parser.Document
+	Lines   *lineMap
+	modTime time.Time
+}
+
+// WorkspaceIndex keeps every Markdown file under the workspace roots parsed,
+// re-parsing files only when they change on disk. Documents open in the
+// editor are indexed from the registry instead of the file system. When the
+// editor reports file changes, the list of files is kept between calls
+// instead of walking the roots every time.
+type WorkspaceIndex struct {
+	roots      []string
+	registry   *DocumentRegistry
+	docs       map[string]*indexedDocument // absolute path -> document
+	files      []string                    // Files under the roots, nil until walked
+	cacheFiles bool                        // Keep files until InvalidateFiles
+	mutex      sync.Mutex
+}
+
+var workspaceIndex *WorkspaceIndex
+
+func InitializeWorkspaceIndex(wsRoots []string, registry *DocumentRegistry) {
+	workspaceIndex = newWorkspaceIndex(wsRoots, registry)
+}
+
+func newWorkspaceIndex(wsRoots []string, registry *DocumentRegistry) *WorkspaceIndex {
+	return &WorkspaceIndex{
+		roots:    wsRoots,
+		registry: registry,
+		docs:     make(map[string]*indexedDocument),
+	}
+}
+
+// Documents returns an up to date snapshot of all indexed documents,
+// sorted by path.
+func (w *WorkspaceIndex) Documents() []*indexedDocument {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	seen := make(map[string]bool)
+
+	// Open documents take precedence over their content on disk
+	for _, state := range w.registry.All() {
+		path := parser.NormalizePath(state.URI)
+		seen[path] = true
+
+		if doc, ok := w.docs[path]; ok && doc.modTime.IsZero() && doc.Content == state.Content {
+			continue
+		}
+
+		w.docs[path] = newIndexedDocument(state.URI, path, state.Content, time.Time{})
+	}
+
+	for _, path := range w.workspaceFiles() {
+		if seen[path] || !slices.Contains(validFileExtensions, filepath.Ext(path)) {
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			continue // Removed since the roots were walked
+		}
+
+		seen[path] = true
+
+		if doc, ok := w.docs[path]; ok && doc.modTime.Equal(info.ModTime()) {
+			continue
+		}
+
+		content, err := os.ReadFile(path) //nolint:gosec // Path comes from walking the workspace root
+		if err != nil {
+			continue
+		}
+
+		w.docs[path] = newIndexedDocument(fileURI(path), path, string(content), info.ModTime())
+	}
+
+	docs := make([]*indexedDocument, 0, len(w.docs))
+
+	for path, doc := range w.docs {
+		if !seen[path] {
+			delete(w.docs, path)
+
+			continue
+		}
+
+		docs = append(docs, doc)
+	}
+
+	sort.Slice(docs, func(i, j int) bool { return docs[i].Path < docs[j].Path })
+
+	return docs
+}
+
+// Files returns the paths of all files under the workspace roots with one of
+// the given extensions, sorted by root.
+func (w *WorkspaceIndex) Files(extensions []string) []string {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	var paths []string
+
+	for _, path := range w.workspaceFiles() {
+		if slices.Contains(extensions, strings.ToLower(filepath.Ext(path))) {
+			paths = append(paths, path)
+		}
+	}
+
+	return paths
+}
+
+// workspaceFiles returns the files under the roots, walking them unless the
+// list is cached. The caller must hold w.mutex.
+func (w *WorkspaceIndex) workspaceFiles() []string {
+	if w.files != nil {
+		return w.files
+	}
+
+	files := []string{}
+
+	parser.WalkWorkspace(w.roots, func(path string, _ fs.DirEntry) {
+		files = append(files, path)
+	})
+
+	if w.cacheFiles {
+		w.files = files
+	}
+
+	return files
+}
+
+// CacheFiles keeps the list of files under the roots between calls. The
+// caller must call InvalidateFiles whenever files are created, deleted or
+// renamed.
+func (w *WorkspaceIndex) CacheFiles() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.cacheFiles = true
+}
+
+// InvalidateFiles makes the next call walk the roots again.
+func (w *WorkspaceIndex) InvalidateFiles() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.files = nil
+}
+
+// Roots returns the workspace roots being indexed.
+func (w *WorkspaceIndex) Roots() []string {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return slices.Clone(w.roots)
+}
+
+// SetRoots changes the workspace roots being indexed. Documents under
+// removed roots are dropped by the next call to Documents.
+func (w *WorkspaceIndex) SetRoots(roots []string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.roots = slices.Clone(roots)
+	w.files = nil
+}
+
+// RelativePath returns path relative to the workspace root containing it,
+// prefixed with the root's name when there are several, or path itself
+// when it lies outside the workspace.
+func (w *WorkspaceIndex) RelativePath(path string) string {
+	if rel := parser.PreviewPath(w.Roots(), path); rel != "" {
+		return strings.TrimPrefix(rel, "/")
+	}
+
+	return path
+}
+
+// linkReference is a link in an indexed document together with the file and
+// heading it resolves to.
+type linkReference struct {
+	Doc    *indexedDocument
+	Link   parser.Link
+	Target linkTarget
+}
+
+// LinksTo returns every link in the workspace that resolves to the file at
+// path, in document order.
+func (w *WorkspaceIndex) LinksTo(path string) []linkReference {
+	var refs []linkReference
+
+	renderer := currentRenderSettings().renderer
+
+	for _, d := range w.Documents() {
+		for _, l := range d.Doc.Links {
+			if target, ok := resolveLinkTarget(renderer, d.URI, l); ok && target.Path == path {
+				refs = append(refs, linkReference{Doc: d, Link: l, Target: target})
+			}
+		}
+	}
+
+	return refs
+}
+
+func newIndexedDocument(uri, path, content string, modTime time.Time) *indexedDocument {
+	return &indexedDocument{
+		URI:     uri,
+		Path:    path,
+		Content: content,
+		Doc:     parser.Parse(content),
+		Lines:   newLineMap(content),
+		modTime: modTime,
+	}
+}
+
+// fileURI converts an absolute file system path to a file:// URI.
+func fileURI(path string) string {
+	if runtime.GOOS == "windows" {
+		return "file:///" + filepath.ToSlash(path)
+	}
+
+	return "file://" + path
+}
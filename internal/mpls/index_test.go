@@ -0,0 +1,180 @@
+package mpls
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// writeFiles creates files relative to root, creating directories as needed.
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+}
+
+func newTestIndex(root string) (*WorkspaceIndex, *DocumentRegistry) {
+	registry := &DocumentRegistry{
+		docs:           make(map[string]*DocumentState),
+		workspaceRoots: []string{root},
+	}
+
+	return newWorkspaceIndex([]string{root}, registry), registry
+}
+
+func TestWorkspaceIndex_Documents(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"README.md":                 "# Readme",
+		"docs/guide.markdown":       "# Guide",
+		"docs/image.png":            "not markdown",
+		".git/notes.md":             "# Hidden",
+		"node_modules/pkg/index.md": "# Dependency",
+	})
+
+	index, _ := newTestIndex(root)
+	docs := index.Documents()
+	require.Len(t, docs, 2)
+
+	assert.Equal(t, "README.md", index.RelativePath(docs[0].Path))
+	assert.Equal(t, "docs/guide.markdown", index.RelativePath(docs[1].Path))
+	assert.Equal(t, fileURI(filepath.Join(root, "README.md")), docs[0].URI)
+	require.Len(t, docs[1].Doc.Headings, 1)
+	assert.Equal(t, "Guide", docs[1].Doc.Headings[0].Text)
+}
+
+func TestWorkspaceIndex_ReparsesChangedFiles(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"a.md": "# Before", "b.md": "# Gone"})
+
+	index, _ := newTestIndex(root)
+	require.Len(t, index.Documents(), 2)
+
+	path := filepath.Join(root, "a.md")
+	require.NoError(t, os.WriteFile(path, []byte("# After"), 0o600))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Second)))
+	require.NoError(t, os.Remove(filepath.Join(root, "b.md")))
+
+	docs := index.Documents()
+	require.Len(t, docs, 1)
+	assert.Equal(t, "After", docs[0].Doc.Headings[0].Text)
+}
+
+func TestWorkspaceIndex_PrefersOpenDocuments(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"a.md": "# On disk"})
+
+	index, registry := newTestIndex(root)
+
+	uri := fileURI(filepath.Join(root, "a.md"))
+	registry.Register(uri, &DocumentState{Content: "# Unsaved"})
+
+	docs := index.Documents()
+	require.Len(t, docs, 1)
+	assert.Equal(t, uri, docs[0].URI)
+	assert.Equal(t, "Unsaved", docs[0].Doc.Headings[0].Text)
+}
+
+func TestWorkspaceIndex_MultipleRoots(t *testing.T) {
+	t.Parallel()
+
+	docs, infra := t.TempDir(), t.TempDir()
+	writeFiles(t, docs, map[string]string{"README.md": "# Docs", "nested/api.md": "# API"})
+	writeFiles(t, infra, map[string]string{"README.md": "# Infra"})
+
+	// A root nested in another is only indexed once
+	roots := []string{docs, infra, filepath.Join(docs, "nested")}
+	index := newWorkspaceIndex(roots, &DocumentRegistry{docs: make(map[string]*DocumentState), workspaceRoots: roots})
+
+	paths := make([]string, 0, 3)
+	for _, d := range index.Documents() {
+		paths = append(paths, index.RelativePath(d.Path))
+	}
+
+	assert.ElementsMatch(t, []string{
+		filepath.Base(docs) + "/README.md",
+		filepath.Base(infra) + "/README.md",
+		"nested/api.md",
+	}, paths)
+
+	index.SetRoots([]string{infra})
+	require.Len(t, index.Documents(), 1)
+	assert.Equal(t, "README.md", index.RelativePath(filepath.Join(infra, "README.md")))
+	assert.Len(t, index.Files([]string{".md"}), 1)
+}
+
+func TestWorkspaceIndex_CachedFiles(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"a.md": "# A", "b.md": "# Before"})
+
+	index, _ := newTestIndex(root)
+	index.CacheFiles()
+	require.Len(t, index.Documents(), 2)
+
+	// Changed files are re-read, but new ones are not listed until the
+	// files are invalidated
+	writeFiles(t, root, map[string]string{"c.md": "# New"})
+	require.NoError(t, os.WriteFile(filepath.Join(root, "b.md"), []byte("# After"), 0o600))
+	require.NoError(t, os.Chtimes(filepath.Join(root, "b.md"), time.Now().Add(time.Minute), time.Now().Add(time.Minute)))
+
+	docs := index.Documents()
+	require.Len(t, docs, 2)
+	assert.Equal(t, "# After", docs[1].Content)
+	assert.Len(t, index.Files([]string{".md"}), 2)
+
+	index.InvalidateFiles()
+	assert.Len(t, index.Documents(), 3)
+
+	// Removed files are dropped without invalidating
+	require.NoError(t, os.Remove(filepath.Join(root, "a.md")))
+	assert.Len(t, index.Documents(), 2)
+}
+
+func TestWorkspaceDidCreateFiles(t *testing.T) { //nolint:paralleltest // Modifies the global workspace index
+	root := setupWorkspace(t, map[string]string{"a.md": "# A"})
+	workspaceIndex.CacheFiles()
+	require.Len(t, workspaceIndex.Documents(), 1)
+
+	writeFiles(t, root, map[string]string{"b.md": "# B"})
+	require.NoError(t, WorkspaceDidCreateFiles(nil, &protocol.CreateFilesParams{}))
+
+	assert.Len(t, workspaceIndex.Documents(), 2)
+}
+
+func TestFuzzyScore(t *testing.T) {
+	t.Parallel()
+
+	_, ok := fuzzyScore("rbp", "Rollback procedure")
+	assert.True(t, ok)
+
+	_, ok = fuzzyScore("ROLLBACK", "Rollback procedure")
+	assert.True(t, ok)
+
+	_, ok = fuzzyScore("xyz", "Rollback procedure")
+	assert.False(t, ok)
+
+	_, ok = fuzzyScore("", "anything")
+	assert.True(t, ok)
+
+	// Consecutive and word-start matches rank higher
+	exact, _ := fuzzyScore("roll", "Rollback procedure")
+	scattered, _ := fuzzyScore("roll", "Release of large logs")
+	assert.Greater(t, exact, scattered)
+}
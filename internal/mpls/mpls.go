@@ -3,9 +3,9 @@ package mpls
 import (
 	"encoding/json"
 	"path/filepath"
+	"slices"
 
 	"github.com/mhersson/mpls/internal/previewserver"
-	"github.com/mhersson/mpls/pkg/parser"
 	"github.com/mhersson/mpls/pkg/plantuml"
 	"github.com/tliron/glsp"
 	protocol "github.com/tliron/glsp/protocol_3_16"
@@ -22,7 +22,6 @@ func editorDidChangeFocus(ctx *glsp.Context, params json.RawMessage) (any, error
 	}
 
 	uri := p.URI
-	filename := filepath.Base(uri)
 
 	_ = protocol.Trace(ctx, protocol.MessageTypeInfo, log("MplsEditorDidChangedFocus: "+uri))
 
@@ -49,31 +48,38 @@ func editorDidChangeFocus(ctx *glsp.Context, params json.RawMessage) (any, error
 		return nil, nil
 	}
 
-	html, meta := parser.HTML(docState.Content, uri, 0)
+	scheduleRender(ctx, uri, renderRequest{content: docState.Content, generate: true, preview: true})
 
-	var err error
+	return nil, nil
+}
 
-	html, docState.PlantUMLs, err = plantuml.InsertPlantumlDiagram(html, true, docState.PlantUMLs)
-	if err != nil {
-		_ = protocol.Trace(ctx, protocol.MessageTypeWarning, log("MplsEditorDidChangeFocus - plantuml: "+err.Error()))
-	}
+type editorDidChangeCursorParams struct {
+	URI string `json:"uri"`
+	// Line is the 0-based line of the cursor, or the top of the viewport.
+	Line int `json:"line"`
+}
 
-	docState.HTML = html
-	docState.Meta = meta
+func editorDidChangeCursor(_ *glsp.Context, params json.RawMessage) (any, error) {
+	var p editorDidChangeCursorParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
 
-	// Get relative path for URI filtering
-	relativePath := documentRegistry.GetRelativePath(uri)
-	if relativePath == "" {
-		relativePath = "/"
+	if !slices.Contains(validFileExtensions, filepath.Ext(p.URI)) {
+		return nil, nil
 	}
 
 	// Set documentURI based on mode
 	documentURI := ""
 	if previewserver.EnableTabs {
-		documentURI = relativePath
+		documentURI = documentRegistry.GetRelativePath(p.URI)
+		if documentURI == "" {
+			documentURI = "/"
+		}
 	}
 
-	previewServer.UpdateWithURI(filename, documentURI, html, meta)
+	// The preview marks blocks with 1-based source lines
+	previewServer.ScrollToLine(p.URI, documentURI, p.Line+1)
 
 	return nil, nil
 }
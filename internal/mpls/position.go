@@ -0,0 +1,87 @@
+package mpls
+
+import (
+	"unicode/utf8"
+
+	"github.com/mhersson/mpls/pkg/parser"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// lineMap converts between byte offsets and LSP positions for one document.
+// LSP characters are counted in UTF-16 code units.
+type lineMap struct {
+	content string
+	lines   []int // byte offset where each line starts
+}
+
+func newLineMap(content string) *lineMap {
+	lines := []int{0}
+
+	for i := range len(content) {
+		if content[i] == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+
+	return &lineMap{content: content, lines: lines}
+}
+
+// position returns the LSP position of the byte offset.
+func (m *lineMap) position(offset int) protocol.Position {
+	offset = max(0, min(offset, len(m.content)))
+
+	// Binary search for the last line starting at or before offset
+	low, high := 0, len(m.lines)-1
+	for low < high {
+		mid := (low + high + 1) / 2
+		if m.lines[mid] <= offset {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+
+	character := 0
+
+	for _, r := range m.content[m.lines[low]:offset] {
+		if r >= 0x10000 {
+			character += 2
+		} else {
+			character++
+		}
+	}
+
+	return protocol.Position{Line: protocol.UInteger(low), Character: protocol.UInteger(character)}
+}
+
+// offset returns the byte offset of the LSP position.
+func (m *lineMap) offset(pos protocol.Position) int {
+	if int(pos.Line) >= len(m.lines) {
+		return len(m.content)
+	}
+
+	offset := m.lines[pos.Line]
+	units := 0
+
+	for offset < len(m.content) && units < int(pos.Character) {
+		r, w := utf8.DecodeRuneInString(m.content[offset:])
+		if r == '\n' {
+			break
+		}
+
+		if r >= 0x10000 {
+			units += 2
+		} else {
+			units++
+		}
+
+		offset += w
+	}
+
+	return offset
+}
+
+// rangeOf returns the LSP range covering the span.
+func (m *lineMap) rangeOf(span parser.Span) protocol.Range {
+	return protocol.Range{Start: m.position(span.Start), End: m.position(span.Stop)}
+}
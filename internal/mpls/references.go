@@ -0,0 +1,87 @@
+package mpls
+
+import (
+	"path/filepath"
+	"slices"
+
+	"github.com/mhersson/mpls/pkg/parser"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TextDocumentReferences(_ *glsp.Context, params *protocol.ReferenceParams) ([]protocol.Location, error) {
+	uri := params.TextDocument.URI
+	if !slices.Contains(validFileExtensions, filepath.Ext(uri)) {
+		return nil, nil
+	}
+
+	content, err := documentContent(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	return references(workspaceIndex, uri, content, params.Position, params.Context.IncludeDeclaration), nil
+}
+
+// references returns the links in the workspace that point at the document
+// when the cursor is on the first line or in the front matter, and otherwise
+// those that point at the heading under the cursor.
+func references(index *WorkspaceIndex, uri, content string, pos protocol.Position, includeDeclaration bool) []protocol.Location {
+	doc := parser.Parse(content)
+	lines := newLineMap(content)
+	offset := lines.offset(pos)
+
+	var declaration protocol.Range
+
+	heading, onHeading := doc.HeadingAt(offset)
+	if atDocumentTop(doc, pos, offset) {
+		// A title on the first line stands for the whole document
+		onHeading = false
+	} else if onHeading {
+		declaration = lines.rangeOf(heading.Span)
+	} else {
+		return nil
+	}
+
+	locations := []protocol.Location{}
+
+	if includeDeclaration {
+		locations = append(locations, protocol.Location{URI: uri, Range: declaration})
+	}
+
+	for _, ref := range index.LinksTo(parser.NormalizePath(uri)) {
+		if onHeading {
+			if ref.Target.Fragment == "" {
+				continue
+			}
+
+			// Match the fragment the same way go-to-definition does, so
+			// case-insensitive wikilink headings are included
+			h, ok := doc.FindHeading(ref.Target.Fragment)
+			if !ok || h.Span != heading.Span {
+				continue
+			}
+		}
+
+		locations = append(locations, protocol.Location{
+			URI:   ref.Doc.URI,
+			Range: ref.Doc.Lines.rangeOf(ref.Link.Span),
+		})
+	}
+
+	return locations
+}
+
+func atDocumentTop(doc *parser.Document, pos protocol.Position, offset int) bool {
+	if pos.Line == 0 {
+		return true
+	}
+
+	for _, b := range doc.Blocks {
+		if b.Kind == parser.BlockFrontMatter && b.Span.Contains(offset) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,91 @@
+package mpls
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/mhersson/mpls/pkg/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// locationLines formats locations as "relative/path:line" for comparison.
+func locationLines(index *WorkspaceIndex, locations []protocol.Location) []string {
+	result := make([]string, 0, len(locations))
+	for _, l := range locations {
+		result = append(result, fmt.Sprintf("%s:%d", index.RelativePath(parser.NormalizePath(l.URI)), l.Range.Start.Line))
+	}
+
+	return result
+}
+
+func TestReferences(t *testing.T) {
+	t.Parallel()
+
+	guide := "# Guide\n\nSee [rollback](#rollback).\n\n## Rollback\n\nSteps.\n"
+
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"guide.md":     guide,
+		"index.md":     "[Guide](guide.md)\n[Rollback](guide.md#rollback)\n[Other](other.md#rollback)\n",
+		"sub/notes.md": "Back to [the guide](../guide.md#rollback) and [top](../guide.md).\n",
+	})
+
+	index, _ := newTestIndex(root)
+	uri := fileURI(filepath.Join(root, "guide.md"))
+
+	// Cursor on the "## Rollback" heading
+	refs := references(index, uri, guide, protocol.Position{Line: 4, Character: 4}, false)
+	assert.Equal(t, []string{"guide.md:2", "index.md:1", "sub/notes.md:0"}, locationLines(index, refs))
+
+	// Cursor at the top of the file lists every link to the document
+	refs = references(index, uri, guide, protocol.Position{Line: 0, Character: 0}, false)
+	assert.Equal(t, []string{"guide.md:2", "index.md:0", "index.md:1", "sub/notes.md:0", "sub/notes.md:0"},
+		locationLines(index, refs))
+
+	// The declaration comes first when requested
+	refs = references(index, uri, guide, protocol.Position{Line: 4, Character: 0}, true)
+	assert.Equal(t, []string{"guide.md:4", "guide.md:2", "index.md:1", "sub/notes.md:0"}, locationLines(index, refs))
+
+	// Anywhere else there is nothing to look up
+	assert.Nil(t, references(index, uri, guide, protocol.Position{Line: 6, Character: 0}, false))
+}
+
+func TestReferences_FrontMatter(t *testing.T) {
+	t.Parallel()
+
+	content := "---\ntitle: A\n---\n\nText\n"
+
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"a.md": content,
+		"b.md": "[A](a.md)\n",
+	})
+
+	index, _ := newTestIndex(root)
+	uri := fileURI(filepath.Join(root, "a.md"))
+
+	refs := references(index, uri, content, protocol.Position{Line: 1, Character: 2}, false)
+	require.Len(t, refs, 1)
+	assert.Equal(t, fileURI(filepath.Join(root, "b.md")), refs[0].URI)
+}
+
+func TestReferences_OpenDocument(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"a.md": "# Setup\n",
+		"b.md": "Nothing yet\n",
+	})
+
+	index, registry := newTestIndex(root)
+	bURI := fileURI(filepath.Join(root, "b.md"))
+	registry.Register(bURI, &DocumentState{Content: "Unsaved [link](a.md#setup)\n"})
+
+	refs := references(index, fileURI(filepath.Join(root, "a.md")), "# Setup\n", protocol.Position{}, false)
+	require.Len(t, refs, 1)
+	assert.Equal(t, bURI, refs[0].URI)
+}
@@ -1,7 +1,7 @@
 package mpls
 
 import (
-	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -11,6 +11,9 @@ import (
 	"github.com/mhersson/mpls/pkg/plantuml"
 )
 
+// DocumentState is an open document. Documents are rendered and checked in
+// the background, so a registered state is only changed through the
+// registry, and the states the registry returns are copies.
 type DocumentState struct {
 	URI          string
 	Content      string
@@ -22,21 +25,23 @@ type DocumentState struct {
 
 type DocumentRegistry struct {
 	docs              map[string]*DocumentState
-	workspaceRoot     string
+	workspaceRoots    []string
 	firstPreviewShown bool
 	mutex             sync.RWMutex
 }
 
 var documentRegistry *DocumentRegistry
 
-func InitializeDocumentRegistry(wsRoot string) {
+func InitializeDocumentRegistry(wsRoots []string) {
 	documentRegistry = &DocumentRegistry{
 		docs:              make(map[string]*DocumentState),
-		workspaceRoot:     wsRoot,
+		workspaceRoots:    wsRoots,
 		firstPreviewShown: false,
 	}
 }
 
+// Register adds the document at uri, or replaces it. The registry takes
+// over state, which must not be changed afterwards.
 func (r *DocumentRegistry) Register(uri string, state *DocumentState) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
@@ -56,45 +61,130 @@ func (r *DocumentRegistry) Update(uri string, content string) {
 	}
 }
 
-func (r *DocumentRegistry) Get(uri string) (*DocumentState, bool) {
+// Content returns the content of the document at uri.
+func (r *DocumentRegistry) Content(uri string) (string, bool) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
-	doc, exists := r.docs[uri]
+	if doc, exists := r.docs[uri]; exists {
+		return doc.Content, true
+	}
 
-	return doc, exists
+	return "", false
 }
 
-func (r *DocumentRegistry) GetRelativePath(uri string) string {
-	normalizedURI := parser.NormalizePath(uri)
-	normalizedRoot := parser.NormalizePath(r.workspaceRoot)
+// SetPlantUMLs stores the PlantUML diagrams of the document at uri.
+func (r *DocumentRegistry) SetPlantUMLs(uri string, plantUMLs []plantuml.Plantuml) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
 
-	if !strings.HasPrefix(normalizedURI, normalizedRoot) {
-		return ""
+	if doc, exists := r.docs[uri]; exists {
+		doc.PlantUMLs = slices.Clone(plantUMLs)
 	}
+}
 
-	relativePath, err := filepath.Rel(normalizedRoot, normalizedURI)
-	if err != nil {
-		return ""
+// ClearPlantUMLs forgets the PlantUML diagrams of all documents, so that
+// they are requested again.
+func (r *DocumentRegistry) ClearPlantUMLs() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, doc := range r.docs {
+		doc.PlantUMLs = []plantuml.Plantuml{}
+	}
+}
+
+// SetRendered stores the rendered HTML and front matter of the document at
+// uri. Documents can be rendered in the background, so these fields are only
+// changed through the registry once a document is registered.
+func (r *DocumentRegistry) SetRendered(uri, html string, meta map[string]any) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if doc, exists := r.docs[uri]; exists {
+		doc.HTML = html
+		doc.Meta = meta
 	}
+}
 
-	// Ensure it starts with /
-	if !strings.HasPrefix(relativePath, "/") {
-		relativePath = "/" + relativePath
+// Rendered returns the rendered HTML and front matter of the document at
+// uri.
+func (r *DocumentRegistry) Rendered(uri string) (string, map[string]any) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if doc, exists := r.docs[uri]; exists {
+		return doc.HTML, doc.Meta
 	}
 
-	return relativePath
+	return "", nil
 }
 
-func (r *DocumentRegistry) GetFileURI(relativePath string) string {
+// Get returns a copy of the document at uri.
+func (r *DocumentRegistry) Get(uri string) (*DocumentState, bool) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
-	// Remove leading slash if present
-	relativePath = strings.TrimPrefix(relativePath, "/")
+	doc, exists := r.docs[uri]
+	if !exists {
+		return nil, false
+	}
 
-	// Construct absolute path
-	absolutePath := filepath.Join(r.workspaceRoot, relativePath)
+	return doc.clone(), true
+}
+
+// GetByPath returns a copy of the document whose URI refers to the file
+// system path.
+func (r *DocumentRegistry) GetByPath(path string) (*DocumentState, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for uri, doc := range r.docs {
+		if parser.NormalizePath(uri) == path {
+			return doc.clone(), true
+		}
+	}
+
+	return nil, false
+}
+
+// All returns a copy of all registered documents.
+func (r *DocumentRegistry) All() []*DocumentState {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	docs := make([]*DocumentState, 0, len(r.docs))
+	for _, doc := range r.docs {
+		docs = append(docs, doc.clone())
+	}
+
+	return docs
+}
+
+// clone returns a copy of the state that can be used without holding the
+// registry's lock.
+func (d *DocumentState) clone() *DocumentState {
+	c := *d
+	c.PlantUMLs = slices.Clone(d.PlantUMLs)
+
+	return &c
+}
+
+// GetRelativePath returns the path of the document in preview URLs, see
+// parser.PreviewPath, or "" if it lies outside the workspace.
+func (r *DocumentRegistry) GetRelativePath(uri string) string {
+	return parser.PreviewPath(r.GetWorkspaceRoots(), parser.NormalizePath(uri))
+}
+
+// GetFileURI returns the URI of the document at a preview URL path.
+func (r *DocumentRegistry) GetFileURI(relativePath string) string {
+	absolutePath := parser.PreviewFile(r.GetWorkspaceRoots(), relativePath)
+	if absolutePath == "" {
+		return ""
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
 
 	// Look for matching document
 	for uri := range r.docs {
@@ -132,8 +222,18 @@ func (r *DocumentRegistry) ShouldAutoOpen() bool {
 	return r.firstPreviewShown
 }
 
-func (r *DocumentRegistry) GetWorkspaceRoot() string {
-	return r.workspaceRoot
+func (r *DocumentRegistry) GetWorkspaceRoots() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return slices.Clone(r.workspaceRoots)
+}
+
+func (r *DocumentRegistry) SetWorkspaceRoots(roots []string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.workspaceRoots = slices.Clone(roots)
 }
 
 func (r *DocumentRegistry) GetMostRecentDocument() *DocumentState {
@@ -151,7 +251,11 @@ func (r *DocumentRegistry) GetMostRecentDocument() *DocumentState {
 		}
 	}
 
-	return mostRecent
+	if mostRecent == nil {
+		return nil
+	}
+
+	return mostRecent.clone()
 }
 
 func (r *DocumentRegistry) Remove(uri string) {
@@ -39,8 +39,8 @@ func TestDocumentRegistry_Register(t *testing.T) {
 			t.Parallel()
 
 			r := &DocumentRegistry{
-				docs:          make(map[string]*DocumentState),
-				workspaceRoot: "/home/user",
+				docs:           make(map[string]*DocumentState),
+				workspaceRoots: []string{"/home/user"},
 			}
 
 			state := &DocumentState{Content: tt.content}
@@ -67,8 +67,8 @@ func TestDocumentRegistry_Update(t *testing.T) {
 		t.Parallel()
 
 		r := &DocumentRegistry{
-			docs:          make(map[string]*DocumentState),
-			workspaceRoot: "/home/user",
+			docs:           make(map[string]*DocumentState),
+			workspaceRoots: []string{"/home/user"},
 		}
 
 		uri := "file:///test.md"
@@ -92,8 +92,8 @@ func TestDocumentRegistry_Update(t *testing.T) {
 		t.Parallel()
 
 		r := &DocumentRegistry{
-			docs:          make(map[string]*DocumentState),
-			workspaceRoot: "/home/user",
+			docs:           make(map[string]*DocumentState),
+			workspaceRoots: []string{"/home/user"},
 		}
 
 		// Should not panic or create new entry
@@ -111,8 +111,8 @@ func TestDocumentRegistry_Get(t *testing.T) {
 		t.Parallel()
 
 		r := &DocumentRegistry{
-			docs:          make(map[string]*DocumentState),
-			workspaceRoot: "/home/user",
+			docs:           make(map[string]*DocumentState),
+			workspaceRoots: []string{"/home/user"},
 		}
 
 		uri := "file:///test.md"
@@ -128,8 +128,8 @@ func TestDocumentRegistry_Get(t *testing.T) {
 		t.Parallel()
 
 		r := &DocumentRegistry{
-			docs:          make(map[string]*DocumentState),
-			workspaceRoot: "/home/user",
+			docs:           make(map[string]*DocumentState),
+			workspaceRoots: []string{"/home/user"},
 		}
 
 		_, exists := r.Get("file:///nonexistent.md")
@@ -144,8 +144,8 @@ func TestDocumentRegistry_Remove(t *testing.T) {
 		t.Parallel()
 
 		r := &DocumentRegistry{
-			docs:          make(map[string]*DocumentState),
-			workspaceRoot: "/home/user",
+			docs:           make(map[string]*DocumentState),
+			workspaceRoots: []string{"/home/user"},
 		}
 
 		uri := "file:///test.md"
@@ -162,8 +162,8 @@ func TestDocumentRegistry_Remove(t *testing.T) {
 		t.Parallel()
 
 		r := &DocumentRegistry{
-			docs:          make(map[string]*DocumentState),
-			workspaceRoot: "/home/user",
+			docs:           make(map[string]*DocumentState),
+			workspaceRoots: []string{"/home/user"},
 		}
 
 		// Should not panic
@@ -179,8 +179,8 @@ func TestDocumentRegistry_IsEmpty(t *testing.T) {
 		t.Parallel()
 
 		r := &DocumentRegistry{
-			docs:          make(map[string]*DocumentState),
-			workspaceRoot: "/home/user",
+			docs:           make(map[string]*DocumentState),
+			workspaceRoots: []string{"/home/user"},
 		}
 
 		assert.True(t, r.IsEmpty())
@@ -190,8 +190,8 @@ func TestDocumentRegistry_IsEmpty(t *testing.T) {
 		t.Parallel()
 
 		r := &DocumentRegistry{
-			docs:          make(map[string]*DocumentState),
-			workspaceRoot: "/home/user",
+			docs:           make(map[string]*DocumentState),
+			workspaceRoots: []string{"/home/user"},
 		}
 
 		r.Register("file:///test.md", &DocumentState{})
@@ -202,8 +202,8 @@ func TestDocumentRegistry_IsEmpty(t *testing.T) {
 		t.Parallel()
 
 		r := &DocumentRegistry{
-			docs:          make(map[string]*DocumentState),
-			workspaceRoot: "/home/user",
+			docs:           make(map[string]*DocumentState),
+			workspaceRoots: []string{"/home/user"},
 		}
 
 		uri := "file:///test.md"
@@ -221,8 +221,8 @@ func TestDocumentRegistry_GetMostRecentDocument(t *testing.T) {
 		t.Parallel()
 
 		r := &DocumentRegistry{
-			docs:          make(map[string]*DocumentState),
-			workspaceRoot: "/home/user",
+			docs:           make(map[string]*DocumentState),
+			workspaceRoots: []string{"/home/user"},
 		}
 
 		assert.Nil(t, r.GetMostRecentDocument())
@@ -232,8 +232,8 @@ func TestDocumentRegistry_GetMostRecentDocument(t *testing.T) {
 		t.Parallel()
 
 		r := &DocumentRegistry{
-			docs:          make(map[string]*DocumentState),
-			workspaceRoot: "/home/user",
+			docs:           make(map[string]*DocumentState),
+			workspaceRoots: []string{"/home/user"},
 		}
 
 		state := &DocumentState{Content: "only doc"}
@@ -248,8 +248,8 @@ func TestDocumentRegistry_GetMostRecentDocument(t *testing.T) {
 		t.Parallel()
 
 		r := &DocumentRegistry{
-			docs:          make(map[string]*DocumentState),
-			workspaceRoot: "/home/user",
+			docs:           make(map[string]*DocumentState),
+			workspaceRoots: []string{"/home/user"},
 		}
 
 		// Register first document
@@ -270,8 +270,8 @@ func TestDocumentRegistry_GetMostRecentDocument(t *testing.T) {
 		t.Parallel()
 
 		r := &DocumentRegistry{
-			docs:          make(map[string]*DocumentState),
-			workspaceRoot: "/home/user",
+			docs:           make(map[string]*DocumentState),
+			workspaceRoots: []string{"/home/user"},
 		}
 
 		// Register two documents
@@ -337,8 +337,8 @@ func TestDocumentRegistry_GetRelativePath(t *testing.T) {
 			t.Parallel()
 
 			r := &DocumentRegistry{
-				docs:          make(map[string]*DocumentState),
-				workspaceRoot: tt.workspaceRoot,
+				docs:           make(map[string]*DocumentState),
+				workspaceRoots: []string{tt.workspaceRoot},
 			}
 
 			result := r.GetRelativePath(tt.uri)
@@ -359,8 +359,8 @@ func TestDocumentRegistry_GetFileURI(t *testing.T) {
 		t.Parallel()
 
 		r := &DocumentRegistry{
-			docs:          make(map[string]*DocumentState),
-			workspaceRoot: "/home/user/project",
+			docs:           make(map[string]*DocumentState),
+			workspaceRoots: []string{"/home/user/project"},
 		}
 
 		uri := "file:///home/user/project/docs/readme.md"
@@ -374,8 +374,8 @@ func TestDocumentRegistry_GetFileURI(t *testing.T) {
 		t.Parallel()
 
 		r := &DocumentRegistry{
-			docs:          make(map[string]*DocumentState),
-			workspaceRoot: "/home/user/project",
+			docs:           make(map[string]*DocumentState),
+			workspaceRoots: []string{"/home/user/project"},
 		}
 
 		result := r.GetFileURI("/docs/other.md")
@@ -386,8 +386,8 @@ func TestDocumentRegistry_GetFileURI(t *testing.T) {
 		t.Parallel()
 
 		r := &DocumentRegistry{
-			docs:          make(map[string]*DocumentState),
-			workspaceRoot: "/home/user/project",
+			docs:           make(map[string]*DocumentState),
+			workspaceRoots: []string{"/home/user/project"},
 		}
 
 		result := r.GetFileURI("docs/file.md")
@@ -395,13 +395,16 @@ func TestDocumentRegistry_GetFileURI(t *testing.T) {
 	})
 }
 
-func TestDocumentRegistry_GetWorkspaceRoot(t *testing.T) {
+func TestDocumentRegistry_GetWorkspaceRoots(t *testing.T) {
 	t.Parallel()
 
 	r := &DocumentRegistry{
-		docs:          make(map[string]*DocumentState),
-		workspaceRoot: "/some/path",
+		docs:           make(map[string]*DocumentState),
+		workspaceRoots: []string{"/some/path"},
 	}
 
-	assert.Equal(t, "/some/path", r.GetWorkspaceRoot())
+	assert.Equal(t, []string{"/some/path"}, r.GetWorkspaceRoots())
+
+	r.SetWorkspaceRoots([]string{"/some/path", "/other/path"})
+	assert.Equal(t, []string{"/some/path", "/other/path"}, r.GetWorkspaceRoots())
 }
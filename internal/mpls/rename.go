@@ -0,0 +1,121 @@
+package mpls
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mhersson/mpls/pkg/parser"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+var errNoHeading = errors.New("only headings can be renamed")
+
+func TextDocumentPrepareRename(_ *glsp.Context, params *protocol.PrepareRenameParams) (any, error) {
+	uri := params.TextDocument.URI
+	if !slices.Contains(validFileExtensions, filepath.Ext(uri)) {
+		return nil, nil
+	}
+
+	content, err := documentContent(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := newLineMap(content)
+
+	heading, ok := parser.Parse(content).HeadingAt(lines.offset(params.Position))
+	if !ok {
+		return nil, nil
+	}
+
+	return protocol.RangeWithPlaceholder{
+		Range:       lines.rangeOf(heading.TextSpan),
+		Placeholder: heading.Text,
+	}, nil
+}
+
+func TextDocumentRename(_ *glsp.Context, params *protocol.RenameParams) (*protocol.WorkspaceEdit, error) {
+	uri := params.TextDocument.URI
+	if !slices.Contains(validFileExtensions, filepath.Ext(uri)) {
+		return nil, nil
+	}
+
+	content, err := documentContent(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	return renameHeading(workspaceIndex, uri, content, params.Position, params.NewName)
+}
+
+// renameHeading replaces the text of the heading at pos with newName and
+// rewrites the fragment of every link in the workspace that targets it to
+// the heading's new slug.
+func renameHeading(index *WorkspaceIndex, uri, content string, pos protocol.Position, newName string) (*protocol.WorkspaceEdit, error) {
+	newName = strings.TrimSpace(newName)
+	if newName == "" || strings.ContainsAny(newName, "\r\n") {
+		return nil, errors.New("heading text must be a single non-empty line")
+	}
+
+	doc := parser.Parse(content)
+	lines := newLineMap(content)
+
+	heading, ok := doc.HeadingAt(lines.offset(pos))
+	if !ok {
+		return nil, errNoHeading
+	}
+
+	// Render the new heading the same way the preview will to get its slug,
+	// including any suffix needed to keep it unique
+	renamed, ok := parser.Parse(content[:heading.TextSpan.Start] + newName + content[heading.TextSpan.Stop:]).
+		HeadingAt(heading.Span.Start)
+	if !ok {
+		return nil, errNoHeading
+	}
+
+	changes := map[protocol.DocumentUri][]protocol.TextEdit{
+		uri: {{Range: lines.rangeOf(heading.TextSpan), NewText: newName}},
+	}
+
+	for _, ref := range index.LinksTo(parser.NormalizePath(uri)) {
+		if ref.Target.Fragment == "" {
+			continue
+		}
+
+		if h, ok := doc.FindHeading(ref.Target.Fragment); !ok || h.Span != heading.Span {
+			continue
+		}
+
+		fragment := renamed.ID
+		if ref.Link.Kind == parser.LinkWiki && ref.Target.Fragment != heading.ID {
+			// Wikilinks usually refer to headings by their text
+			fragment = renamed.Text
+		}
+
+		if edit, ok := fragmentEdit(ref, fragment); ok {
+			changes[ref.Doc.URI] = append(changes[ref.Doc.URI], edit)
+		}
+	}
+
+	return &protocol.WorkspaceEdit{Changes: changes}, nil
+}
+
+// fragmentEdit replaces the "#fragment" part of a link destination as written
+// in the source. Reference-style links have no destination of their own; their
+// definitions are rewritten instead.
+func fragmentEdit(ref linkReference, fragment string) (protocol.TextEdit, bool) {
+	span := ref.Link.DestSpan
+
+	idx := strings.Index(ref.Doc.Content[span.Start:span.Stop], "#")
+	if span.Start == span.Stop || idx == -1 {
+		return protocol.TextEdit{}, false
+	}
+
+	return protocol.TextEdit{
+		Range:   ref.Doc.Lines.rangeOf(parser.Span{Start: span.Start + idx + 1, Stop: span.Stop}),
+		NewText: fragment,
+	}, true
+}
@@ -0,0 +1,94 @@
+package mpls
+
+import (
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// applyEdits applies non-overlapping text edits to content.
+func applyEdits(content string, edits []protocol.TextEdit) string {
+	lines := newLineMap(content)
+
+	sorted := append([]protocol.TextEdit(nil), edits...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return lines.offset(sorted[i].Range.Start) > lines.offset(sorted[j].Range.Start)
+	})
+
+	for _, e := range sorted {
+		content = content[:lines.offset(e.Range.Start)] + e.NewText + content[lines.offset(e.Range.End):]
+	}
+
+	return content
+}
+
+func TestRenameHeading(t *testing.T) {
+	t.Parallel()
+
+	guide := "# Guide\n\nSee [rollback](#rollback).\n\n## Rollback\n\n## Deploy steps\n"
+
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"guide.md": guide,
+		"index.md": "[Rollback](guide.md#rollback \"title\")\n[Deploy](guide.md#deploy-steps)\n\n" +
+			"[ref]: guide.md#rollback\n",
+		"sub/notes.md": "[Back](../guide.md#rollback) and [top](../guide.md).\n",
+	})
+
+	index, _ := newTestIndex(root)
+	uri := fileURI(filepath.Join(root, "guide.md"))
+
+	edit, err := renameHeading(index, uri, guide, protocol.Position{Line: 4, Character: 4}, "Rolling back")
+	require.NoError(t, err)
+	require.Len(t, edit.Changes, 3)
+
+	assert.Equal(t, "# Guide\n\nSee [rollback](#rolling-back).\n\n## Rolling back\n\n## Deploy steps\n",
+		applyEdits(guide, edit.Changes[uri]))
+
+	indexURI := fileURI(filepath.Join(root, "index.md"))
+	assert.Equal(t, "[Rollback](guide.md#rolling-back \"title\")\n[Deploy](guide.md#deploy-steps)\n\n"+
+		"[ref]: guide.md#rolling-back\n",
+		applyEdits(index.docs[filepath.Join(root, "index.md")].Content, edit.Changes[indexURI]))
+
+	notesURI := fileURI(filepath.Join(root, "sub/notes.md"))
+	assert.Equal(t, "[Back](../guide.md#rolling-back) and [top](../guide.md).\n",
+		applyEdits(index.docs[filepath.Join(root, "sub/notes.md")].Content, edit.Changes[notesURI]))
+}
+
+func TestRenameHeading_DuplicateSlug(t *testing.T) {
+	t.Parallel()
+
+	content := "## Setup\n\n## Install\n\n[Install](#install)\n"
+
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"a.md": content})
+
+	index, _ := newTestIndex(root)
+	uri := fileURI(filepath.Join(root, "a.md"))
+
+	edit, err := renameHeading(index, uri, content, protocol.Position{Line: 2, Character: 3}, "Setup")
+	require.NoError(t, err)
+	assert.Equal(t, "## Setup\n\n## Setup\n\n[Install](#setup-1)\n", applyEdits(content, edit.Changes[uri]))
+}
+
+func TestRenameHeading_Errors(t *testing.T) {
+	t.Parallel()
+
+	content := "# Title\n\nText\n"
+
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"a.md": content})
+
+	index, _ := newTestIndex(root)
+	uri := fileURI(filepath.Join(root, "a.md"))
+
+	_, err := renameHeading(index, uri, content, protocol.Position{Line: 2, Character: 0}, "New")
+	require.ErrorIs(t, err, errNoHeading)
+
+	_, err = renameHeading(index, uri, content, protocol.Position{Line: 0, Character: 2}, "  ")
+	require.Error(t, err)
+}
@@ -0,0 +1,336 @@
+package mpls
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mhersson/mpls/internal/previewserver"
+	"github.com/mhersson/mpls/pkg/linkcheck"
+	"github.com/mhersson/mpls/pkg/parser"
+	"github.com/mhersson/mpls/pkg/plantuml"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// DefaultRenderTimeout is the default time budget for rendering a document.
+const DefaultRenderTimeout = 10 * time.Second
+
+// RenderTimeout is the time budget for rendering a document, including its
+// PlantUML diagrams. A document that takes longer is shown with a banner in
+// the preview. Zero disables the budget.
+var RenderTimeout = DefaultRenderTimeout
+
+// MatchPlantUMLTheme styles PlantUML diagrams to match the preview theme,
+// for example with reversed colors for dark themes.
+var MatchPlantUMLTheme bool
+
+// errRenderTimeout is returned with the banner of a document that could not
+// be rendered in time.
+var errRenderTimeout = errors.New("render timed out")
+
+// Render workers, by document URI. Each document is rendered by at most one
+// worker at a time. A request with new content cancels the running render,
+// and the requests that arrive while the worker is busy are merged into one,
+// so that only the latest content is rendered next.
+var (
+	renderWorkers      = make(map[string]*renderWorker)
+	renderWorkersMutex sync.Mutex
+)
+
+type renderWorker struct {
+	pending   *renderRequest
+	rendering *renderRequest // The request being rendered
+	cancel    context.CancelFunc
+}
+
+// renderRequest asks for a document to be rendered.
+type renderRequest struct {
+	content    string
+	changeLine int  // Source line of the latest change, 0 compares with the previous render
+	generate   bool // Request the missing PlantUML diagrams
+	preview    bool // Send the rendered document to the preview
+	diagnose   bool // Publish the diagnostics of the document
+	// done is called with the rendered document once it is stored.
+	done []func(renderedDocument)
+}
+
+// merge returns the request that does the work of r followed by next.
+func (r *renderRequest) merge(next renderRequest) *renderRequest {
+	return &renderRequest{
+		content:    next.content,
+		changeLine: next.changeLine,
+		generate:   r.generate || next.generate,
+		preview:    r.preview || next.preview,
+		diagnose:   r.diagnose || next.diagnose,
+		done:       append(r.done, next.done...),
+	}
+}
+
+// renderSettings are the settings that documents are rendered and checked
+// with. Renders and link checks run on their own goroutines, so they use a
+// copy of the package settings, taken on the LSP goroutine, which is the
+// only one that changes those.
+type renderSettings struct {
+	renderer *parser.Renderer
+	plantUML *plantuml.Client
+	timeout  time.Duration
+	checker  *linkcheck.Checker
+}
+
+var (
+	activeRenderSettings *renderSettings
+	renderSettingsMutex  sync.RWMutex
+)
+
+// updateRenderSettings copies the package settings for renders and link
+// checks, and passes them on to the preview server. It is called on the LSP
+// goroutine whenever the settings or workspace roots change.
+func updateRenderSettings() {
+	s := &renderSettings{
+		renderer: parser.DefaultRenderer(),
+		plantUML: plantuml.DefaultClient(),
+		timeout:  RenderTimeout,
+		checker:  linkChecker,
+	}
+
+	renderSettingsMutex.Lock()
+	activeRenderSettings = s
+	renderSettingsMutex.Unlock()
+
+	if previewServer != nil {
+		previewServer.SetRenderers(s.renderer, s.plantUML)
+	}
+}
+
+// currentRenderSettings returns the settings for renders and link checks,
+// copying the package settings if they have not been copied yet.
+func currentRenderSettings() *renderSettings {
+	renderSettingsMutex.RLock()
+	s := activeRenderSettings
+	renderSettingsMutex.RUnlock()
+
+	if s == nil {
+		updateRenderSettings()
+
+		return currentRenderSettings()
+	}
+
+	return s
+}
+
+// renderedDocument is a document rendered for the preview.
+type renderedDocument struct {
+	HTML      string
+	Meta      map[string]any
+	PlantUMLs []plantuml.Plantuml
+}
+
+// scheduleRender renders the document at uri in the background. The
+// request is merged with the one waiting for the running render to end, if
+// any, and the running render is cancelled if it is of other content. Once
+// rendered, the document is stored in the registry and, as asked
+// for, the preview is updated and the diagnostics are published.
+func scheduleRender(ctx *glsp.Context, uri string, req renderRequest) {
+	renderWorkersMutex.Lock()
+	defer renderWorkersMutex.Unlock()
+
+	w, running := renderWorkers[uri]
+	if !running {
+		w = &renderWorker{}
+		renderWorkers[uri] = w
+	}
+
+	if w.pending != nil {
+		w.pending = w.pending.merge(req)
+	} else {
+		w.pending = &req
+	}
+
+	// The running render is superseded, run will render it with the pending
+	// request
+	if w.rendering != nil && w.rendering.content != req.content {
+		w.cancel()
+	}
+
+	if !running {
+		go w.run(ctx, uri)
+	}
+}
+
+// cancelRender cancels the render of uri that is running, if any, and drops
+// the one waiting.
+func cancelRender(uri string) {
+	renderWorkersMutex.Lock()
+	defer renderWorkersMutex.Unlock()
+
+	if w, ok := renderWorkers[uri]; ok {
+		w.pending = nil
+		if w.cancel != nil {
+			w.cancel()
+		}
+
+		delete(renderWorkers, uri)
+	}
+}
+
+// next takes the waiting request, or ends the worker if there is none or it
+// has been cancelled.
+func (w *renderWorker) next(uri string) (*renderRequest, context.Context, bool) {
+	renderWorkersMutex.Lock()
+	defer renderWorkersMutex.Unlock()
+
+	// Release the context of the previous render
+	if w.cancel != nil {
+		w.cancel()
+	}
+
+	w.rendering = nil
+
+	if renderWorkers[uri] != w {
+		return nil, nil, false
+	}
+
+	req := w.pending
+	if req == nil {
+		delete(renderWorkers, uri)
+
+		return nil, nil, false
+	}
+
+	w.pending = nil
+	w.rendering = req
+
+	var ctx context.Context
+
+	ctx, w.cancel = context.WithCancel(context.Background())
+
+	return req, ctx, true
+}
+
+// supersede hands the work of a cancelled render on to the pending request,
+// so that its callbacks and flags are not lost. It does nothing when the
+// document was closed.
+func (w *renderWorker) supersede(uri string, req *renderRequest) {
+	renderWorkersMutex.Lock()
+	defer renderWorkersMutex.Unlock()
+
+	w.rendering = nil
+
+	if renderWorkers[uri] != w || w.pending == nil {
+		return
+	}
+
+	w.pending = req.merge(*w.pending)
+}
+
+func (w *renderWorker) run(ctx *glsp.Context, uri string) {
+	for {
+		req, renderCtx, ok := w.next(uri)
+		if !ok {
+			return
+		}
+
+		var plantUMLs []plantuml.Plantuml
+		if docState, exists := documentRegistry.Get(uri); exists {
+			plantUMLs = docState.PlantUMLs
+		}
+
+		rendered, err := renderDocument(renderCtx, uri, req.content, req.changeLine, req.generate, plantUMLs)
+		if renderCtx.Err() != nil {
+			// Superseded by newer content, or the document was closed
+			w.supersede(uri, req)
+
+			continue
+		}
+
+		if err != nil {
+			_ = protocol.Trace(ctx, protocol.MessageTypeWarning, log("Render: "+err.Error()))
+		}
+
+		documentRegistry.SetRendered(uri, rendered.HTML, rendered.Meta)
+
+		if req.generate {
+			documentRegistry.SetPlantUMLs(uri, rendered.PlantUMLs)
+		}
+
+		if req.preview {
+			// Set documentURI based on mode
+			documentURI := ""
+			if previewserver.EnableTabs {
+				documentURI = documentRegistry.GetRelativePath(uri)
+				if documentURI == "" {
+					documentURI = "/"
+				}
+			}
+
+			previewServer.UpdateWithURI(uri, documentURI, rendered.HTML, rendered.Meta)
+		}
+
+		if req.diagnose {
+			publishDiagnostics(ctx, uri, req.content)
+		}
+
+		for _, done := range req.done {
+			done(rendered)
+		}
+	}
+}
+
+// renderDocument renders content for the preview and inserts its PlantUML
+// diagrams, requesting the ones missing from plantUMLs when generate is set.
+// When the render runs out of time the HTML is a banner saying so, followed
+// by the document with the diagrams that were ready if only diagrams were
+// late, or else by the last version of the document that was rendered, and
+// the error is errRenderTimeout. Diagram errors are returned with the
+// rendered document. When ctx is cancelled the error is ctx.Err().
+func renderDocument(ctx context.Context, uri, content string, changeLine int, generate bool,
+	plantUMLs []plantuml.Plantuml,
+) (renderedDocument, error) {
+	settings := currentRenderSettings()
+	budget := ctx
+
+	if settings.timeout > 0 {
+		var cancel context.CancelFunc
+
+		budget, cancel = context.WithTimeout(ctx, settings.timeout)
+		defer cancel()
+	}
+
+	rendered := renderedDocument{PlantUMLs: plantUMLs}
+
+	var err error
+
+	rendered.HTML, rendered.Meta, err = settings.renderer.HTML(budget, content, uri, changeLine)
+	if err == nil {
+		rendered.HTML, rendered.PlantUMLs, err = settings.plantUML.InsertDiagrams(budget, rendered.HTML, generate, plantUMLs)
+	} else if budget.Err() != nil {
+		// Keep the last version that was rendered, without its banner
+		rendered.HTML, rendered.Meta = documentRegistry.Rendered(uri)
+		rendered.HTML = strings.TrimPrefix(rendered.HTML, timeoutBanner(settings.timeout))
+	}
+
+	if ctx.Err() != nil {
+		return rendered, ctx.Err()
+	}
+
+	if errors.Is(budget.Err(), context.DeadlineExceeded) {
+		rendered.HTML = timeoutBanner(settings.timeout) + rendered.HTML
+
+		return rendered, errRenderTimeout
+	}
+
+	return rendered, err
+}
+
+// timeoutBanner returns the banner shown for a document that could not be
+// rendered in time.
+func timeoutBanner(timeout time.Duration) string {
+	return fmt.Sprintf(`<div class="markdown-alert markdown-alert-caution mpls-render-timeout">`+
+		`<p class="markdown-alert-title">Render timed out</p>`+
+		`<p>The document took longer than %s to render. Increase <code>renderTimeout</code> to allow more time.</p>`+
+		`</div>`, timeout)
+}
@@ -0,0 +1,208 @@
+package mpls
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mhersson/mpls/internal/config"
+	"github.com/mhersson/mpls/pkg/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleRender_Merges(t *testing.T) {
+	t.Parallel()
+
+	uri := "file:///test/merged.md"
+
+	// A worker that is busy rendering
+	renderWorkersMutex.Lock()
+	renderWorkers[uri] = &renderWorker{}
+	renderWorkersMutex.Unlock()
+
+	scheduleRender(nil, uri, renderRequest{content: "first", generate: true, done: []func(renderedDocument){func(renderedDocument) {}}})
+	scheduleRender(nil, uri, renderRequest{content: "second", changeLine: 3, preview: true, done: []func(renderedDocument){func(renderedDocument) {}}})
+
+	renderWorkersMutex.Lock()
+	pending := renderWorkers[uri].pending
+	renderWorkersMutex.Unlock()
+
+	require.NotNil(t, pending)
+	assert.Equal(t, "second", pending.content, "the latest content is rendered")
+	assert.Equal(t, 3, pending.changeLine)
+	assert.True(t, pending.generate)
+	assert.True(t, pending.preview)
+	assert.Len(t, pending.done, 2)
+
+	cancelRender(uri)
+
+	renderWorkersMutex.Lock()
+	_, running := renderWorkers[uri]
+	renderWorkersMutex.Unlock()
+
+	assert.False(t, running)
+}
+
+func TestScheduleRender_CancelsSuperseded(t *testing.T) {
+	t.Parallel()
+
+	uri := "file:///test/superseded.md"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	running := &renderRequest{content: "old", generate: true, done: []func(renderedDocument){func(renderedDocument) {}}}
+
+	// A worker that is busy rendering running
+	w := &renderWorker{rendering: running, cancel: cancel}
+
+	renderWorkersMutex.Lock()
+	renderWorkers[uri] = w
+	renderWorkersMutex.Unlock()
+
+	t.Cleanup(func() { cancelRender(uri) })
+
+	scheduleRender(nil, uri, renderRequest{content: "old"})
+	require.NoError(t, ctx.Err(), "the same content does not cancel the render")
+
+	scheduleRender(nil, uri, renderRequest{content: "new", preview: true})
+	require.ErrorIs(t, ctx.Err(), context.Canceled, "new content cancels the render")
+
+	// The cancelled render is rendered with the pending request
+	w.supersede(uri, running)
+
+	renderWorkersMutex.Lock()
+	pending := w.pending
+	renderWorkersMutex.Unlock()
+
+	assert.Equal(t, "new", pending.content)
+	assert.True(t, pending.generate)
+	assert.True(t, pending.preview)
+	assert.Len(t, pending.done, 1)
+}
+
+func TestScheduleRender(t *testing.T) { //nolint:paralleltest // Modifies the global document registry
+	setupWorkspace(t, nil)
+
+	uri := "file:///test/scheduled.md"
+	documentRegistry.Register(uri, &DocumentState{Content: "# Title\n"})
+
+	done := make(chan renderedDocument, 1)
+
+	scheduleRender(nil, uri, renderRequest{
+		content: "# Title\n",
+		done:    []func(renderedDocument){func(rendered renderedDocument) { done <- rendered }},
+	})
+
+	select {
+	case rendered := <-done:
+		assert.Contains(t, rendered.HTML, "Title</h1>")
+	case <-time.After(10 * time.Second):
+		require.FailNow(t, "the document was not rendered")
+	}
+
+	html, _ := documentRegistry.Rendered(uri)
+	assert.Contains(t, html, "Title</h1>", "the rendered document is stored")
+
+	assert.Eventually(t, func() bool {
+		renderWorkersMutex.Lock()
+		defer renderWorkersMutex.Unlock()
+
+		_, running := renderWorkers[uri]
+
+		return !running
+	}, time.Second, 10*time.Millisecond, "the worker ends when there is nothing left to render")
+}
+
+func TestRenderDocument(t *testing.T) {
+	t.Parallel()
+
+	rendered, err := renderDocument(context.Background(), "file:///test/render.md", "# Title\n", 0, false, nil)
+	require.NoError(t, err)
+
+	assert.Contains(t, rendered.HTML, "Title</h1>")
+	assert.NotContains(t, rendered.HTML, "mpls-render-timeout")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = renderDocument(ctx, "file:///test/render.md", "# Title\n", 0, false, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRenderDocument_Timeout(t *testing.T) { //nolint:paralleltest // Modifies the global render timeout
+	oldTimeout := RenderTimeout
+	RenderTimeout = 1
+
+	updateRenderSettings()
+
+	t.Cleanup(func() {
+		RenderTimeout = oldTimeout
+
+		updateRenderSettings()
+	})
+
+	setupWorkspace(t, nil)
+
+	uri := "file:///test/timeout.md"
+
+	rendered, err := renderDocument(context.Background(), uri, "# Title\n", 0, false, nil)
+	require.ErrorIs(t, err, errRenderTimeout)
+
+	assert.Contains(t, rendered.HTML, "mpls-render-timeout")
+	assert.Contains(t, rendered.HTML, "Render timed out")
+
+	// The last version that was rendered is kept below the banner
+	documentRegistry.Register(uri, &DocumentState{Content: "# Title\n"})
+	documentRegistry.SetRendered(uri, timeoutBanner(RenderTimeout)+"<h1>Title</h1>", map[string]any{"title": "Title"})
+
+	rendered, err = renderDocument(context.Background(), uri, "# Title changed\n", 0, false, nil)
+	require.ErrorIs(t, err, errRenderTimeout)
+
+	assert.Equal(t, timeoutBanner(RenderTimeout)+"<h1>Title</h1>", rendered.HTML, "banners do not pile up")
+	assert.Equal(t, "Title", rendered.Meta["title"])
+}
+
+func TestRenderSettings(t *testing.T) { //nolint:paralleltest // Modifies global parser settings
+	emoji := parser.EnableEmoji
+
+	t.Cleanup(func() {
+		parser.EnableEmoji = emoji
+
+		parser.ResetExtensions()
+		updateRenderSettings()
+	})
+
+	parser.EnableEmoji = false
+
+	updateRenderSettings()
+
+	// Renders in the background use the settings copied on the LSP
+	// goroutine while the package settings change
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for range 20 {
+			rendered, err := renderDocument(context.Background(), "file:///test/settings.md", ":smile:\n", 0, false, nil)
+			assert.NoError(t, err)
+			assert.Contains(t, rendered.HTML, ":smile:")
+		}
+	}()
+
+	for range 20 {
+		enabled := true
+		updateSettings(config.Settings{EnableEmoji: &enabled})
+		parser.EnableEmoji = false
+	}
+
+	<-done
+
+	parser.EnableEmoji = true
+
+	updateRenderSettings()
+
+	rendered, err := renderDocument(context.Background(), "file:///test/settings.md", ":smile:\n", 0, false, nil)
+	require.NoError(t, err)
+	assert.NotContains(t, rendered.HTML, ":smile:", "new settings apply once copied")
+}
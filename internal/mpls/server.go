@@ -2,11 +2,12 @@ package mpls
 
 import (
 	"context"
-	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/mhersson/mpls/internal/config"
 	"github.com/mhersson/mpls/internal/previewserver"
+	"github.com/mhersson/mpls/pkg/linkcheck"
 	"github.com/mhersson/mpls/pkg/parser"
 	"github.com/mhersson/mpls/pkg/plantuml"
 	"github.com/tliron/glsp"
@@ -23,7 +24,7 @@ const lsName = "Markdown Preview Language Server"
 var (
 	TextDocumentUseFullSync bool
 	Version                 string
-	workspaceRoot           string
+	workspaceRoots          []string
 	serverCtx               context.Context
 	serverCancel            context.CancelFunc
 )
@@ -39,9 +40,6 @@ func Run() {
 	serverCtx = ctx
 	serverCancel = cancel
 
-	previewServer = previewserver.New()
-	go previewServer.Start()
-
 	lspServer := serverPkg.NewServer(&Handler, lsName, false)
 
 	_ = lspServer.RunStdio()
@@ -52,24 +50,66 @@ func initialize(context *glsp.Context, params *protocol.InitializeParams) (any,
 
 	_ = protocol.Trace(context, protocol.MessageTypeInfo, log("Initializing "+lsName))
 
-	// Extract workspace root
+	// Extract workspace roots
 	switch {
 	case len(params.WorkspaceFolders) > 0:
-		workspaceRoot = parser.NormalizePath(params.WorkspaceFolders[0].URI)
+		for _, folder := range params.WorkspaceFolders {
+			workspaceRoots = append(workspaceRoots, parser.NormalizePath(folder.URI))
+		}
 	case params.RootURI != nil:
-		workspaceRoot = parser.NormalizePath(*params.RootURI)
+		workspaceRoots = []string{parser.NormalizePath(*params.RootURI)}
 	case params.RootPath != nil:
-		workspaceRoot = parser.NormalizePath(*params.RootPath)
+		workspaceRoots = []string{parser.NormalizePath(*params.RootPath)}
+	}
+
+	// Configuration files take precedence over command-line flags. The
+	// project file is read from the first workspace folder.
+	settings, err := LoadSettings(workspaceRoots)
+	if err != nil {
+		_ = protocol.Trace(context, protocol.MessageTypeWarning, log("Initialize - "+err.Error()))
+	}
+
+	// initializationOptions take precedence over configuration files
+	if options, err := config.Decode(params.InitializationOptions); err != nil {
+		_ = protocol.Trace(context, protocol.MessageTypeWarning, log("Initialize - "+err.Error()))
+	} else {
+		settings.Merge(options)
+	}
+
+	applyStartupSettings(settings)
+
+	if err := openDiskCache(); err != nil {
+		_ = protocol.Trace(context, protocol.MessageTypeWarning, log("Initialize - disk cache: "+err.Error()))
 	}
 
-	// Initialize document registry with workspace root
-	InitializeDocumentRegistry(workspaceRoot)
+	// The preview server is started once its port and theme are known
+	previewServer = previewserver.New()
+	go previewServer.Start()
+
+	// Initialize document registry with workspace roots
+	InitializeDocumentRegistry(workspaceRoots)
+
+	// Index all Markdown files in the workspace for cross-document features
+	InitializeWorkspaceIndex(workspaceRoots, documentRegistry)
 
-	// Pass workspace root to preview server
-	previewServer.SetWorkspaceRoot(workspaceRoot)
+	// Wikilinks resolve against the files of the index
+	parser.SetNotes(func() []string {
+		return workspaceIndex.Files(validFileExtensions)
+	})
+
+	watchFiles = canWatchFiles(params.Capabilities)
+
+	// Pass workspace roots to preview server
+	previewServer.SetWorkspaceRoots(workspaceRoots)
+
+	// Set workspace roots for parser link resolution
+	parser.SetWorkspaceRoots(workspaceRoots)
+
+	if CheckExternalLinks {
+		linkChecker = linkcheck.New(linkcheck.Options{Timeout: ExternalLinkTimeout})
+	}
 
-	// Set workspace root for parser link resolution
-	parser.WorkspaceRoot = workspaceRoot
+	updateRenderSettings()
 
 	capabilities := Handler.CreateServerCapabilities()
 	if TextDocumentUseFullSync {
@@ -78,6 +118,28 @@ func initialize(context *glsp.Context, params *protocol.InitializeParams) (any,
 
 	capabilities.ExecuteCommandProvider.Commands = []string{"open-preview"}
 
+	capabilities.CompletionProvider.TriggerCharacters = completionTriggers
+
+	// Let clients ask whether the cursor is on a heading before renaming
+	capabilities.RenameProvider = protocol.RenameOptions{PrepareProvider: boolPtr(true)}
+
+	// Folders can be added to and removed from the workspace
+	capabilities.Workspace.WorkspaceFolders = &protocol.WorkspaceFoldersServerCapabilities{
+		Supported:           boolPtr(true),
+		ChangeNotifications: &protocol.BoolOrString{Value: true},
+	}
+
+	// Links can point at any kind of file, and whole directories can be moved
+	allFiles := []protocol.FileOperationFilter{
+		{Pattern: protocol.FileOperationPattern{Glob: "**/*"}},
+	}
+	capabilities.Workspace.FileOperations.WillRename.Filters = allFiles
+
+	// File operations change the files the workspace index lists
+	capabilities.Workspace.FileOperations.DidCreate.Filters = allFiles
+	capabilities.Workspace.FileOperations.DidRename.Filters = allFiles
+	capabilities.Workspace.FileOperations.DidDelete.Filters = allFiles
+
 	return protocol.InitializeResult{
 		Capabilities: capabilities,
 		ServerInfo: &protocol.InitializeResultServerInfo{
@@ -91,6 +153,10 @@ func initialized(ctx *glsp.Context, _ *protocol.InitializedParams) error {
 	// Start goroutine to handle browser -> LSP -> editor requests
 	startDocumentRequestHandler(ctx)
 
+	if watchFiles {
+		go registerFileWatcher(ctx)
+	}
+
 	return nil
 }
 
@@ -102,7 +168,10 @@ func setTrace(_ *glsp.Context, params *protocol.SetTraceParams) error {
 
 func shutdown(_ *glsp.Context) error {
 	serverCancel() // Signal goroutine to exit
-	previewServer.Stop()
+
+	if previewServer != nil {
+		previewServer.Stop()
+	}
 	protocol.SetTraceValue(protocol.TraceValueOff)
 
 	return nil
@@ -120,12 +189,14 @@ func startDocumentRequestHandler(ctx *glsp.Context) {
 				// Clean exit when server is shutting down
 				return
 			case req := <-previewserver.LSPRequestChan:
-				// Convert workspace-relative path to file:// URI
-				relativePath := req.URI
-				relativePath = strings.TrimPrefix(relativePath, "/")
-
-				// Construct absolute file path
-				fileURI := documentRegistry.GetFileURI("/" + relativePath)
+				fileURI := req.URI
+				if !strings.HasPrefix(fileURI, "file://") {
+					// Convert workspace-relative path to file:// URI
+					relativePath := strings.TrimPrefix(req.URI, "/")
+					if fileURI = documentRegistry.GetFileURI("/" + relativePath); fileURI == "" {
+						continue
+					}
+				}
 
 				// Create ShowDocumentParams
 				params := protocol.ShowDocumentParams{
@@ -134,6 +205,12 @@ func startDocumentRequestHandler(ctx *glsp.Context) {
 					TakeFocus: boolPtr(req.TakeFocus),
 				}
 
+				if req.Line > 0 {
+					// Place the cursor at the start of the 1-based source line
+					line := protocol.Position{Line: protocol.UInteger(req.Line - 1)}
+					params.Selection = &protocol.Range{Start: line, End: line}
+				}
+
 				// Send window/showDocument request to client
 				var result protocol.ShowDocumentResult
 
@@ -145,28 +222,20 @@ func startDocumentRequestHandler(ctx *glsp.Context) {
 
 					// In single-page mode with updatePreview, send WebSocket update
 					if req.UpdatePreview && !previewserver.EnableTabs {
-						docState, exists := documentRegistry.Get(fileURI)
-						if !exists {
+						if _, exists := documentRegistry.Get(fileURI); !exists {
 							// Document not in registry, load from disk
 							content, err := loadDocument(fileURI)
 							if err == nil {
-								html, meta := parser.HTML(content, fileURI, 0)
-								html, _, _ = plantuml.InsertPlantumlDiagram(html, true, []plantuml.Plantuml{})
-
-								docState = &DocumentState{
+								documentRegistry.Register(fileURI, &DocumentState{
 									URI:       fileURI,
 									Content:   content,
-									HTML:      html,
-									Meta:      meta,
 									PlantUMLs: []plantuml.Plantuml{},
-								}
-								documentRegistry.Register(fileURI, docState)
+								})
+								scheduleRender(ctx, fileURI, renderRequest{content: content, generate: true, preview: true})
 							}
-						}
-
-						if docState != nil {
-							filename := filepath.Base(fileURI)
-							previewServer.UpdateWithURI(filename, "", docState.HTML, docState.Meta)
+						} else {
+							html, meta := documentRegistry.Rendered(fileURI)
+							previewServer.UpdateWithURI(fileURI, "", html, meta)
 						}
 					}
 				}
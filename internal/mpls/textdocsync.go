@@ -16,7 +16,7 @@ import (
 
 var (
 	previewServer       *previewserver.Server
-	validFileExtensions = []string{".md", ".markdown", ".mkd", ".mkdn", ".mdwn"}
+	validFileExtensions = parser.MarkdownExtensions
 )
 
 func TextDocumentDidOpen(ctx *glsp.Context, params *protocol.DidOpenTextDocumentParams) error {
@@ -24,39 +24,41 @@ func TextDocumentDidOpen(ctx *glsp.Context, params *protocol.DidOpenTextDocument
 		return nil
 	}
 
-	var err error
-
 	uri := params.TextDocument.URI
 	content := params.TextDocument.Text
 
 	_ = protocol.Trace(ctx, protocol.MessageTypeInfo, log("TextDocumentDidOpen: "+params.TextDocument.URI))
 
-	// Always render HTML (even with --no-auto, so it's ready when user runs open-preview)
-	html, meta := parser.HTML(content, uri, 0)
-
-	var plantUMLs []plantuml.Plantuml
-
-	html, plantUMLs, err = plantuml.InsertPlantumlDiagram(html, true, []plantuml.Plantuml{})
-	if err != nil {
-		_ = protocol.Trace(ctx, protocol.MessageTypeWarning, log("TextDocumentDidOpen - plantuml: "+err.Error()))
-	}
-
-	// Register document in registry with rendered content
+	// Register document in registry, it is rendered in the background
 	docState := &DocumentState{
 		URI:       uri,
 		Content:   content,
-		HTML:      html,
-		Meta:      meta,
-		PlantUMLs: plantUMLs,
+		PlantUMLs: []plantuml.Plantuml{},
 	}
 	documentRegistry.Register(uri, docState)
 
+	checkExternalLinks(ctx, uri, content)
+
+	// Always render HTML (even with --no-auto, so it's ready when user runs open-preview)
+	req := renderRequest{content: content, generate: true, diagnose: true}
+
 	// Check if should auto-open browser
-	if !documentRegistry.ShouldAutoOpen() {
-		// Don't open browser, just register the document
-		return nil
+	if documentRegistry.ShouldAutoOpen() {
+		req.done = append(req.done, func(rendered renderedDocument) {
+			openPreview(ctx, uri, rendered)
+		})
 	}
 
+	scheduleRender(ctx, uri, req)
+
+	return nil
+}
+
+// openPreview opens the preview of a newly opened document in the browser,
+// or shows it in the preview that is already open.
+func openPreview(ctx *glsp.Context, uri string, rendered renderedDocument) {
+	html, meta := rendered.HTML, rendered.Meta
+
 	// Get relative path for URL
 	relativePath := documentRegistry.GetRelativePath(uri)
 	if relativePath == "" {
@@ -67,7 +69,7 @@ func TextDocumentDidOpen(ctx *glsp.Context, params *protocol.DidOpenTextDocument
 		// MULTI-TAB MODE: Open new browser tab at file-specific URL
 		previewURL := fmt.Sprintf("http://localhost:%d%s", previewServer.Port, relativePath)
 
-		err = previewserver.Openbrowser(previewURL, previewserver.Browser)
+		err := previewserver.Openbrowser(previewURL, previewserver.Browser)
 		if err != nil {
 			_ = protocol.Trace(ctx, protocol.MessageTypeWarning, log("TextDocumentDidOpen - failed to open browser: "+err.Error()))
 		}
@@ -76,7 +78,7 @@ func TextDocumentDidOpen(ctx *glsp.Context, params *protocol.DidOpenTextDocument
 		// since HTTP serving cannot resolve the file path
 		if relativePath == "/" {
 			if err := previewserver.WaitForClients(2 * time.Second); err == nil {
-				previewServer.UpdateWithURI(filepath.Base(uri), "", html, meta)
+				previewServer.UpdateWithURI(uri, "", html, meta)
 			}
 		}
 	} else {
@@ -85,22 +87,20 @@ func TextDocumentDidOpen(ctx *glsp.Context, params *protocol.DidOpenTextDocument
 			// No browser open yet - open at root
 			previewURL := fmt.Sprintf("http://localhost:%d/", previewServer.Port)
 
-			err = previewserver.Openbrowser(previewURL, previewserver.Browser)
+			err := previewserver.Openbrowser(previewURL, previewserver.Browser)
 			if err != nil {
 				_ = protocol.Trace(ctx, protocol.MessageTypeWarning, log("TextDocumentDidOpen - failed to open browser: "+err.Error()))
 			}
 
 			// Wait for WebSocket connection and send initial content
 			if err := previewserver.WaitForClients(2 * time.Second); err == nil {
-				previewServer.UpdateWithURI(filepath.Base(uri), "", html, meta)
+				previewServer.UpdateWithURI(uri, "", html, meta)
 			}
 		} else {
 			// Browser already open - send update via WebSocket
-			previewServer.UpdateWithURI(filepath.Base(uri), "", html, meta)
+			previewServer.UpdateWithURI(uri, "", html, meta)
 		}
 	}
-
-	return nil
 }
 
 func TextDocumentDidChange(ctx *glsp.Context, params *protocol.DidChangeTextDocumentParams) error {
@@ -108,10 +108,7 @@ func TextDocumentDidChange(ctx *glsp.Context, params *protocol.DidChangeTextDocu
 		return nil
 	}
 
-	var err error
-
 	uri := params.TextDocument.URI
-	filename := filepath.Base(uri)
 
 	// Get document state from registry
 	docState, exists := documentRegistry.Get(uri)
@@ -133,60 +130,31 @@ func TextDocumentDidChange(ctx *glsp.Context, params *protocol.DidChangeTextDocu
 			log("TextDocumentUriDidChange - loaded new document: "+uri))
 	}
 
-	// Get relative path for URI filtering
-	relativePath := documentRegistry.GetRelativePath(uri)
-	if relativePath == "" {
-		relativePath = "/"
-	}
+	// Use line-based targeting of the scroll anchor when the last change has
+	// a range, and the content diff fallback otherwise
+	changeLine := 0
+
+	content := docState.Content
 
 	for _, change := range params.ContentChanges {
 		if c, ok := change.(protocol.TextDocumentContentChangeEvent); ok {
-			startIndex, endIndex := c.Range.IndexesIn(docState.Content)
-			docState.Content = docState.Content[:startIndex] + c.Text + docState.Content[endIndex:]
-
-			// Use line-based targeting: convert 0-based LSP line to 1-based
-			changeLine := int(c.Range.Start.Line) + 1
-			html, meta := parser.HTML(docState.Content, uri, changeLine)
+			startIndex, endIndex := c.Range.IndexesIn(content)
+			content = content[:startIndex] + c.Text + content[endIndex:]
 
-			html, docState.PlantUMLs, err = plantuml.InsertPlantumlDiagram(html, false, docState.PlantUMLs)
-			if err != nil {
-				_ = protocol.Trace(ctx, protocol.MessageTypeWarning, log("TextDocumentDidChange - plantuml: "+err.Error()))
-			}
-
-			docState.HTML = html
-			docState.Meta = meta
-
-			// Set documentURI based on mode
-			documentURI := ""
-			if previewserver.EnableTabs {
-				documentURI = relativePath
-			}
-
-			previewServer.UpdateWithURI(filename, documentURI, html, meta)
+			// Convert 0-based LSP line to 1-based
+			changeLine = int(c.Range.Start.Line) + 1
 		} else if c, ok := change.(protocol.TextDocumentContentChangeEventWhole); ok {
-			docState.Content = c.Text
-
-			// No range info available, use content diff fallback
-			html, meta := parser.HTML(c.Text, uri, 0)
-
-			html, docState.PlantUMLs, err = plantuml.InsertPlantumlDiagram(html, false, docState.PlantUMLs)
-			if err != nil {
-				_ = protocol.Trace(ctx, protocol.MessageTypeWarning, log("TextDocumentDidChange - plantuml: "+err.Error()))
-			}
-
-			docState.HTML = html
-			docState.Meta = meta
-
-			// Set documentURI based on mode
-			documentURI := ""
-			if previewserver.EnableTabs {
-				documentURI = relativePath
-			}
-
-			previewServer.UpdateWithURI(filename, documentURI, html, meta)
+			content = c.Text
+			changeLine = 0
 		}
 	}
 
+	documentRegistry.Update(uri, content)
+
+	// Render in the background so that large documents do not hold up the
+	// editor
+	scheduleRender(ctx, uri, renderRequest{content: content, changeLine: changeLine, preview: true, diagnose: true})
+
 	return nil
 }
 
@@ -195,10 +163,7 @@ func TextDocumentDidSave(ctx *glsp.Context, params *protocol.DidSaveTextDocument
 		return nil
 	}
 
-	var err error
-
 	uri := params.TextDocument.URI
-	filename := filepath.Base(uri)
 
 	// Reload document from disk
 	content, err := loadDocument(uri)
@@ -206,42 +171,18 @@ func TextDocumentDidSave(ctx *glsp.Context, params *protocol.DidSaveTextDocument
 		return err
 	}
 
-	// Get document state from registry
-	docState, exists := documentRegistry.Get(uri)
-	if !exists {
-		docState = &DocumentState{
+	if _, exists := documentRegistry.Content(uri); exists {
+		documentRegistry.Update(uri, content)
+	} else {
+		documentRegistry.Register(uri, &DocumentState{
 			URI:       uri,
+			Content:   content,
 			PlantUMLs: []plantuml.Plantuml{},
-		}
+		})
 	}
 
-	docState.Content = content
-
-	html, meta := parser.HTML(content, uri, 0)
-
-	html, docState.PlantUMLs, err = plantuml.InsertPlantumlDiagram(html, true, docState.PlantUMLs)
-	if err != nil {
-		_ = protocol.Trace(ctx, protocol.MessageTypeWarning, log("TextDocumentDidSave - plantuml: "+err.Error()))
-	}
-
-	docState.HTML = html
-	docState.Meta = meta
-
-	documentRegistry.Register(uri, docState)
-
-	// Get relative path for URI filtering
-	relativePath := documentRegistry.GetRelativePath(uri)
-	if relativePath == "" {
-		relativePath = "/"
-	}
-
-	// Set documentURI based on mode
-	documentURI := ""
-	if previewserver.EnableTabs {
-		documentURI = relativePath
-	}
-
-	previewServer.UpdateWithURI(filename, documentURI, html, meta)
+	scheduleRender(ctx, uri, renderRequest{content: content, generate: true, preview: true, diagnose: true})
+	checkExternalLinks(ctx, uri, content)
 
 	return nil
 }
@@ -261,12 +202,15 @@ func TextDocumentDidClose(ctx *glsp.Context, params *protocol.DidCloseTextDocume
 		relativePath = "/"
 	}
 
-	// 2. Clean up parser cache
+	// 2. Stop rendering and clean up parser cache
+	cancelRender(uri)
 	parser.CleanupDocumentContent(uri)
 
 	// 3. Remove from registry
 	documentRegistry.Remove(uri)
 
+	clearDiagnostics(ctx, uri)
+
 	// 4. Check if last document
 	isLastDocument := documentRegistry.IsEmpty()
 
@@ -276,6 +220,16 @@ func TextDocumentDidClose(ctx *glsp.Context, params *protocol.DidCloseTextDocume
 	return nil
 }
 
+// documentContent returns the editor's version of an open document,
+// falling back to the file on disk.
+func documentContent(uri string) (string, error) {
+	if content, exists := documentRegistry.Content(uri); exists {
+		return content, nil
+	}
+
+	return loadDocument(uri)
+}
+
 func loadDocument(uri string) (string, error) {
 	f := parser.NormalizePath(uri)
 
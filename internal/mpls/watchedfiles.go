@@ -0,0 +1,65 @@
+package mpls
+
+import (
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// watchFiles is set when the client can report changes to the files in the
+// workspace, so that the workspace index can keep its list of files.
+var watchFiles bool
+
+// canWatchFiles reports whether the client supports registering file
+// watchers.
+func canWatchFiles(capabilities protocol.ClientCapabilities) bool {
+	workspace := capabilities.Workspace
+
+	return workspace != nil && workspace.DidChangeWatchedFiles != nil &&
+		workspace.DidChangeWatchedFiles.DynamicRegistration != nil &&
+		*workspace.DidChangeWatchedFiles.DynamicRegistration
+}
+
+// registerFileWatcher asks the client to report files created, changed or
+// deleted in the workspace, and lets the workspace index cache its list of
+// files from then on.
+func registerFileWatcher(ctx *glsp.Context) {
+	kind := protocol.WatchKindCreate | protocol.WatchKindDelete
+
+	params := protocol.RegistrationParams{
+		Registrations: []protocol.Registration{{
+			ID:     "mpls-watched-files",
+			Method: string(protocol.MethodWorkspaceDidChangeWatchedFiles),
+			RegisterOptions: protocol.DidChangeWatchedFilesRegistrationOptions{
+				Watchers: []protocol.FileSystemWatcher{{GlobPattern: "**/*", Kind: &kind}},
+			},
+		}},
+	}
+
+	ctx.Call(protocol.ServerClientRegisterCapability, params, nil)
+
+	workspaceIndex.CacheFiles()
+}
+
+func WorkspaceDidChangeWatchedFiles(_ *glsp.Context, _ *protocol.DidChangeWatchedFilesParams) error {
+	workspaceIndex.InvalidateFiles()
+
+	return nil
+}
+
+func WorkspaceDidCreateFiles(_ *glsp.Context, _ *protocol.CreateFilesParams) error {
+	workspaceIndex.InvalidateFiles()
+
+	return nil
+}
+
+func WorkspaceDidRenameFiles(_ *glsp.Context, _ *protocol.RenameFilesParams) error {
+	workspaceIndex.InvalidateFiles()
+
+	return nil
+}
+
+func WorkspaceDidDeleteFiles(_ *glsp.Context, _ *protocol.DeleteFilesParams) error {
+	workspaceIndex.InvalidateFiles()
+
+	return nil
+}
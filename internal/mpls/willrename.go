@@ -0,0 +1,123 @@
+package mpls
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/mhersson/mpls/pkg/parser"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func WorkspaceWillRenameFiles(_ *glsp.Context, params *protocol.RenameFilesParams) (*protocol.WorkspaceEdit, error) {
+	edit := renameFiles(workspaceIndex, params.Files)
+
+	// The client renames the files next, and reports it with didRenameFiles
+	workspaceIndex.InvalidateFiles()
+	if len(edit.Changes) == 0 {
+		return nil, nil
+	}
+
+	return edit, nil
+}
+
+// fileMoves maps old paths to new paths for a batch of renamed files and
+// directories.
+type fileMoves []protocol.FileRename
+
+// moved returns the new location of path, which is either one of the
+// renamed files itself or a file inside a renamed directory.
+func (m fileMoves) moved(path string) (string, bool) {
+	for _, f := range m {
+		oldPath := parser.NormalizePath(f.OldURI)
+		newPath := parser.NormalizePath(f.NewURI)
+
+		if path == oldPath {
+			return newPath, true
+		}
+
+		if strings.HasPrefix(path, oldPath+string(filepath.Separator)) {
+			return newPath + path[len(oldPath):], true
+		}
+	}
+
+	return path, false
+}
+
+// renameFiles rewrites every relative link, image and <img src> in the
+// workspace that points at a renamed file, as well as the relative links
+// inside the renamed files themselves. Edits refer to the documents by their
+// old URIs since clients apply them before moving the files.
+func renameFiles(index *WorkspaceIndex, files []protocol.FileRename) *protocol.WorkspaceEdit {
+	moves := fileMoves(files)
+	changes := map[protocol.DocumentUri][]protocol.TextEdit{}
+
+	for _, d := range index.Documents() {
+		newDocPath, docMoved := moves.moved(d.Path)
+
+		for _, l := range d.Doc.Links {
+			if l.Kind == parser.LinkAuto || l.Kind == parser.LinkWiki || l.DestSpan.Start == l.DestSpan.Stop {
+				continue
+			}
+
+			raw := d.Content[l.DestSpan.Start:l.DestSpan.Stop]
+
+			target, fragment := parser.ResolveLink(d.URI, raw)
+			if target == "" {
+				continue
+			}
+
+			// Like the preview, absolute <img src> paths are taken as is
+			path, _ := parser.SplitFragment(raw)
+			absolute := l.Kind == parser.LinkHTMLImage && filepath.IsAbs(path)
+
+			if absolute {
+				target = filepath.Clean(path)
+			}
+
+			newTarget, targetMoved := moves.moved(target)
+			if !targetMoved && !docMoved {
+				continue
+			}
+
+			newDest := filepath.ToSlash(newTarget)
+			if !absolute {
+				var ok bool
+				if newDest, ok = relativeDestination(path, filepath.Dir(newDocPath), newTarget); !ok {
+					continue
+				}
+			}
+
+			if fragment != "" {
+				newDest += "#" + fragment
+			}
+
+			if newDest != raw {
+				changes[d.URI] = append(changes[d.URI], protocol.TextEdit{
+					Range:   d.Lines.rangeOf(l.DestSpan),
+					NewText: newDest,
+				})
+			}
+		}
+	}
+
+	return &protocol.WorkspaceEdit{Changes: changes}
+}
+
+// relativeDestination returns the link destination for target as seen from
+// dir, keeping a leading "./" from the original destination.
+func relativeDestination(original, dir, target string) (string, bool) {
+	rel, err := filepath.Rel(dir, target)
+	if err != nil {
+		return "", false
+	}
+
+	rel = filepath.ToSlash(rel)
+
+	if strings.HasPrefix(original, "./") && !strings.HasPrefix(rel, "../") {
+		rel = "./" + rel
+	}
+
+	// Markdown destinations cannot contain spaces
+	return strings.ReplaceAll(rel, " ", "%20"), true
+}
@@ -0,0 +1,104 @@
+package mpls
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestRenameFiles(t *testing.T) {
+	t.Parallel()
+
+	files := map[string]string{
+		"index.md": "[Guide](docs/guide.md#setup)\n![Logo](img/logo.png)\n" +
+			"<img src=\"img/logo.png\" width=\"10\">\n\n[logo]: ./img/logo.png\n",
+		"docs/guide.md": "# Setup\n\n[Home](../index.md) [FAQ](faq.md) [Top](#setup)\n" +
+			"![Logo](../img/logo.png)\n",
+		"docs/faq.md":  "[Guide](guide.md)\n",
+		"img/logo.png": "png",
+	}
+
+	tests := []struct {
+		name     string
+		old, new string
+		expected map[string]string
+	}{
+		{
+			name: "image",
+			old:  "img/logo.png",
+			new:  "assets/brand logo.png",
+			expected: map[string]string{
+				"index.md": "[Guide](docs/guide.md#setup)\n![Logo](assets/brand%20logo.png)\n" +
+					"<img src=\"assets/brand%20logo.png\" width=\"10\">\n\n[logo]: ./assets/brand%20logo.png\n",
+				"docs/guide.md": "# Setup\n\n[Home](../index.md) [FAQ](faq.md) [Top](#setup)\n" +
+					"![Logo](../assets/brand%20logo.png)\n",
+			},
+		},
+		{
+			name: "moved document",
+			old:  "docs/guide.md",
+			new:  "guide.md",
+			expected: map[string]string{
+				"index.md": "[Guide](guide.md#setup)\n![Logo](img/logo.png)\n" +
+					"<img src=\"img/logo.png\" width=\"10\">\n\n[logo]: ./img/logo.png\n",
+				"docs/guide.md": "# Setup\n\n[Home](index.md) [FAQ](docs/faq.md) [Top](#setup)\n" +
+					"![Logo](img/logo.png)\n",
+				"docs/faq.md": "[Guide](../guide.md)\n",
+			},
+		},
+		{
+			name: "directory",
+			old:  "docs",
+			new:  "manual/docs",
+			expected: map[string]string{
+				"index.md": "[Guide](manual/docs/guide.md#setup)\n![Logo](img/logo.png)\n" +
+					"<img src=\"img/logo.png\" width=\"10\">\n\n[logo]: ./img/logo.png\n",
+				"docs/guide.md": "# Setup\n\n[Home](../../index.md) [FAQ](faq.md) [Top](#setup)\n" +
+					"![Logo](../../img/logo.png)\n",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			root := t.TempDir()
+			writeFiles(t, root, files)
+
+			index, _ := newTestIndex(root)
+
+			edit := renameFiles(index, []protocol.FileRename{{
+				OldURI: fileURI(filepath.Join(root, tt.old)),
+				NewURI: fileURI(filepath.Join(root, tt.new)),
+			}})
+			require.Len(t, edit.Changes, len(tt.expected))
+
+			for name, expected := range tt.expected {
+				edits, ok := edit.Changes[fileURI(filepath.Join(root, name))]
+				require.True(t, ok, name)
+				assert.Equal(t, expected, applyEdits(files[name], edits), name)
+			}
+		})
+	}
+}
+
+func TestRenameFiles_AbsoluteImage(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	logo := filepath.Join(root, "logo.png")
+	content := "<img src=\"" + filepath.ToSlash(logo) + "\">\n"
+	writeFiles(t, root, map[string]string{"a.md": content, "logo.png": "png"})
+
+	index, _ := newTestIndex(root)
+	moved := filepath.Join(root, "img", "logo.png")
+
+	edit := renameFiles(index, []protocol.FileRename{{OldURI: fileURI(logo), NewURI: fileURI(moved)}})
+
+	edits := edit.Changes[fileURI(filepath.Join(root, "a.md"))]
+	assert.Equal(t, "<img src=\""+filepath.ToSlash(moved)+"\">\n", applyEdits(content, edits))
+}
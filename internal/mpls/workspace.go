@@ -3,10 +3,12 @@ package mpls
 import (
 	"errors"
 	"fmt"
-	"path/filepath"
+	"slices"
 	"time"
 
+	"github.com/mhersson/mpls/internal/config"
 	"github.com/mhersson/mpls/internal/previewserver"
+	"github.com/mhersson/mpls/pkg/parser"
 	"github.com/tliron/glsp"
 	protocol "github.com/tliron/glsp/protocol_3_16"
 )
@@ -20,6 +22,15 @@ func WorkspaceExecuteCommand(ctx *glsp.Context, param *protocol.ExecuteCommandPa
 		// Get the most recent document to determine which URL to open
 		doc := documentRegistry.GetMostRecentDocument()
 
+		var (
+			html string
+			meta map[string]any
+		)
+
+		if doc != nil {
+			html, meta = documentRegistry.Rendered(doc.URI)
+		}
+
 		var previewURL string
 
 		// Check if browser is already open in single-page mode
@@ -32,8 +43,8 @@ func WorkspaceExecuteCommand(ctx *glsp.Context, param *protocol.ExecuteCommandPa
 
 			documentRegistry.MarkFirstPreviewShown()
 
-			if doc != nil && doc.HTML != "" {
-				previewServer.UpdateWithURI(filepath.Base(doc.URI), "", doc.HTML, doc.Meta)
+			if html != "" {
+				previewServer.UpdateWithURI(doc.URI, "", html, meta)
 			}
 		} else {
 			// Open new browser window/tab
@@ -65,7 +76,7 @@ func WorkspaceExecuteCommand(ctx *glsp.Context, param *protocol.ExecuteCommandPa
 
 			// If there are documents in registry, update preview with the most recent one
 			// This ensures preview shows content when opened with --no-auto
-			if doc != nil && doc.HTML != "" {
+			if html != "" {
 				documentURI := ""
 
 				if previewserver.EnableTabs {
@@ -77,7 +88,7 @@ func WorkspaceExecuteCommand(ctx *glsp.Context, param *protocol.ExecuteCommandPa
 					documentURI = relativePath
 				}
 
-				previewServer.UpdateWithURI(filepath.Base(doc.URI), documentURI, doc.HTML, doc.Meta)
+				previewServer.UpdateWithURI(doc.URI, documentURI, html, meta)
 			}
 		}
 	default:
@@ -87,6 +98,54 @@ func WorkspaceExecuteCommand(ctx *glsp.Context, param *protocol.ExecuteCommandPa
 	return nil, nil
 }
 
-func WorkspaceDidChangeConfiguration(_ *glsp.Context, _ *protocol.DidChangeConfigurationParams) error {
+func WorkspaceDidChangeConfiguration(ctx *glsp.Context, params *protocol.DidChangeConfigurationParams) error {
+	settings, err := config.Decode(params.Settings)
+	if err != nil {
+		return err
+	}
+
+	_ = protocol.Trace(ctx, protocol.MessageTypeInfo, log("WorkspaceDidChangeConfiguration"))
+
+	applySettings(ctx, settings)
+
 	return nil
 }
+
+func WorkspaceDidChangeWorkspaceFolders(ctx *glsp.Context, params *protocol.DidChangeWorkspaceFoldersParams) error {
+	roots := documentRegistry.GetWorkspaceRoots()
+
+	for _, folder := range params.Event.Removed {
+		roots = slices.DeleteFunc(roots, func(root string) bool {
+			return root == parser.NormalizePath(folder.URI)
+		})
+	}
+
+	for _, folder := range params.Event.Added {
+		if root := parser.NormalizePath(folder.URI); !slices.Contains(roots, root) {
+			roots = append(roots, root)
+		}
+	}
+
+	_ = protocol.Trace(ctx, protocol.MessageTypeInfo,
+		log(fmt.Sprintf("WorkspaceDidChangeWorkspaceFolders - %d folder(s)", len(roots))))
+
+	setWorkspaceRoots(roots)
+
+	// Link targets and preview URLs depend on the roots
+	rerenderDocuments(ctx)
+
+	return nil
+}
+
+// setWorkspaceRoots switches everything that resolves paths within the
+// workspace to new roots.
+func setWorkspaceRoots(roots []string) {
+	workspaceRoots = roots
+
+	documentRegistry.SetWorkspaceRoots(roots)
+	workspaceIndex.SetRoots(roots)
+	previewServer.SetWorkspaceRoots(roots)
+	parser.SetWorkspaceRoots(roots)
+
+	updateRenderSettings()
+}
@@ -0,0 +1,55 @@
+package mpls
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/mhersson/mpls/internal/previewserver"
+	"github.com/mhersson/mpls/pkg/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestWorkspaceDidChangeWorkspaceFolders(t *testing.T) { //nolint:paralleltest // Modifies the global registry, index and preview server
+	docs := setupWorkspace(t, map[string]string{"README.md": "[Deploy](../infra/deploy.md)"})
+	infra := filepath.Join(filepath.Dir(docs), "infra")
+
+	previous := previewServer
+	previewServer = &previewserver.Server{}
+
+	t.Cleanup(func() {
+		previewServer = previous
+
+		parser.SetWorkspaceRoots(nil)
+	})
+
+	setWorkspaceRoots([]string{docs})
+
+	err := WorkspaceDidChangeWorkspaceFolders(nil, &protocol.DidChangeWorkspaceFoldersParams{
+		Event: protocol.WorkspaceFoldersChangeEvent{
+			Added: []protocol.WorkspaceFolder{{URI: fileURI(infra), Name: "infra"}},
+		},
+	})
+	require.NoError(t, err)
+
+	roots := []string{docs, infra}
+	assert.Equal(t, roots, documentRegistry.GetWorkspaceRoots())
+	assert.Equal(t, roots, workspaceIndex.Roots())
+	assert.Equal(t, roots, previewServer.GetWorkspaceRoots())
+	assert.Equal(t, roots, parser.WorkspaceRoots())
+
+	readme := fileURI(filepath.Join(docs, "README.md"))
+	assert.Equal(t, "/"+filepath.Base(docs)+"/README.md", documentRegistry.GetRelativePath(readme))
+	assert.Equal(t, fileURI(filepath.Join(infra, "deploy.md")), documentRegistry.GetFileURI("/infra/deploy.md"))
+
+	err = WorkspaceDidChangeWorkspaceFolders(nil, &protocol.DidChangeWorkspaceFoldersParams{
+		Event: protocol.WorkspaceFoldersChangeEvent{
+			Removed: []protocol.WorkspaceFolder{{URI: fileURI(docs), Name: filepath.Base(docs)}},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{infra}, documentRegistry.GetWorkspaceRoots())
+	assert.Empty(t, documentRegistry.GetRelativePath(readme))
+}
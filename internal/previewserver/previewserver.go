@@ -34,11 +34,17 @@ var (
 	FixedPort            int
 	OpenBrowserOnStartup bool
 	EnableTabs           bool
+	// CustomCSS lists stylesheets served after the theme. Relative paths
+	// are resolved against the workspace root.
+	CustomCSS      []string
+	customCSSMutex sync.RWMutex
 
 	// Current content state for single-page mode.
 	currentHTML  string
 	currentTitle string
+	currentURI   string
 	currentMeta  string
+	currentView  documentView
 	contentMutex sync.RWMutex
 
 	//go:embed web/index.html
@@ -71,13 +77,56 @@ type OpenDocumentRequest struct {
 	URI           string
 	TakeFocus     bool
 	UpdatePreview bool
+	// Line is the 1-based source line to select in the editor, or 0.
+	Line int
+}
+
+// documentView holds the preview settings a document sets in its front
+// matter.
+type documentView struct {
+	Stylesheet   string
+	MermaidTheme string
+	Presentation bool
+	SlideLevel   int
+}
+
+// documentViewFor returns the preview settings of the document with the
+// given front matter. Documents without a valid theme use the server's one.
+func (s *Server) documentViewFor(meta map[string]any) documentView {
+	opts := parser.MetaOptions(meta)
+
+	stylesheet, mermaidTheme, err := themeFiles(opts.Theme)
+	if opts.Theme == "" || err != nil {
+		s.mutex.RLock()
+		stylesheet, mermaidTheme = s.stylesheet, s.mermaidTheme
+		s.mutex.RUnlock()
+	}
+
+	return documentView{
+		Stylesheet:   stylesheet,
+		MermaidTheme: mermaidTheme,
+		Presentation: opts.Presentation,
+		SlideLevel:   opts.SlideLevel,
+	}
 }
 
 type Server struct {
 	Server         *http.Server
 	InitialContent string
 	Port           int
-	WorkspaceRoot  string
+	WorkspaceRoots []string
+
+	// The renderer and PlantUML client that files requested by the browser
+	// are rendered with. The package settings are used if nil.
+	renderer *parser.Renderer
+	plantUML *plantuml.Client
+
+	// The stylesheet and mermaid theme of the current theme, used for
+	// documents that do not choose their own.
+	stylesheet   string
+	mermaidTheme string
+
+	mutex sync.RWMutex // Protects InitialContent, WorkspaceRoots, the theme and the renderers
 }
 
 func logTime() string {
@@ -191,6 +240,16 @@ func getThemeConfig(themeName string) (cssFile, mermaidTheme string) {
 	return cssFile, mermaidTheme
 }
 
+// PlantUMLSkinparams returns the skinparams that make PlantUML diagrams fit
+// the theme, like the Mermaid theme returned by getThemeConfig.
+func PlantUMLSkinparams(themeName string) string {
+	if _, mermaidTheme := getThemeConfig(themeName); mermaidTheme == "dark" {
+		return "skinparam backgroundColor transparent\nskinparam monochrome reverse"
+	}
+
+	return "skinparam backgroundColor transparent"
+}
+
 func New() *Server {
 	port := rand.Intn(65535-10000) + 10000 //nolint:gosec
 	if FixedPort > 0 {
@@ -202,17 +261,13 @@ func New() *Server {
 		Theme = "light"
 	}
 
-	theme, mermaidTheme := getThemeConfig(Theme)
-
-	// Validate theme file exists
-	themeFilePath := fmt.Sprintf("web/%s", theme)
-	if _, err := themesFS.ReadFile(themeFilePath); err != nil {
+	if _, _, err := themeFiles(Theme); err != nil {
 		fmt.Fprintf(os.Stderr, "%s Warning: theme '%s' not found, falling back to light\n", logTime(), Theme)
 
-		theme, mermaidTheme = getThemeConfig("light")
+		Theme = "light"
 	}
 
-	indexHTML = fmt.Sprintf(indexHTML, theme, mermaidTheme)
+	theme, mermaidTheme, _ := themeFiles(Theme)
 
 	srv := &http.Server{
 		Addr:        fmt.Sprintf(":%d", port),
@@ -221,17 +276,144 @@ func New() *Server {
 
 	return &Server{
 		Server:         srv,
-		InitialContent: indexHTML,
+		InitialContent: fmt.Sprintf(indexHTML, theme, mermaidTheme),
 		Port:           port,
+		renderer:       parser.DefaultRenderer(),
+		plantUML:       plantuml.DefaultClient(),
+		stylesheet:     theme,
+		mermaidTheme:   mermaidTheme,
 	}
 }
 
-func (s *Server) SetWorkspaceRoot(root string) {
-	s.WorkspaceRoot = root
+// themeFiles returns the stylesheet and mermaid theme of an embedded theme.
+func themeFiles(themeName string) (cssFile, mermaidTheme string, err error) {
+	cssFile, mermaidTheme = getThemeConfig(themeName)
+
+	if _, err := themesFS.ReadFile("web/" + cssFile); err != nil {
+		return "", "", fmt.Errorf("theme %q not found", themeName)
+	}
+
+	return cssFile, mermaidTheme, nil
 }
 
-func (s *Server) GetWorkspaceRoot() string {
-	return s.WorkspaceRoot
+// SetTheme switches the preview to another theme. Pages served from now on
+// use it, and connected clients are told to swap their stylesheet and
+// mermaid theme.
+func (s *Server) SetTheme(themeName string) error {
+	theme, mermaidTheme, err := themeFiles(themeName)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	Theme = themeName
+	s.InitialContent = fmt.Sprintf(indexHTML, theme, mermaidTheme)
+	s.stylesheet, s.mermaidTheme = theme, mermaidTheme
+	s.mutex.Unlock()
+
+	type ThemeEvent struct {
+		Type         string
+		Stylesheet   string
+		MermaidTheme string
+	}
+
+	eventJSON, err := json.Marshal(ThemeEvent{Type: "theme", Stylesheet: theme, MermaidTheme: mermaidTheme})
+	if err != nil {
+		return err
+	}
+
+	broadcastToClients(eventJSON)
+
+	return nil
+}
+
+// SetRenderers sets the renderer and PlantUML client that files requested by
+// the browser are rendered with. Requests are served on their own
+// goroutines, so they use these rather than the package settings, which can
+// change at any time.
+func (s *Server) SetRenderers(renderer *parser.Renderer, client *plantuml.Client) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.renderer, s.plantUML = renderer, client
+}
+
+// renderers returns the renderer and PlantUML client for files requested by
+// the browser. New sets them from the package settings of the time, so the
+// handlers never read those.
+func (s *Server) renderers() (*parser.Renderer, *plantuml.Client) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.renderer, s.plantUML
+}
+
+// SetCustomCSS replaces the custom stylesheets, and tells connected clients
+// to reload them.
+func (s *Server) SetCustomCSS(paths []string) {
+	customCSSMutex.Lock()
+	CustomCSS = paths
+	customCSSMutex.Unlock()
+
+	broadcastToClients([]byte(`{"Type":"stylesheets"}`))
+}
+
+// serveCustomCSS serves the custom stylesheets as one file. Stylesheets that
+// cannot be read are skipped with a comment, so the others still apply.
+func (s *Server) serveCustomCSS(w http.ResponseWriter, _ *http.Request) {
+	customCSSMutex.RLock()
+	paths := slices.Clone(CustomCSS)
+	customCSSMutex.RUnlock()
+
+	var css strings.Builder
+
+	for _, path := range paths {
+		if roots := s.GetWorkspaceRoots(); !filepath.IsAbs(path) && len(roots) > 0 {
+			path = filepath.Join(roots[0], path)
+		}
+
+		content, err := os.ReadFile(path) //nolint:gosec // Stylesheets are configured by the user
+		if err != nil {
+			fmt.Fprintf(&css, "/* %s: %s */\n", path, err)
+
+			continue
+		}
+
+		fmt.Fprintf(&css, "/* %s */\n%s\n", path, content)
+	}
+
+	w.Header().Set("Content-Type", "text/css")
+	w.Header().Set("Cache-Control", "no-cache")
+	_, _ = w.Write([]byte(css.String()))
+}
+
+// CurrentURI returns the URI of the document shown in single-page mode.
+func (s *Server) CurrentURI() string {
+	contentMutex.RLock()
+	defer contentMutex.RUnlock()
+
+	return currentURI
+}
+
+func (s *Server) initialContent() string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.InitialContent
+}
+
+func (s *Server) SetWorkspaceRoots(roots []string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.WorkspaceRoots = slices.Clone(roots)
+}
+
+func (s *Server) GetWorkspaceRoots() []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return slices.Clone(s.WorkspaceRoots)
 }
 
 func isStaticAsset(path string) bool {
@@ -243,6 +425,7 @@ func isStaticAsset(path string) bool {
 		"/ws",
 		"/presentation.js",
 		"/presentation.css",
+		"/custom.css",
 	}
 
 	if slices.Contains(staticPaths, path) {
@@ -260,12 +443,12 @@ func isValidMarkdownExt(ext string) bool {
 }
 
 func (s *Server) serveMarkdownFile(w http.ResponseWriter, r *http.Request) {
-	workspaceRoot := s.GetWorkspaceRoot()
+	workspaceRoots := s.GetWorkspaceRoots()
 
 	// If no workspace root, serve the initial content
-	if workspaceRoot == "" {
+	if len(workspaceRoots) == 0 {
 		w.Header().Set("Content-Type", "text/html")
-		_, _ = w.Write([]byte(s.InitialContent))
+		_, _ = w.Write([]byte(s.initialContent()))
 
 		return
 	}
@@ -283,15 +466,10 @@ func (s *Server) serveMarkdownFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Construct absolute file path
-	absolutePath := filepath.Join(workspaceRoot, relativePath)
-
-	// Normalize workspace root for comparison
-	normalizedRoot := parser.NormalizePath("file://" + workspaceRoot)
-
-	// Verify path is within workspace
-	normalizedPath := parser.NormalizePath("file://" + absolutePath)
-	if !strings.HasPrefix(normalizedPath, normalizedRoot) {
+	// Construct absolute file path, within the root named by the path when
+	// there are several
+	absolutePath := parser.PreviewFile(workspaceRoots, relativePath)
+	if absolutePath == "" {
 		http.Error(w, "Forbidden", http.StatusForbidden)
 
 		return
@@ -313,12 +491,18 @@ func (s *Server) serveMarkdownFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Render HTML
+	// Render HTML, giving up when the browser goes away
 	fileURI := "file://" + absolutePath
-	renderedHTML, meta := parser.HTML(string(content), fileURI, 0)
+
+	renderer, client := s.renderers()
+
+	renderedHTML, meta, err := renderer.HTML(r.Context(), string(content), fileURI, 0)
+	if err != nil {
+		return
+	}
 
 	// Process PlantUML diagrams
-	renderedHTML, _, err = plantuml.InsertPlantumlDiagram(renderedHTML, true, []plantuml.Plantuml{})
+	renderedHTML, _, err = client.InsertDiagrams(r.Context(), renderedHTML, true, []plantuml.Plantuml{})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s error processing PlantUML: %v\n", logTime(), err)
 		// Continue without PlantUML if there's an error
@@ -331,6 +515,8 @@ func (s *Server) serveMarkdownFile(w http.ResponseWriter, r *http.Request) {
 
 	_ = json.Unmarshal(metaJSON, &metaMap)
 
+	delete(metaMap, parser.OptionsKey)
+
 	metaHTML := ""
 	if len(metaMap) > 0 {
 		metaHTML = "<table>"
@@ -342,8 +528,12 @@ func (s *Server) serveMarkdownFile(w http.ResponseWriter, r *http.Request) {
 		metaHTML += "</table>"
 	}
 
-	// Create full HTML page
-	fullHTML := s.InitialContent
+	// Create full HTML page, in the document's own theme if it has one
+	fullHTML := s.initialContent()
+	if parser.MetaOptions(meta).Theme != "" {
+		view := s.documentViewFor(meta)
+		fullHTML = fmt.Sprintf(indexHTML, view.Stylesheet, view.MermaidTheme)
+	}
 	fullHTML = strings.Replace(fullHTML, `<div class="preview-content" id="content"></div>`,
 		fmt.Sprintf(`<div class="preview-content" id="content">%s</div>`, renderedHTML), 1)
 	fullHTML = strings.Replace(fullHTML, `<div id="header-meta"></div>`,
@@ -363,6 +553,7 @@ func (s *Server) Start() {
 	http.HandleFunc("/ws.js", handleResponse("application/javascript", fmt.Sprintf(websocketJS, s.Port)))
 	http.HandleFunc("/presentation.js", handleResponse("application/javascript", presentationJS))
 	http.HandleFunc("/presentation.css", handleResponse("text/css", presentationCSS))
+	http.HandleFunc("/custom.css", s.serveCustomCSS)
 
 	// Serve embedded KaTeX fonts
 	fontsSubFS, _ := fs.Sub(katexFontsFS, "web/fonts")
@@ -381,7 +572,7 @@ func (s *Server) Start() {
 		// Root path - serve initial content
 		if path == "/" || path == "" {
 			w.Header().Set("Content-Type", "text/html")
-			_, _ = w.Write([]byte(s.InitialContent))
+			_, _ = w.Write([]byte(s.initialContent()))
 
 			return
 		}
@@ -417,8 +608,8 @@ func (s *Server) Start() {
 }
 
 // Update updates the current HTML content.
-func (s *Server) Update(filename, newContent string, meta map[string]any) {
-	s.UpdateWithURI(filename, "", newContent, meta)
+func (s *Server) Update(uri, newContent string, meta map[string]any) {
+	s.UpdateWithURI(uri, "", newContent, meta)
 }
 
 // CloseDocument sends a close message to clients viewing the specified document.
@@ -441,19 +632,44 @@ func (s *Server) CloseDocument(documentURI string, isLastDocument bool) {
 	broadcastToClients(eventJSON)
 }
 
+// ScrollToLine asks clients showing the document to scroll to the block
+// rendered from the 1-based source line.
+func (s *Server) ScrollToLine(uri, documentURI string, line int) {
+	type ScrollEvent struct {
+		Type        string
+		Title       string
+		DocumentURI string
+		Line        int
+	}
+
+	e := ScrollEvent{Type: "scrollToLine", Title: strings.TrimSuffix(filepath.Base(uri), ".md"), DocumentURI: documentURI, Line: line}
+
+	eventJSON, err := json.Marshal(e)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error marshaling scroll event to JSON: %v\n", err)
+
+		return
+	}
+
+	broadcastToClients(eventJSON)
+}
+
 // UpdateWithURI updates the current HTML content with document URI for client filtering.
-func (s *Server) UpdateWithURI(filename, documentURI string, newContent string, meta map[string]any) {
+// The uri is the file:// URI of the rendered document.
+func (s *Server) UpdateWithURI(uri, documentURI string, newContent string, meta map[string]any) {
 	type Event struct {
 		HTML        string
 		Title       string
 		Meta        string
 		DocumentURI string
+		View        documentView
 	}
 
-	t := strings.TrimSuffix(filename, ".md")
+	t := strings.TrimSuffix(filepath.Base(uri), ".md")
 	m := convertMetaToHTMLTable(meta)
+	v := s.documentViewFor(meta)
 
-	e := Event{HTML: newContent, Title: t, Meta: m, DocumentURI: documentURI}
+	e := Event{HTML: newContent, Title: t, Meta: m, DocumentURI: documentURI, View: v}
 
 	// Store current content for single-page mode (when no documentURI filtering)
 	if !EnableTabs {
@@ -461,6 +677,8 @@ func (s *Server) UpdateWithURI(filename, documentURI string, newContent string,
 		currentHTML = newContent
 		currentTitle = t
 		currentMeta = m
+		currentView = v
+		currentURI = uri
 		contentMutex.Unlock()
 	}
 
@@ -530,6 +748,7 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 				"Title":       currentTitle,
 				"Meta":        currentMeta,
 				"DocumentURI": "",
+				"View":        currentView,
 			}
 			if msgJSON, err := json.Marshal(contentMsg); err == nil {
 				if err := conn.WriteMessage(websocket.TextMessage, msgJSON); err != nil {
@@ -586,6 +805,7 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 			URI           string `json:"uri"`
 			TakeFocus     bool   `json:"takeFocus"`
 			UpdatePreview bool   `json:"updatePreview"`
+			Line          int    `json:"line"`
 		}
 
 		if err := json.Unmarshal(msg, &incomingMsg); err == nil {
@@ -600,6 +820,22 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 
 				continue
 			}
+
+			if incomingMsg.Type == "revealSource" && incomingMsg.Line > 0 {
+				uri := incomingMsg.URI
+				if uri == "" {
+					// Single-page mode shows the last rendered document
+					contentMutex.RLock()
+					uri = currentURI
+					contentMutex.RUnlock()
+				}
+
+				if uri != "" {
+					LSPRequestChan <- OpenDocumentRequest{URI: uri, TakeFocus: true, Line: incomingMsg.Line}
+				}
+
+				continue
+			}
 		}
 
 		// Unknown message types are ignored (no broadcast needed)
@@ -646,7 +882,14 @@ func convertMetaToHTMLTable(meta map[string]any) string {
 
 	keys := make([]string, 0, len(meta))
 	for k := range meta {
-		keys = append(keys, k)
+		// Per-document settings are applied, not shown
+		if k != parser.OptionsKey {
+			keys = append(keys, k)
+		}
+	}
+
+	if len(keys) == 0 {
+		return ""
 	}
 
 	sort.Strings(keys)
@@ -1,10 +1,15 @@
 package previewserver
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGetChromaStyleForTheme(t *testing.T) {
@@ -221,6 +226,98 @@ func TestGetThemeConfig(t *testing.T) {
 	}
 }
 
+func TestPlantUMLSkinparams(t *testing.T) {
+	t.Parallel()
+
+	assert.Contains(t, PlantUMLSkinparams("nord"), "skinparam monochrome reverse")
+	assert.Contains(t, PlantUMLSkinparams("github-dark"), "skinparam monochrome reverse")
+	assert.Equal(t, "skinparam backgroundColor transparent", PlantUMLSkinparams("light"))
+}
+
+func TestSetTheme(t *testing.T) { //nolint:paralleltest // Modifies the global theme
+	original := Theme
+
+	t.Cleanup(func() { Theme = original })
+
+	s := &Server{}
+
+	require.NoError(t, s.SetTheme("nord"))
+	assert.Equal(t, "nord", Theme)
+	assert.Contains(t, s.initialContent(), `href="/themes/nord.css"`)
+	assert.Contains(t, s.initialContent(), `theme: "dark"`)
+
+	require.Error(t, s.SetTheme("no-such-theme"))
+	assert.Equal(t, "nord", Theme)
+	assert.Contains(t, s.initialContent(), `href="/themes/nord.css"`)
+}
+
+func TestServeCustomCSS(t *testing.T) { //nolint:paralleltest // Modifies the global custom stylesheets
+	original := CustomCSS
+
+	t.Cleanup(func() { CustomCSS = original })
+
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a.css"), []byte("body { color: red; }"), 0o600))
+
+	s := &Server{WorkspaceRoots: []string{root}}
+	s.SetCustomCSS([]string{"a.css", filepath.Join(root, "missing.css")})
+
+	rec := httptest.NewRecorder()
+	s.serveCustomCSS(rec, httptest.NewRequest(http.MethodGet, "/custom.css", nil))
+
+	assert.Equal(t, "text/css", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "body { color: red; }")
+	assert.Contains(t, rec.Body.String(), "missing.css")
+}
+
+func TestDocumentViewFor(t *testing.T) { //nolint:paralleltest // Modifies the global theme
+	original := Theme
+
+	t.Cleanup(func() { Theme = original })
+
+	s := &Server{}
+	require.NoError(t, s.SetTheme("light"))
+
+	view := s.documentViewFor(map[string]any{
+		"mpls": map[any]any{"theme": "nord", "presentation": true, "slideLevel": 2},
+	})
+	assert.Equal(t, documentView{Stylesheet: "themes/nord.css", MermaidTheme: "dark", Presentation: true, SlideLevel: 2}, view)
+
+	// Documents without a theme, or with an unknown one, use the server's theme
+	for _, meta := range []map[string]any{nil, {"mpls": map[any]any{"theme": "no-such-theme"}}} {
+		view = s.documentViewFor(meta)
+		assert.Equal(t, "themes/light.css", view.Stylesheet)
+		assert.False(t, view.Presentation)
+	}
+}
+
+func TestDocumentViewFor_WhileThemeChanges(t *testing.T) { //nolint:paralleltest // Modifies the global theme
+	original := Theme
+
+	t.Cleanup(func() { Theme = original })
+
+	s := &Server{}
+	require.NoError(t, s.SetTheme("light"))
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for range 50 {
+			_ = s.SetTheme("nord")
+			_ = s.SetTheme("light")
+		}
+	}()
+
+	for range 50 {
+		view := s.documentViewFor(nil)
+		assert.Contains(t, []string{"themes/light.css", "themes/nord.css"}, view.Stylesheet)
+	}
+
+	<-done
+}
+
 func TestIsValidMarkdownExt(t *testing.T) {
 	t.Parallel()
 
@@ -270,6 +367,7 @@ func TestIsStaticAsset(t *testing.T) {
 		{name: "ws websocket endpoint", path: "/ws", expected: true},
 		{name: "presentation.js", path: "/presentation.js", expected: true},
 		{name: "presentation.css", path: "/presentation.css", expected: true},
+		{name: "custom.css", path: "/custom.css", expected: true},
 		// Font paths
 		{name: "font file", path: "/fonts/KaTeX_Main-Regular.woff2", expected: true},
 		{name: "fonts root", path: "/fonts/", expected: true},
@@ -367,6 +465,16 @@ func TestConvertMetaToHTMLTable(t *testing.T) {
 		assert.Contains(t, result, "true")
 		assert.Contains(t, result, "3.14")
 	})
+
+	t.Run("per-document settings are not shown", func(t *testing.T) {
+		t.Parallel()
+
+		result := convertMetaToHTMLTable(map[string]any{"title": "Test", "mpls": map[any]any{"theme": "nord"}})
+		assert.Contains(t, result, "<td>title</td>")
+		assert.NotContains(t, result, "mpls")
+
+		assert.Empty(t, convertMetaToHTMLTable(map[string]any{"mpls": map[any]any{"theme": "nord"}}))
+	})
 }
 
 func TestConvertMetaToHTMLTable_SortedKeys(t *testing.T) {
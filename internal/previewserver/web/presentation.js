@@ -3,6 +3,7 @@
     "use strict";
 
     const CONTROLS_HIDE_DELAY = 2000;
+    const DEFAULT_SLIDE_LEVEL = 3;
 
     // State
     let state = {
@@ -12,6 +13,7 @@
         currentFragmentStep: 0,
         rawHtml: "",
         hideControlsTimer: null,
+        slideLevel: DEFAULT_SLIDE_LEVEL,
     };
 
     // DOM elements (initialized on DOMContentLoaded)
@@ -71,13 +73,16 @@
     }
 
     /**
-     * Split HTML on H1, H2, and H3 headers for auto-slide mode
+     * Split HTML on headers up to the slide level (H1-H3 by default) for
+     * auto-slide mode
      * Used as fallback when no <!-- slide --> markers are present
      * Merges header-only slides with next slide (except first slide)
      */
     function splitOnHeaders(html) {
-        // Split before <h1>, <h2>, or <h3> tags using lookahead
-        const headerPattern = /(?=<h[123][^>]*>)/gi;
+        // Split before <h1> to <hN> tags using lookahead
+        const levels = `[1-${state.slideLevel}]`;
+        const headerPattern = new RegExp(`(?=<h${levels}[^>]*>)`, "gi");
+        const headerOnlyPattern = new RegExp(`^<h${levels}[^>]*>.*?</h${levels}>\\s*$`, "is");
         const parts = html.split(headerPattern).filter(part => part.trim().length > 0);
 
         // Merge header-only parts with next part (except first slide which can be title-only)
@@ -85,7 +90,7 @@
         for (let i = 0; i < parts.length; i++) {
             const part = parts[i];
             // Check if this part is header-only (no content after the header)
-            const isHeaderOnly = /^<h[123][^>]*>.*?<\/h[123]>\s*$/is.test(part.trim());
+            const isHeaderOnly = headerOnlyPattern.test(part.trim());
 
             if (isHeaderOnly && i > 0 && i < parts.length - 1) {
                 // Merge with next part
@@ -436,6 +441,14 @@
         return -1;
     }
 
+    /**
+     * Set the deepest header level that starts a slide, as given in the
+     * document's front matter. Falls back to the default for invalid levels.
+     */
+    function setSlideLevel(level) {
+        state.slideLevel = level >= 1 && level <= 6 ? level : DEFAULT_SLIDE_LEVEL;
+    }
+
     /**
      * Handle content updates from ws.js
      */
@@ -667,6 +680,7 @@
         enter: enter,
         exit: exit,
         onContentUpdate: onContentUpdate,
+        setSlideLevel: setSlideLevel,
         isActive: () => state.active,
         getCurrentSlide: () => state.currentIndex,
         getSlideCount: () => state.slides.length,
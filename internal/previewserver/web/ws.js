@@ -21,6 +21,8 @@
     ws: null,
     isReloading: false,
     enableTabsMode: false,
+    // Whether presentation mode was entered because of front matter
+    presentationFromDocument: false,
   };
 
   // ==========================================================================
@@ -154,6 +156,49 @@
       }
     },
 
+    // Scroll to the block rendered from a 1-based source line. The deepest
+    // block containing the line wins; lines without a block of their own,
+    // like those in fenced code, fall back to the closest block before them.
+    toLine(line) {
+      if ($("disable-scrolling")?.checked) {
+        return;
+      }
+
+      let target = null;
+      let targetStart = 0;
+      let targetEnd = 0;
+
+      for (const el of $$("[data-mpls-lines]")) {
+        const [start, end] = el.dataset.mplsLines.split("-").map(Number);
+        if (start > line) {
+          break;
+        }
+        if (end >= line || !target || targetEnd < line) {
+          target = el;
+          targetStart = start;
+          targetEnd = end;
+        }
+      }
+
+      if (!target) {
+        window.scrollTo({ top: 0, behavior: "smooth" });
+        return;
+      }
+
+      // Place the line proportionally within blocks spanning several lines
+      const rect = target.getBoundingClientRect();
+      let offset = 0;
+      if (line <= targetEnd && targetEnd > targetStart) {
+        offset =
+          (rect.height * (line - targetStart)) / (targetEnd - targetStart + 1);
+      }
+
+      const top = rect.top + window.scrollY + offset - CONFIG.SCROLL_OFFSET;
+      if (Math.abs(window.scrollY - top) > 5) {
+        window.scrollTo({ top, behavior: "smooth" });
+      }
+    },
+
     handleResize: debounce(() => {
       if (scroll.lastTarget && !$("disable-scrolling")?.checked) {
         scroll.toEdit();
@@ -347,6 +392,42 @@
     },
   };
 
+  // ==========================================================================
+  // Reveal Source Module
+  // ==========================================================================
+
+  const revealSource = {
+    setup() {
+      $("content")?.addEventListener("dblclick", (event) =>
+        this.handleDoubleClick(event),
+      );
+    },
+
+    handleDoubleClick(event) {
+      const block = event.target.closest("[data-mpls-lines]");
+      if (!block || !state.ws) return;
+
+      // Estimate the line within blocks spanning several source lines
+      const [start, end] = block.dataset.mplsLines.split("-").map(Number);
+      const rect = block.getBoundingClientRect();
+      const fraction =
+        rect.height > 0 ? (event.clientY - rect.top) / rect.height : 0;
+      const line = Math.min(
+        end,
+        start + Math.floor(Math.max(0, fraction) * (end - start + 1)),
+      );
+
+      state.ws.send(
+        JSON.stringify({
+          type: "revealSource",
+          // The server knows which document single-page mode shows
+          uri: state.enableTabsMode ? window.location.pathname : "",
+          line: line,
+        }),
+      );
+    },
+  };
+
   // ==========================================================================
   // WebSocket Module
   // ==========================================================================
@@ -355,6 +436,9 @@
     handlers: {
       config: (data) => websocket.handleConfig(data),
       closeDocument: (data) => websocket.handleClose(data),
+      scrollToLine: (data) => websocket.handleScrollToLine(data),
+      theme: (data) => websocket.handleTheme(data),
+      stylesheets: () => websocket.handleStylesheets(),
     },
 
     init(ws) {
@@ -363,6 +447,7 @@
       ws.addEventListener("open", () => {
         console.log("WebSocket connection established");
         links.setup();
+        revealSource.setup();
       });
 
       ws.addEventListener("message", (event) => this.onMessage(event));
@@ -428,12 +513,69 @@
       }
     },
 
+    handleTheme(data) {
+      const link = document.querySelector('link[href^="/themes/"]');
+      if (link) {
+        link.setAttribute("href", `/${data.Stylesheet}`);
+      }
+
+      // Diagrams are rendered again by the content update that follows
+      if (window.mermaid) {
+        window.mermaid.initialize({
+          startOnLoad: false,
+          theme: data.MermaidTheme,
+        });
+      }
+      mermaidRenderer.svgCache.clear();
+    },
+
+    // Switches to the theme a document sets in its front matter, or back to
+    // the global one
+    applyView(view) {
+      const link = document.querySelector('link[href^="/themes/"]');
+      if (link && link.getAttribute("href") !== `/${view.Stylesheet}`) {
+        websocket.handleTheme(view);
+      }
+    },
+
+    // Enters presentation mode when a focused document asks for it, and
+    // leaves it again for documents that do not
+    applyPresentation(view) {
+      if (view.Presentation && !window.presentation.isActive()) {
+        window.presentation.enter();
+        state.presentationFromDocument = window.presentation.isActive();
+      } else if (!view.Presentation && state.presentationFromDocument) {
+        window.presentation.exit();
+        state.presentationFromDocument = false;
+      }
+    },
+
+    handleStylesheets() {
+      const link = document.querySelector('link[href^="/custom.css"]');
+      if (link) {
+        link.setAttribute("href", `/custom.css?${Date.now()}`);
+      }
+    },
+
+    handleScrollToLine(data) {
+      // Only follow the cursor in the document this page is showing
+      if (data.DocumentURI && data.DocumentURI !== window.location.pathname) {
+        return;
+      }
+      if (!data.DocumentURI && `mpls - ${data.Title}` !== document.title) {
+        return;
+      }
+
+      scroll.toLine(data.Line);
+    },
+
     async handleContent(response) {
       const {
         HTML: renderedHtml,
         Title: responseTitle,
         Meta: meta,
         DocumentURI: documentURI,
+        View: view,
       } = response;
 
       // If DocumentURI is provided, check if it matches current page
@@ -470,12 +612,21 @@
         headerMeta.innerHTML = meta;
       }
 
+      if (view) {
+        websocket.applyView(view);
+      }
+
       // Update content
       content.update(renderedHtml);
 
       // Notify presentation module of content update
       if (window.presentation) {
+        window.presentation.setSlideLevel(view?.SlideLevel);
         window.presentation.onContentUpdate(renderedHtml);
+
+        if (titleChanged && view) {
+          websocket.applyPresentation(view);
+        }
       }
 
       // Render and scroll
@@ -0,0 +1,288 @@
+// Package diskcache keeps rendered output on disk between sessions, so that
+// diagrams and math are not rendered again every time mpls starts.
+package diskcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxSize is the default size limit of a cache, in bytes.
+const DefaultMaxSize = 100 << 20
+
+// tempPrefix starts the names of entries that are still being written.
+const tempPrefix = ".tmp-"
+
+// staleTempAge is the age after which an entry that is still being written
+// is taken to be left over from an interrupted write. Other processes may be
+// writing the younger ones.
+const staleTempAge = time.Hour
+
+// Options configures a Cache.
+type Options struct {
+	// Dir is the directory the entries are stored in. It is created if it
+	// does not exist. DefaultDir is used if empty.
+	Dir string
+	// MaxSize is the total size of the entries, in bytes, above which the
+	// least recently used ones are removed.
+	MaxSize int64
+}
+
+// Cache stores values on disk under the SHA-256 hash of their key. Entries
+// are removed in least recently used order, by modification time, when the
+// cache grows beyond its size limit. Several processes can share a
+// directory. A Cache is safe for concurrent use, and a nil Cache stores
+// nothing.
+type Cache struct {
+	opts Options
+
+	mutex sync.Mutex
+	size  int64 // total size of the entries, as far as this process knows
+}
+
+// entry is a file in the cache directory.
+type entry struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// DefaultDir returns the default cache directory, $XDG_CACHE_HOME/mpls or
+// the platform's user cache directory, or "" if it cannot be determined.
+func DefaultDir() string {
+	dir := os.Getenv("XDG_CACHE_HOME")
+	if dir == "" {
+		var err error
+
+		dir, err = os.UserCacheDir()
+		if err != nil {
+			return ""
+		}
+	}
+
+	return filepath.Join(dir, "mpls")
+}
+
+func New(opts Options) (*Cache, error) {
+	if opts.Dir == "" {
+		opts.Dir = DefaultDir()
+		if opts.Dir == "" {
+			return nil, errors.New("diskcache: no cache directory")
+		}
+	}
+
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultMaxSize
+	}
+
+	if err := os.MkdirAll(opts.Dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	c := &Cache{opts: opts}
+	c.removeStaleTemp()
+
+	entries, err := c.entries()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		c.size += e.size
+	}
+
+	return c, nil
+}
+
+// Get returns the value stored for key and marks it as recently used.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	path := c.path(key)
+
+	value, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+
+	return value, true
+}
+
+// Set stores value for key, removing the least recently used entries if
+// the cache has grown too large.
+func (c *Cache) Set(key string, value []byte) error {
+	if c == nil {
+		return nil
+	}
+
+	path := c.path(key)
+
+	tmp, err := os.CreateTemp(c.opts.Dir, tempPrefix)
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(value)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var replaced int64
+	if info, err := os.Stat(path); err == nil {
+		replaced = info.Size()
+	}
+
+	// Renaming makes the entry appear complete to other processes
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+
+		return err
+	}
+
+	c.size += int64(len(value)) - replaced
+
+	if c.size > c.opts.MaxSize {
+		return c.evict()
+	}
+
+	return nil
+}
+
+// Clear removes all entries.
+func (c *Cache) Clear() error {
+	if c == nil {
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entries, err := c.entries()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+
+	for _, e := range entries {
+		if err := os.Remove(e.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+
+	c.size = 0
+
+	return errors.Join(errs...)
+}
+
+// evict removes the least recently used entries until the cache is below
+// nine tenths of its size limit, so that it is not trimmed on every Set.
+func (c *Cache) evict() error {
+	entries, err := c.entries()
+	if err != nil {
+		return err
+	}
+
+	// Other processes may have added or removed entries
+	c.size = 0
+	for _, e := range entries {
+		c.size += e.size
+	}
+
+	slices.SortFunc(entries, func(a, b entry) int {
+		return a.modTime.Compare(b.modTime)
+	})
+
+	target := c.opts.MaxSize - c.opts.MaxSize/10
+
+	for _, e := range entries {
+		if c.size <= target {
+			break
+		}
+
+		if err := os.Remove(e.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		c.size -= e.size
+	}
+
+	return nil
+}
+
+// removeStaleTemp removes the files left over from interrupted writes.
+func (c *Cache) removeStaleTemp() {
+	files, err := os.ReadDir(c.opts.Dir)
+	if err != nil {
+		return
+	}
+
+	for _, file := range files {
+		if file.IsDir() || !strings.HasPrefix(file.Name(), tempPrefix) {
+			continue
+		}
+
+		if info, err := file.Info(); err == nil && time.Since(info.ModTime()) > staleTempAge {
+			_ = os.Remove(filepath.Join(c.opts.Dir, file.Name()))
+		}
+	}
+}
+
+// entries lists the entries in the cache directory. Files that are still
+// being written, or were not created by a Cache, are left out.
+func (c *Cache) entries() ([]entry, error) {
+	files, err := os.ReadDir(c.opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]entry, 0, len(files))
+
+	for _, file := range files {
+		if file.IsDir() || len(file.Name()) != 2*sha256.Size {
+			continue
+		}
+
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+
+		entries = append(entries, entry{
+			path:    filepath.Join(c.opts.Dir, file.Name()),
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+	}
+
+	return entries, nil
+}
+
+// path returns the file that the value of key is stored in.
+func (c *Cache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+
+	return filepath.Join(c.opts.Dir, hex.EncodeToString(sum[:]))
+}
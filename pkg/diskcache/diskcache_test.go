@@ -0,0 +1,131 @@
+package diskcache
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_GetSet(t *testing.T) {
+	t.Parallel()
+
+	c, err := New(Options{Dir: t.TempDir()})
+	require.NoError(t, err)
+
+	_, ok := c.Get("plantuml:png/abc")
+	assert.False(t, ok)
+
+	require.NoError(t, c.Set("plantuml:png/abc", []byte("diagram")))
+
+	value, ok := c.Get("plantuml:png/abc")
+	require.True(t, ok)
+	assert.Equal(t, []byte("diagram"), value)
+
+	require.NoError(t, c.Set("plantuml:png/abc", []byte("replaced")))
+
+	value, _ = c.Get("plantuml:png/abc")
+	assert.Equal(t, []byte("replaced"), value)
+	assert.Equal(t, int64(len("replaced")), c.size)
+}
+
+func TestCache_Persists(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	c, err := New(Options{Dir: dir})
+	require.NoError(t, err)
+	require.NoError(t, c.Set("katex:i:x^2", []byte("<span>x²</span>")))
+
+	reopened, err := New(Options{Dir: dir})
+	require.NoError(t, err)
+
+	value, ok := reopened.Get("katex:i:x^2")
+	require.True(t, ok)
+	assert.Equal(t, []byte("<span>x²</span>"), value)
+	assert.Equal(t, int64(len(value)), reopened.size)
+}
+
+func TestCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	t.Parallel()
+
+	c, err := New(Options{Dir: t.TempDir(), MaxSize: 25})
+	require.NoError(t, err)
+
+	require.NoError(t, c.Set("a", []byte(strings.Repeat("a", 10))))
+	require.NoError(t, c.Set("b", []byte(strings.Repeat("b", 10))))
+
+	// a is older than b until it is read
+	past := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(c.path("a"), past, past))
+	require.NoError(t, os.Chtimes(c.path("b"), past.Add(time.Minute), past.Add(time.Minute)))
+
+	_, ok := c.Get("a")
+	require.True(t, ok)
+
+	require.NoError(t, c.Set("c", []byte(strings.Repeat("c", 10))))
+
+	_, ok = c.Get("b")
+	assert.False(t, ok, "the least recently used entry is removed")
+
+	_, ok = c.Get("a")
+	assert.True(t, ok)
+
+	_, ok = c.Get("c")
+	assert.True(t, ok)
+}
+
+func TestCache_Clear(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	other := filepath.Join(dir, "notes.txt")
+	require.NoError(t, os.WriteFile(other, []byte("not a cache entry"), 0o600))
+
+	c, err := New(Options{Dir: dir})
+	require.NoError(t, err)
+	require.NoError(t, c.Set("key", []byte("value")))
+
+	require.NoError(t, c.Clear())
+
+	_, ok := c.Get("key")
+	assert.False(t, ok)
+	assert.FileExists(t, other, "only cache entries are removed")
+}
+
+func TestNew_RemovesStaleTemp(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	stale := filepath.Join(dir, tempPrefix+"interrupted")
+	writing := filepath.Join(dir, tempPrefix+"writing")
+
+	require.NoError(t, os.WriteFile(stale, []byte("partial"), 0o600))
+	require.NoError(t, os.WriteFile(writing, []byte("partial"), 0o600))
+
+	past := time.Now().Add(-2 * staleTempAge)
+	require.NoError(t, os.Chtimes(stale, past, past))
+
+	_, err := New(Options{Dir: dir})
+	require.NoError(t, err)
+
+	assert.NoFileExists(t, stale)
+	assert.FileExists(t, writing, "files another process may be writing are kept")
+}
+
+func TestCache_Nil(t *testing.T) {
+	t.Parallel()
+
+	var c *Cache
+
+	require.NoError(t, c.Set("key", []byte("value")))
+
+	_, ok := c.Get("key")
+	assert.False(t, ok)
+	assert.NoError(t, c.Clear())
+}
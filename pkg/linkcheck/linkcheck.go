@@ -0,0 +1,258 @@
+package linkcheck
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Default settings used when the corresponding Options field is zero.
+const (
+	DefaultConcurrency  = 4
+	DefaultTimeout      = 10 * time.Second
+	DefaultTTL          = 30 * time.Minute
+	DefaultHostInterval = 500 * time.Millisecond
+)
+
+// Options configures a Checker.
+type Options struct {
+	// Concurrency is the maximum number of requests in flight.
+	Concurrency int
+	// Timeout applies to each request.
+	Timeout time.Duration
+	// TTL is how long a result is reused before the URL is checked again.
+	TTL time.Duration
+	// HostInterval is the minimum time between two requests to the same
+	// host. A negative value disables the limit.
+	HostInterval time.Duration
+	// Client sends the requests. http.DefaultClient is used if nil.
+	Client *http.Client
+}
+
+// Result is the outcome of checking a single URL.
+type Result struct {
+	URL string
+	// StatusCode is the final HTTP status, or 0 if no response was received.
+	StatusCode int
+	// Err is set when the host could not be reached.
+	Err error
+
+	checked time.Time
+}
+
+// OK reports whether the URL responded with a non-error status.
+func (r Result) OK() bool {
+	return r.Err == nil && r.StatusCode < http.StatusBadRequest
+}
+
+// Message describes why the URL is broken.
+func (r Result) Message() string {
+	if r.Err != nil {
+		return "unreachable: " + r.Err.Error()
+	}
+
+	return fmt.Sprintf("returned %d %s", r.StatusCode, http.StatusText(r.StatusCode))
+}
+
+// Checker checks http(s) URLs, caching results and limiting how hard each
+// host is hit.
+type Checker struct {
+	opts   Options
+	client *http.Client
+	sem    chan struct{}
+
+	mutex    sync.Mutex
+	cache    map[string]Result
+	nextSlot map[string]time.Time // host -> earliest time for the next request
+}
+
+func New(opts Options) *Checker {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultConcurrency
+	}
+
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultTTL
+	}
+
+	if opts.HostInterval < 0 {
+		opts.HostInterval = 0
+	} else if opts.HostInterval == 0 {
+		opts.HostInterval = DefaultHostInterval
+	}
+
+	client := opts.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &Checker{
+		opts:     opts,
+		client:   client,
+		sem:      make(chan struct{}, opts.Concurrency),
+		cache:    make(map[string]Result),
+		nextSlot: make(map[string]time.Time),
+	}
+}
+
+// Cached returns the result of an earlier check of rawURL if it has not
+// expired, without making any requests.
+func (c *Checker) Cached(rawURL string) (Result, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	r, ok := c.cache[rawURL]
+	if !ok || time.Since(r.checked) > c.opts.TTL {
+		return Result{}, false
+	}
+
+	return r, true
+}
+
+// CheckAll checks every URL concurrently and returns the results keyed by
+// URL. Duplicate URLs are only checked once.
+func (c *Checker) CheckAll(ctx context.Context, urls []string) map[string]Result {
+	results := make(map[string]Result, len(urls))
+	seen := make(map[string]bool, len(urls))
+
+	var (
+		wg    sync.WaitGroup
+		mutex sync.Mutex
+	)
+
+	for _, u := range urls {
+		if seen[u] {
+			continue
+		}
+
+		seen[u] = true
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			r := c.Check(ctx, u)
+
+			mutex.Lock()
+			results[u] = r
+			mutex.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	return results
+}
+
+// Check returns the status of rawURL, using the cache when possible. A HEAD
+// request is tried first, falling back to GET for servers that reject or
+// mishandle HEAD.
+func (c *Checker) Check(ctx context.Context, rawURL string) Result {
+	if r, ok := c.Cached(rawURL); ok {
+		return r
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Result{URL: rawURL, Err: fmt.Errorf("invalid URL %q", rawURL)}
+	}
+
+	r := c.request(ctx, http.MethodHead, u)
+	if !r.OK() && ctx.Err() == nil {
+		r = c.request(ctx, http.MethodGet, u)
+	}
+
+	// Results of cancelled checks say nothing about the link
+	if ctx.Err() != nil {
+		return r
+	}
+
+	r.checked = time.Now()
+
+	c.mutex.Lock()
+	c.cache[rawURL] = r
+	c.mutex.Unlock()
+
+	return r
+}
+
+func (c *Checker) request(ctx context.Context, method string, u *url.URL) Result {
+	result := Result{URL: u.String()}
+
+	if err := c.waitForHost(ctx, u.Host); err != nil {
+		result.Err = err
+
+		return result
+	}
+
+	// The slot is taken after the rate limit wait, so that links to other
+	// hosts are not held up by it
+	select {
+	case c.sem <- struct{}{}:
+		defer func() { <-c.sem }()
+	case <-ctx.Done():
+		result.Err = ctx.Err()
+
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		result.Err = err
+
+		return result
+	}
+
+	resp, err := c.client.Do(req) //nolint:gosec // Intentional: checking links found in the document
+	if err != nil {
+		result.Err = err
+
+		return result
+	}
+
+	_ = resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+
+	return result
+}
+
+// waitForHost blocks until a request to host is allowed by the per-host
+// rate limit.
+func (c *Checker) waitForHost(ctx context.Context, host string) error {
+	c.mutex.Lock()
+
+	now := time.Now()
+
+	slot := c.nextSlot[host]
+	if slot.Before(now) {
+		slot = now
+	}
+
+	c.nextSlot[host] = slot.Add(c.opts.HostInterval)
+	c.mutex.Unlock()
+
+	if wait := time.Until(slot); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,227 @@
+package linkcheck
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestCheck_Status(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			w.WriteHeader(http.StatusOK)
+		case "/redirect":
+			http.Redirect(w, r, "/ok", http.StatusMovedPermanently)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	checker := New(Options{HostInterval: -1})
+
+	r := checker.Check(context.Background(), server.URL+"/ok")
+	assert.True(t, r.OK())
+	assert.Equal(t, http.StatusOK, r.StatusCode)
+
+	r = checker.Check(context.Background(), server.URL+"/redirect")
+	assert.True(t, r.OK())
+
+	r = checker.Check(context.Background(), server.URL+"/missing")
+	assert.False(t, r.OK())
+	assert.Equal(t, "returned 404 Not Found", r.Message())
+}
+
+func TestCheck_FallsBackToGet(t *testing.T) {
+	t.Parallel()
+
+	var methods []string
+
+	var mutex sync.Mutex
+
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		methods = append(methods, r.Method)
+		mutex.Unlock()
+
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+
+	r := New(Options{HostInterval: -1}).Check(context.Background(), server.URL)
+	assert.True(t, r.OK())
+	assert.Equal(t, []string{http.MethodHead, http.MethodGet}, methods)
+}
+
+func TestCheck_Unreachable(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	url := server.URL
+	server.Close()
+
+	r := New(Options{HostInterval: -1}).Check(context.Background(), url)
+	assert.False(t, r.OK())
+	require.Error(t, r.Err)
+	assert.Contains(t, r.Message(), "unreachable")
+}
+
+func TestCheck_Timeout(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+
+	r := New(Options{Timeout: 20 * time.Millisecond, HostInterval: -1}).Check(context.Background(), server.URL)
+	assert.False(t, r.OK())
+	require.ErrorIs(t, r.Err, context.DeadlineExceeded)
+}
+
+func TestCheck_Cache(t *testing.T) {
+	t.Parallel()
+
+	var requests atomic.Int32
+
+	server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	checker := New(Options{TTL: 50 * time.Millisecond, HostInterval: -1})
+
+	_, ok := checker.Cached(server.URL)
+	assert.False(t, ok)
+
+	checker.Check(context.Background(), server.URL)
+	checker.Check(context.Background(), server.URL)
+	assert.Equal(t, int32(1), requests.Load())
+
+	r, ok := checker.Cached(server.URL)
+	assert.True(t, ok)
+	assert.True(t, r.OK())
+
+	time.Sleep(60 * time.Millisecond)
+
+	checker.Check(context.Background(), server.URL)
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func TestCheckAll_ConcurrencyLimit(t *testing.T) {
+	t.Parallel()
+
+	var inFlight, peak atomic.Int32
+
+	server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		n := inFlight.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		inFlight.Add(-1)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	checker := New(Options{Concurrency: 2, HostInterval: -1})
+
+	urls := []string{server.URL + "/a", server.URL + "/b", server.URL + "/c", server.URL + "/d", server.URL + "/a"}
+
+	results := checker.CheckAll(context.Background(), urls)
+	assert.Len(t, results, 4)
+
+	for _, r := range results {
+		assert.True(t, r.OK(), r.URL)
+	}
+
+	assert.LessOrEqual(t, peak.Load(), int32(2))
+}
+
+func TestCheckAll_HostRateLimit(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	checker := New(Options{Concurrency: 4, HostInterval: 30 * time.Millisecond})
+
+	start := time.Now()
+	checker.CheckAll(context.Background(), []string{server.URL + "/a", server.URL + "/b", server.URL + "/c"})
+
+	// Three requests to one host need at least two intervals
+	assert.GreaterOrEqual(t, time.Since(start), 60*time.Millisecond)
+}
+
+func TestCheckAll_RateLimitDoesNotHoldSlot(t *testing.T) {
+	t.Parallel()
+
+	handler := func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+	limited := newTestServer(t, handler)
+	other := newTestServer(t, handler)
+
+	checker := New(Options{Concurrency: 1, HostInterval: 300 * time.Millisecond})
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		checker.CheckAll(context.Background(), []string{limited.URL + "/a", limited.URL + "/b"})
+	}()
+
+	// Let the second request to the limited host start waiting
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+	r := checker.Check(context.Background(), other.URL)
+
+	assert.True(t, r.OK())
+	assert.Less(t, time.Since(start), 200*time.Millisecond, "other hosts are checked while one is rate limited")
+
+	<-done
+}
+
+func TestCheck_InvalidURL(t *testing.T) {
+	t.Parallel()
+
+	r := New(Options{}).Check(context.Background(), "ftp://example.com/file")
+	assert.False(t, r.OK())
+	require.Error(t, r.Err)
+}
@@ -0,0 +1,800 @@
+package parser //nolint:revive
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	emojiast "github.com/yuin/goldmark-emoji/ast"
+	meta "github.com/yuin/goldmark-meta"
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/parser"
+	"go.abhg.dev/goldmark/wikilink"
+)
+
+// Span is a half-open byte range [Start, Stop) in a document's source.
+type Span struct {
+	Start int
+	Stop  int
+}
+
+// Contains reports whether offset lies within the span. The stop offset is
+// inclusive so that a cursor placed right after the last character still
+// counts as being inside.
+func (s Span) Contains(offset int) bool {
+	return offset >= s.Start && offset <= s.Stop
+}
+
+// Heading describes a heading in a parsed document.
+type Heading struct {
+	Level int
+	// Text is the plain text of the heading with inline markup removed.
+	Text string
+	// ID is the anchor slug generated by parser.WithAutoHeadingID.
+	ID string
+	// Span covers the whole heading including its markers.
+	Span Span
+	// TextSpan covers only the heading content as written in the source.
+	TextSpan Span
+}
+
+// BlockKind identifies the kind of a non-heading structural block.
+type BlockKind int
+
+const (
+	BlockFrontMatter BlockKind = iota
+	BlockCode
+	BlockTable
+)
+
+// Block describes a front matter section, fenced code block or table.
+type Block struct {
+	Kind BlockKind
+	// Info is the language of a fenced code block, or the header cells of a
+	// table joined by " | ".
+	Info string
+	Span Span
+}
+
+// LinkKind identifies how a link was written in the source.
+type LinkKind int
+
+const (
+	LinkInline     LinkKind = iota // [text](dest) or [text][ref]
+	LinkImage                      // ![alt](src) or ![alt][ref]
+	LinkDefinition                 // [ref]: dest
+	LinkAuto                       // <https://...> or a bare URL
+	LinkWiki                       // [[target#fragment|label]]
+	LinkHTMLImage                  // <img src="...">
+)
+
+// Link describes a link, image or link reference definition.
+type Link struct {
+	Kind LinkKind
+	// Destination is the target as resolved by the parser. For wikilinks it
+	// is the target followed by "#fragment" when a fragment is present.
+	Destination string
+	// Reference is the label of a reference-style link or definition.
+	Reference string
+	// Span covers the whole link.
+	Span Span
+	// DestSpan covers the destination as written in the source. It is
+	// empty for reference-style links, whose destination lives in the
+	// definition.
+	DestSpan Span
+}
+
+// FootnoteRef describes a [^label] reference to a footnote.
+type FootnoteRef struct {
+	Label string
+	Span  Span
+}
+
+// Footnote describes a footnote definition. Text is the Markdown source of
+// its body, with paragraphs separated by blank lines.
+type Footnote struct {
+	Label string
+	Text  string
+	Span  Span
+}
+
+// Emoji describes a :shortcode: expanded to an emoji.
+type Emoji struct {
+	ShortName string
+	Name      string
+	Value     string
+	Span      Span
+}
+
+// Document holds the structure of a parsed Markdown document.
+type Document struct {
+	Source []byte
+	Meta   map[string]any
+	// Title is the front matter title, or the text of the first level one
+	// heading.
+	Title string
+	// Summary is the Markdown source of the first paragraph.
+	Summary      string
+	Headings     []Heading
+	Blocks       []Block
+	Links        []Link
+	FootnoteRefs []FootnoteRef
+	Footnotes    []Footnote
+	Emojis       []Emoji
+}
+
+var htmlImgSrcRegex = regexp.MustCompile(`(?is)<img\b[^>]*?\bsrc\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))[^>]*>?`)
+
+// Parse parses document with the same extensions and heading IDs used for
+// rendering and returns its structure without producing any HTML.
+func Parse(document string) *Document {
+	return DefaultRenderer().Parse(document)
+}
+
+// newDocument collects the structure of the document parsed into root.
+func newDocument(root ast.Node, source []byte, ctx parser.Context) *Document {
+	doc := &Document{Source: source}
+
+	if m, err := meta.TryGet(ctx); err == nil && m != nil {
+		doc.Meta = m
+
+		if span, ok := frontMatterSpan(source); ok {
+			doc.Blocks = append(doc.Blocks, Block{Kind: BlockFrontMatter, Span: span})
+		}
+	}
+
+	_ = ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch node := n.(type) {
+		case *ast.Heading:
+			doc.Headings = append(doc.Headings, newHeading(node, source))
+		case *ast.FencedCodeBlock:
+			info := ""
+			if lang := node.Language(source); lang != nil {
+				info = string(lang)
+			}
+
+			doc.Blocks = append(doc.Blocks, Block{Kind: BlockCode, Info: info, Span: fencedCodeSpan(node, source)})
+
+			return ast.WalkSkipChildren, nil
+		case *east.Table:
+			doc.Blocks = append(doc.Blocks, Block{Kind: BlockTable, Info: tableInfo(node, source), Span: tableSpan(node, source)})
+		case *ast.Link:
+			doc.Links = append(doc.Links, newLink(LinkInline, node.Pos(), node.Destination, node.Reference, source))
+		case *ast.Image:
+			doc.Links = append(doc.Links, newLink(LinkImage, node.Pos(), node.Destination, node.Reference, source))
+		case *ast.LinkReferenceDefinition:
+			doc.Links = append(doc.Links, newDefinition(node, source))
+		case *ast.AutoLink:
+			doc.Links = append(doc.Links, newAutoLink(node, source))
+		case *wikilink.Node:
+			doc.Links = append(doc.Links, newWikiLink(node, source))
+
+			return ast.WalkSkipChildren, nil
+		case *ast.Paragraph:
+			if doc.Summary == "" && n.Parent() == root {
+				doc.Summary = blockSource(node, source)
+			}
+		case *east.FootnoteLink:
+			doc.FootnoteRefs = append(doc.FootnoteRefs, newFootnoteRef(node, source))
+		case *east.Footnote:
+			doc.Footnotes = append(doc.Footnotes, newFootnote(node, source))
+		case *emojiast.Emoji:
+			doc.Emojis = append(doc.Emojis, newEmoji(node))
+		case *ast.HTMLBlock:
+			for i := range node.Lines().Len() {
+				seg := node.Lines().At(i)
+				doc.Links = append(doc.Links, htmlImages(source, seg.Start, seg.Stop)...)
+			}
+		case *ast.RawHTML:
+			for i := range node.Segments.Len() {
+				seg := node.Segments.At(i)
+				doc.Links = append(doc.Links, htmlImages(source, seg.Start, seg.Stop)...)
+			}
+		}
+
+		return ast.WalkContinue, nil
+	})
+
+	doc.Title = documentTitle(doc)
+
+	return doc
+}
+
+// HeadingAt returns the heading whose span contains offset.
+func (d *Document) HeadingAt(offset int) (Heading, bool) {
+	for _, h := range d.Headings {
+		if h.Span.Contains(offset) {
+			return h, true
+		}
+	}
+
+	return Heading{}, false
+}
+
+// HeadingByID returns the heading with the given anchor slug.
+func (d *Document) HeadingByID(id string) (Heading, bool) {
+	for _, h := range d.Headings {
+		if h.ID == id {
+			return h, true
+		}
+	}
+
+	return Heading{}, false
+}
+
+// FindHeading returns the heading a link fragment refers to. Fragments are
+// matched against heading IDs, and for wikilinks also against heading text,
+// ignoring case.
+func (d *Document) FindHeading(fragment string) (Heading, bool) {
+	if h, ok := d.HeadingByID(fragment); ok {
+		return h, true
+	}
+
+	for _, h := range d.Headings {
+		if strings.EqualFold(h.ID, fragment) || strings.EqualFold(h.Text, fragment) {
+			return h, true
+		}
+	}
+
+	return Heading{}, false
+}
+
+// FootnoteAt returns the definition of the footnote referenced at offset.
+func (d *Document) FootnoteAt(offset int) (Footnote, bool) {
+	for _, ref := range d.FootnoteRefs {
+		if !ref.Span.Contains(offset) {
+			continue
+		}
+
+		for _, f := range d.Footnotes {
+			if strings.EqualFold(f.Label, ref.Label) {
+				return f, true
+			}
+		}
+	}
+
+	return Footnote{}, false
+}
+
+// EmojiAt returns the emoji whose shortcode contains offset.
+func (d *Document) EmojiAt(offset int) (Emoji, bool) {
+	for _, e := range d.Emojis {
+		if e.Span.Contains(offset) {
+			return e, true
+		}
+	}
+
+	return Emoji{}, false
+}
+
+// LinkAt returns the link whose span contains offset.
+func (d *Document) LinkAt(offset int) (Link, bool) {
+	for _, l := range d.Links {
+		if l.Span.Contains(offset) {
+			return l, true
+		}
+	}
+
+	return Link{}, false
+}
+
+// SplitFragment splits a link destination into its path and "#fragment"
+// parts. The returned fragment does not include the "#".
+func SplitFragment(dest string) (path, fragment string) {
+	if idx := strings.Index(dest, "#"); idx != -1 {
+		return dest[:idx], dest[idx+1:]
+	}
+
+	return dest, ""
+}
+
+// IsExternal reports whether dest points outside the local file system,
+// e.g. an http(s) URL or a mailto: link.
+func IsExternal(dest string) bool {
+	if strings.HasPrefix(dest, "//") {
+		return true
+	}
+
+	scheme, _, found := strings.Cut(dest, ":")
+	if !found || len(scheme) < 2 {
+		// A single letter scheme is most likely a Windows drive
+		return false
+	}
+
+	for _, r := range scheme {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '+' || r == '-' || r == '.') {
+			return false
+		}
+	}
+
+	return !strings.EqualFold(scheme, "file")
+}
+
+func newHeading(node *ast.Heading, source []byte) Heading {
+	h := Heading{
+		Level: node.Level,
+		Text:  plainText(node, source),
+	}
+
+	if id, ok := node.AttributeString("id"); ok {
+		if b, ok := id.([]byte); ok {
+			h.ID = string(b)
+		}
+	}
+
+	start := node.Pos()
+	lines := node.Lines()
+
+	if lines.Len() > 0 {
+		h.TextSpan = Span{Start: lines.At(0).Start, Stop: trimLineStop(source, lines.At(lines.Len()-1).Stop)}
+	} else {
+		h.TextSpan = Span{Start: lineEnd(source, start), Stop: lineEnd(source, start)}
+	}
+
+	stop := lineEnd(source, start)
+
+	// Setext headings are underlined on the line following the text
+	if start < len(source) && source[start] != '#' && lines.Len() > 0 {
+		last := lines.At(lines.Len() - 1).Start
+		stop = lineEnd(source, nextLine(source, last))
+	}
+
+	h.Span = Span{Start: start, Stop: stop}
+
+	return h
+}
+
+func fencedCodeSpan(node *ast.FencedCodeBlock, source []byte) Span {
+	start := node.Pos()
+	last := start
+
+	if lines := node.Lines(); lines.Len() > 0 {
+		last = lines.At(lines.Len() - 1).Start
+	}
+
+	stop := lineEnd(source, last)
+
+	if next := nextLine(source, last); next < len(source) {
+		line := bytes.TrimSpace(source[next:lineEnd(source, next)])
+		if bytes.HasPrefix(line, []byte("```")) || bytes.HasPrefix(line, []byte("~~~")) {
+			stop = lineEnd(source, next)
+		}
+	}
+
+	return Span{Start: start, Stop: stop}
+}
+
+func tableSpan(node *east.Table, source []byte) Span {
+	last := node.Pos()
+	if row := node.LastChild(); row != nil && row.Pos() >= 0 {
+		last = row.Pos()
+	}
+
+	return Span{Start: node.Pos(), Stop: lineEnd(source, last)}
+}
+
+func tableInfo(node *east.Table, source []byte) string {
+	header, ok := node.FirstChild().(*east.TableHeader)
+	if !ok {
+		return ""
+	}
+
+	var cells []string
+
+	for cell := header.FirstChild(); cell != nil; cell = cell.NextSibling() {
+		cells = append(cells, plainText(cell, source))
+	}
+
+	return strings.Join(cells, " | ")
+}
+
+func newLink(kind LinkKind, start int, dest []byte, ref *ast.ReferenceLink, source []byte) Link {
+	l := Link{Kind: kind, Destination: string(dest)}
+
+	stop, destSpan := scanLink(source, start)
+	l.Span = Span{Start: start, Stop: stop}
+
+	if ref != nil {
+		l.Reference = string(ref.Value)
+	} else {
+		l.DestSpan = destSpan
+	}
+
+	return l
+}
+
+func newDefinition(node *ast.LinkReferenceDefinition, source []byte) Link {
+	l := Link{
+		Kind:        LinkDefinition,
+		Destination: string(node.Destination),
+		Reference:   string(node.Label),
+	}
+
+	start := node.Pos()
+	stop := start
+
+	if lines := node.Lines(); lines.Len() > 0 {
+		stop = trimLineStop(source, lines.At(lines.Len()-1).Stop)
+	}
+
+	l.Span = Span{Start: start, Stop: stop}
+
+	// The destination follows the first "]:" and optional whitespace
+	if idx := bytes.Index(source[start:stop], []byte("]:")); idx != -1 {
+		i := skipSpace(source, start+idx+2, stop)
+		l.DestSpan = scanDestination(source, i, stop)
+	}
+
+	return l
+}
+
+func newAutoLink(node *ast.AutoLink, source []byte) Link {
+	url := node.URL(source)
+	start := node.Pos()
+	label := node.Label(source)
+
+	l := Link{Kind: LinkAuto, Destination: string(url)}
+
+	if start >= 0 && start < len(source) && source[start] == '<' {
+		l.Span = Span{Start: start, Stop: start + len(label) + 2}
+		l.DestSpan = Span{Start: start + 1, Stop: start + 1 + len(label)}
+	} else {
+		l.Span = Span{Start: start, Stop: start + len(label)}
+		l.DestSpan = l.Span
+	}
+
+	return l
+}
+
+func newWikiLink(node *wikilink.Node, source []byte) Link {
+	dest := string(node.Target)
+	if len(node.Fragment) > 0 {
+		dest += "#" + string(node.Fragment)
+	}
+
+	start := node.Pos()
+	open := start + 2
+
+	if start < len(source) && source[start] == '!' {
+		open++
+	}
+
+	stop := open
+	if idx := bytes.Index(source[open:], []byte("]]")); idx != -1 {
+		stop = open + idx + 2
+	}
+
+	destStop := stop - 2
+	if idx := bytes.IndexByte(source[open:destStop], '|'); idx != -1 {
+		destStop = open + idx
+	}
+
+	return Link{
+		Kind:        LinkWiki,
+		Destination: dest,
+		Span:        Span{Start: start, Stop: stop},
+		DestSpan:    Span{Start: open, Stop: destStop},
+	}
+}
+
+// htmlImages finds <img src="..."> occurrences in raw HTML between start and stop.
+func htmlImages(source []byte, start, stop int) []Link {
+	var links []Link
+
+	for _, m := range htmlImgSrcRegex.FindAllSubmatchIndex(source[start:stop], -1) {
+		for g := 1; g <= 3; g++ {
+			if m[2*g] < 0 {
+				continue
+			}
+
+			links = append(links, Link{
+				Kind:        LinkHTMLImage,
+				Destination: string(source[start+m[2*g] : start+m[2*g+1]]),
+				Span:        Span{Start: start + m[0], Stop: start + m[1]},
+				DestSpan:    Span{Start: start + m[2*g], Stop: start + m[2*g+1]},
+			})
+
+			break
+		}
+	}
+
+	return links
+}
+
+// scanLink finds the end of a link or image starting at start ("[" or "!["),
+// and the location of its inline destination if it has one.
+func scanLink(source []byte, start int) (int, Span) {
+	i := start
+	if i < len(source) && source[i] == '!' {
+		i++
+	}
+
+	labelEnd := matchBracket(source, i, '[', ']')
+	if labelEnd == -1 {
+		return lineEnd(source, start), Span{}
+	}
+
+	i = labelEnd + 1
+	if i >= len(source) {
+		return i, Span{}
+	}
+
+	switch source[i] {
+	case '(':
+		destStart := skipSpace(source, i+1, len(source))
+		dest := scanDestination(source, destStart, len(source))
+
+		end := matchBracket(source, i, '(', ')')
+		if end == -1 {
+			return dest.Stop, dest
+		}
+
+		return end + 1, dest
+	case '[':
+		if end := matchBracket(source, i, '[', ']'); end != -1 {
+			return end + 1, Span{}
+		}
+	}
+
+	return i, Span{}
+}
+
+// scanDestination returns the span of a link destination starting at i,
+// excluding surrounding angle brackets.
+func scanDestination(source []byte, i, limit int) Span {
+	if i < limit && source[i] == '<' {
+		if end := bytes.IndexByte(source[i+1:limit], '>'); end != -1 {
+			return Span{Start: i + 1, Stop: i + 1 + end}
+		}
+	}
+
+	depth := 0
+	j := i
+
+	for ; j < limit; j++ {
+		c := source[j]
+		if c == '\\' && j+1 < limit {
+			j++
+
+			continue
+		}
+
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			break
+		}
+
+		if c == '(' {
+			depth++
+		} else if c == ')' {
+			if depth == 0 {
+				break
+			}
+
+			depth--
+		}
+	}
+
+	return Span{Start: i, Stop: j}
+}
+
+// matchBracket returns the index of the bracket closing the one at i,
+// honouring nesting, backslash escapes and quoted titles inside parentheses.
+func matchBracket(source []byte, i int, open, closing byte) int {
+	depth := 0
+
+	var quote byte
+
+	for j := i; j < len(source); j++ {
+		c := source[j]
+
+		switch {
+		case c == '\\':
+			j++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case open == '(' && depth > 0 && (c == '"' || c == '\'') && j > 0 && isSpace(source[j-1]):
+			quote = c
+		case c == open:
+			depth++
+		case c == closing:
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+
+	return -1
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func skipSpace(source []byte, i, limit int) int {
+	for i < limit && isSpace(source[i]) {
+		i++
+	}
+
+	return i
+}
+
+// lineEnd returns the offset of the end of the line containing offset,
+// excluding the line terminator.
+func lineEnd(source []byte, offset int) int {
+	if offset >= len(source) {
+		return len(source)
+	}
+
+	end := len(source)
+	if idx := bytes.IndexByte(source[offset:], '\n'); idx != -1 {
+		end = offset + idx
+	}
+
+	if end > offset && source[end-1] == '\r' {
+		end--
+	}
+
+	return end
+}
+
+// nextLine returns the offset where the line following offset starts.
+func nextLine(source []byte, offset int) int {
+	if idx := bytes.IndexByte(source[min(offset, len(source)):], '\n'); idx != -1 {
+		return offset + idx + 1
+	}
+
+	return len(source)
+}
+
+// trimLineStop moves stop back over a trailing line terminator.
+func trimLineStop(source []byte, stop int) int {
+	stop = min(stop, len(source))
+	for stop > 0 && (source[stop-1] == '\n' || source[stop-1] == '\r') {
+		stop--
+	}
+
+	return stop
+}
+
+// frontMatterSpan locates the YAML front matter recognised by goldmark-meta:
+// a line of dashes at the very start of the document up to the next one.
+func frontMatterSpan(source []byte) (Span, bool) {
+	first := lineEnd(source, 0)
+	if !isDashLine(source[:first]) {
+		return Span{}, false
+	}
+
+	for i := nextLine(source, 0); i < len(source); i = nextLine(source, i) {
+		end := lineEnd(source, i)
+		if isDashLine(source[i:end]) {
+			return Span{Start: 0, Stop: end}, true
+		}
+	}
+
+	return Span{Start: 0, Stop: len(source)}, true
+}
+
+func isDashLine(line []byte) bool {
+	line = bytes.TrimSpace(line)
+
+	return len(line) > 0 && len(bytes.Trim(line, "-")) == 0
+}
+
+// plainText concatenates the text content of n's descendants.
+func plainText(n ast.Node, source []byte) string {
+	var sb strings.Builder
+
+	_ = ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch t := c.(type) {
+		case *ast.Text:
+			sb.Write(t.Segment.Value(source))
+
+			if t.SoftLineBreak() || t.HardLineBreak() {
+				sb.WriteByte(' ')
+			}
+		case *ast.String:
+			// Code strings are raw HTML added by transformers, such as
+			// heading numbers
+			if !t.IsCode() {
+				sb.Write(t.Value)
+			}
+		}
+
+		return ast.WalkContinue, nil
+	})
+
+	return strings.TrimSpace(sb.String())
+}
+
+func documentTitle(doc *Document) string {
+	if title, ok := doc.Meta["title"].(string); ok && title != "" {
+		return title
+	}
+
+	for _, h := range doc.Headings {
+		if h.Level == 1 {
+			return h.Text
+		}
+	}
+
+	return ""
+}
+
+func newFootnoteRef(node *east.FootnoteLink, source []byte) FootnoteRef {
+	start := node.Pos()
+	stop := start
+
+	if idx := bytes.IndexByte(source[start:], ']'); idx != -1 {
+		stop = start + idx + 1
+	}
+
+	label := strings.TrimPrefix(strings.TrimSuffix(string(source[start:stop]), "]"), "[^")
+
+	return FootnoteRef{Label: label, Span: Span{Start: start, Stop: stop}}
+}
+
+func newFootnote(node *east.Footnote, source []byte) Footnote {
+	f := Footnote{Label: string(node.Ref), Span: Span{Start: node.Pos(), Stop: node.Pos()}}
+
+	var paragraphs []string
+
+	for c := node.FirstChild(); c != nil; c = c.NextSibling() {
+		if text := blockSource(c, source); text != "" {
+			paragraphs = append(paragraphs, text)
+		}
+
+		if lines := c.Lines(); lines.Len() > 0 {
+			f.Span.Stop = lines.At(lines.Len() - 1).Stop
+		}
+	}
+
+	f.Text = strings.Join(paragraphs, "\n\n")
+
+	return f
+}
+
+func newEmoji(node *emojiast.Emoji) Emoji {
+	start := node.Pos()
+
+	e := Emoji{
+		ShortName: string(node.ShortName),
+		Span:      Span{Start: start, Stop: start + len(node.ShortName) + 2},
+	}
+
+	if node.Value != nil {
+		e.Name = node.Value.Name
+		e.Value = string(node.Value.Unicode)
+	}
+
+	return e
+}
+
+// blockSource returns the source lines of a leaf block with surrounding
+// whitespace trimmed.
+func blockSource(n ast.Node, source []byte) string {
+	lines := n.Lines()
+	if lines.Len() == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+
+	for i := range lines.Len() {
+		seg := lines.At(i)
+		sb.Write(seg.Value(source))
+	}
+
+	return strings.TrimSpace(sb.String())
+}
@@ -0,0 +1,218 @@
+package parser //nolint:revive
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func spanText(doc *Document, s Span) string {
+	return string(doc.Source[s.Start:s.Stop])
+}
+
+func TestParse_Headings(t *testing.T) { //nolint:paralleltest // Modifies global extensions cache
+	resetExtensionsCache()
+
+	markdown := "# Getting *started*\n\nText\n\nSetext title\n------------\n\n## Getting started\n"
+
+	doc := Parse(markdown)
+	require.Len(t, doc.Headings, 3)
+
+	assert.Equal(t, 1, doc.Headings[0].Level)
+	assert.Equal(t, "Getting started", doc.Headings[0].Text)
+	assert.Equal(t, "getting-started", doc.Headings[0].ID)
+	assert.Equal(t, "# Getting *started*", spanText(doc, doc.Headings[0].Span))
+	assert.Equal(t, "Getting *started*", spanText(doc, doc.Headings[0].TextSpan))
+
+	assert.Equal(t, 2, doc.Headings[1].Level)
+	assert.Equal(t, "Setext title\n------------", spanText(doc, doc.Headings[1].Span))
+	assert.Equal(t, "Setext title", spanText(doc, doc.Headings[1].TextSpan))
+
+	// Duplicate headings get the same suffixed IDs as the rendered HTML
+	assert.Equal(t, "getting-started-1", doc.Headings[2].ID)
+}
+
+func TestParse_Blocks(t *testing.T) { //nolint:paralleltest // Modifies global extensions cache
+	resetExtensionsCache()
+
+	markdown := "---\ntitle: Doc\n---\n# Title\n\n```go\nfunc main() {}\n```\n\n| Name | Value |\n|------|-------|\n| a    | 1     |\n"
+
+	doc := Parse(markdown)
+	require.Len(t, doc.Blocks, 3)
+
+	assert.Equal(t, BlockFrontMatter, doc.Blocks[0].Kind)
+	assert.Equal(t, "---\ntitle: Doc\n---", spanText(doc, doc.Blocks[0].Span))
+	assert.Equal(t, "Doc", doc.Meta["title"])
+
+	assert.Equal(t, BlockCode, doc.Blocks[1].Kind)
+	assert.Equal(t, "go", doc.Blocks[1].Info)
+	assert.Equal(t, "```go\nfunc main() {}\n```", spanText(doc, doc.Blocks[1].Span))
+
+	assert.Equal(t, BlockTable, doc.Blocks[2].Kind)
+	assert.Equal(t, "Name | Value", doc.Blocks[2].Info)
+	assert.Equal(t, "| Name | Value |\n|------|-------|\n| a    | 1     |", spanText(doc, doc.Blocks[2].Span))
+}
+
+func TestParse_UnclosedFence(t *testing.T) { //nolint:paralleltest // Modifies global extensions cache
+	resetExtensionsCache()
+
+	doc := Parse("```\nline one\nline two")
+	require.Len(t, doc.Blocks, 1)
+
+	assert.Equal(t, "```\nline one\nline two", spanText(doc, doc.Blocks[0].Span))
+}
+
+func TestParse_Links(t *testing.T) { //nolint:paralleltest // Modifies global extensions cache
+	resetExtensionsCache()
+
+	markdown := "See [other](./other.md#intro \"Title (x)\") and ![logo](<img/my logo.png>).\n" +
+		"A [reference][ref] and <https://example.com>.\n\n" +
+		"<img alt=\"x\" src='diagram.svg'>\n\n" +
+		"[ref]: docs/ref.md\n"
+
+	doc := Parse(markdown)
+	require.Len(t, doc.Links, 6)
+
+	tests := []struct {
+		kind LinkKind
+		dest string
+		ref  string
+		span string
+		at   string
+	}{
+		{LinkInline, "./other.md#intro", "", `[other](./other.md#intro "Title (x)")`, "./other.md#intro"},
+		{LinkImage, "img/my logo.png", "", "![logo](<img/my logo.png>)", "img/my logo.png"},
+		{LinkInline, "docs/ref.md", "ref", "[reference][ref]", ""},
+		{LinkAuto, "https://example.com", "", "<https://example.com>", "https://example.com"},
+		{LinkHTMLImage, "diagram.svg", "", `<img alt="x" src='diagram.svg'>`, "diagram.svg"},
+		{LinkDefinition, "docs/ref.md", "ref", "[ref]: docs/ref.md", "docs/ref.md"},
+	}
+
+	for i, tt := range tests {
+		l := doc.Links[i]
+		assert.Equal(t, tt.kind, l.Kind, "link %d", i)
+		assert.Equal(t, tt.dest, l.Destination, "link %d", i)
+		assert.Equal(t, tt.ref, l.Reference, "link %d", i)
+		assert.Equal(t, tt.span, spanText(doc, l.Span), "link %d", i)
+		assert.Equal(t, tt.at, spanText(doc, l.DestSpan), "link %d", i)
+	}
+
+	link, ok := doc.LinkAt(10)
+	require.True(t, ok)
+	assert.Equal(t, "./other.md#intro", link.Destination)
+
+	_, ok = doc.LinkAt(0)
+	assert.False(t, ok)
+}
+
+func TestParse_WikiLinks(t *testing.T) { //nolint:paralleltest // Modifies global extensions cache
+	resetExtensionsCache()
+
+	EnableWikiLinks = true
+
+	defer resetExtensionsCache()
+
+	doc := Parse("Read [[Design Notes#Rollout|the notes]] and [[Glossary]].")
+	require.Len(t, doc.Links, 2)
+
+	assert.Equal(t, LinkWiki, doc.Links[0].Kind)
+	assert.Equal(t, "Design Notes#Rollout", doc.Links[0].Destination)
+	assert.Equal(t, "[[Design Notes#Rollout|the notes]]", spanText(doc, doc.Links[0].Span))
+	assert.Equal(t, "Design Notes#Rollout", spanText(doc, doc.Links[0].DestSpan))
+
+	assert.Equal(t, "Glossary", doc.Links[1].Destination)
+	assert.Equal(t, "Glossary", spanText(doc, doc.Links[1].DestSpan))
+}
+
+func TestParse_TitleAndSummary(t *testing.T) { //nolint:paralleltest // Modifies global extensions cache
+	resetExtensionsCache()
+
+	doc := Parse("# Install\n\nRun the *installer*\nand restart.\n\nMore text.\n")
+	assert.Equal(t, "Install", doc.Title)
+	assert.Equal(t, "Run the *installer*\nand restart.", doc.Summary)
+
+	doc = Parse("---\ntitle: Setup guide\n---\n\n> Quoted\n\n# Install\n")
+	assert.Equal(t, "Setup guide", doc.Title)
+	assert.Empty(t, doc.Summary)
+}
+
+func TestParse_Footnotes(t *testing.T) { //nolint:paralleltest // Modifies global extensions cache
+	resetExtensionsCache()
+
+	EnableFootnotes = true
+
+	defer resetExtensionsCache()
+
+	doc := Parse("See the note[^note] here.\n\n[^note]: First paragraph\n    continues.\n\n    Second paragraph.\n")
+	require.Len(t, doc.FootnoteRefs, 1)
+	require.Len(t, doc.Footnotes, 1)
+
+	assert.Equal(t, "note", doc.FootnoteRefs[0].Label)
+	assert.Equal(t, "[^note]", spanText(doc, doc.FootnoteRefs[0].Span))
+	assert.Equal(t, "First paragraph\ncontinues.\n\nSecond paragraph.", doc.Footnotes[0].Text)
+
+	f, ok := doc.FootnoteAt(doc.FootnoteRefs[0].Span.Start + 2)
+	require.True(t, ok)
+	assert.Equal(t, "note", f.Label)
+
+	_, ok = doc.FootnoteAt(0)
+	assert.False(t, ok)
+}
+
+func TestParse_Emoji(t *testing.T) { //nolint:paralleltest // Modifies global extensions cache
+	resetExtensionsCache()
+
+	EnableEmoji = true
+
+	defer resetExtensionsCache()
+
+	doc := Parse("Ship it :rocket: now")
+	require.Len(t, doc.Emojis, 1)
+
+	e, ok := doc.EmojiAt(10)
+	require.True(t, ok)
+	assert.Equal(t, "rocket", e.ShortName)
+	assert.Equal(t, "rocket", e.Name)
+	assert.Equal(t, "\U0001F680", e.Value)
+	assert.Equal(t, ":rocket:", spanText(doc, e.Span))
+}
+
+func TestSplitFragment(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		dest, path, fragment string
+	}{
+		{"other.md#intro", "other.md", "intro"},
+		{"#intro", "", "intro"},
+		{"other.md", "other.md", ""},
+	}
+
+	for _, tt := range tests {
+		path, fragment := SplitFragment(tt.dest)
+		assert.Equal(t, tt.path, path)
+		assert.Equal(t, tt.fragment, fragment)
+	}
+}
+
+func TestIsExternal(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		dest     string
+		expected bool
+	}{
+		{"https://example.com", true},
+		{"mailto:user@example.com", true},
+		{"//cdn.example.com/x.png", true},
+		{"./other.md", false},
+		{"other.md#a:b", false},
+		{"C:/docs/other.md", false},
+		{"file:///tmp/other.md", false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, IsExternal(tt.dest), tt.dest)
+	}
+}
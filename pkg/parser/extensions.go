@@ -9,11 +9,11 @@ import (
 	"github.com/yuin/goldmark/extension"
 )
 
-func defaultExtensions() []goldmark.Extender {
+func defaultExtensions(cfg renderConfig) []goldmark.Extender {
 	return []goldmark.Extender{
 		extension.GFM,
 		highlighting.NewHighlighting(
-			highlighting.WithStyle(CodeHighlightingStyle),
+			highlighting.WithStyle(cfg.codeStyle),
 		),
 		meta.Meta,
 		&GitHubAlertExtension{},
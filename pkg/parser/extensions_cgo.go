@@ -9,14 +9,19 @@ import (
 	"github.com/yuin/goldmark/extension"
 )
 
-func defaultExtensions() []goldmark.Extender {
-	return []goldmark.Extender{
+func defaultExtensions(cfg renderConfig) []goldmark.Extender {
+	extensions := []goldmark.Extender{
 		extension.GFM,
 		highlighting.NewHighlighting(
-			highlighting.WithStyle(CodeHighlightingStyle),
+			highlighting.WithStyle(cfg.codeStyle),
 		),
 		meta.Meta,
-		&mplsKatexExtender{},
 		&GitHubAlertExtension{},
 	}
+
+	if cfg.math {
+		extensions = append(extensions, &mplsKatexExtender{})
+	}
+
+	return extensions
 }
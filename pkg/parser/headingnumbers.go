@@ -0,0 +1,79 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// HeadingNumberTransformer numbers the document's headings, "1", "1.1",
+// "1.2" and so on. Numbering starts at the highest heading level used, but a
+// single level 1 heading is treated as the document title and left out.
+// Headings inside lists and blockquotes are not numbered.
+type HeadingNumberTransformer struct{}
+
+func (t *HeadingNumberTransformer) Transform(doc *ast.Document, _ text.Reader, _ parser.Context) {
+	var headings []*ast.Heading
+
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		if h, ok := n.(*ast.Heading); ok {
+			headings = append(headings, h)
+		}
+	}
+
+	if len(headings) == 0 {
+		return
+	}
+
+	base := 6
+	titles := 0
+
+	for _, h := range headings {
+		base = min(base, h.Level)
+
+		if h.Level == 1 {
+			titles++
+		}
+	}
+
+	if titles == 1 && len(headings) > 1 {
+		base = 6
+		for _, h := range headings {
+			if h.Level > 1 {
+				base = min(base, h.Level)
+			}
+		}
+	}
+
+	var counters [6]int
+
+	for _, h := range headings {
+		if h.Level < base {
+			continue
+		}
+
+		depth := h.Level - base
+		counters[depth]++
+
+		for i := depth + 1; i < len(counters); i++ {
+			counters[i] = 0
+		}
+
+		parts := make([]string, 0, depth+1)
+		for _, c := range counters[:depth+1] {
+			parts = append(parts, strconv.Itoa(c))
+		}
+
+		number := ast.NewString([]byte(`<span class="mpls-heading-number">` + strings.Join(parts, ".") + `</span> `))
+		number.SetCode(true)
+
+		if h.FirstChild() != nil {
+			h.InsertBefore(h, h.FirstChild(), number)
+		} else {
+			h.AppendChild(h, number)
+		}
+	}
+}
@@ -2,10 +2,21 @@
 
 package parser //nolint:revive
 
-import "sync"
+import (
+	"runtime/debug"
+	"sync"
+)
 
 const katexMaxCacheSize = 256
 
+// katexModule renders the equations, with the KaTeX version it embeds.
+const katexModule = "github.com/FurqanSoftware/goldmark-katex"
+
+// katexDiskPrefix starts the keys of equations on disk. Unlike the cache in
+// memory, the disk cache outlives upgrades, so its keys include the version
+// of the module that rendered the equations.
+var katexDiskPrefix = "katex:" + moduleVersion(katexModule) + ":"
+
 // katexCache is the process-level KaTeX render cache.
 // Keys use a type prefix: "i:" for inline, "b:" for block.
 var (
@@ -13,16 +24,33 @@ var (
 	katexCacheMu sync.RWMutex
 )
 
+// katexCacheGet returns the rendered equation from memory or, after a
+// restart, from DiskCache.
 func katexCacheGet(key string) ([]byte, bool) {
 	katexCacheMu.RLock()
-	defer katexCacheMu.RUnlock()
-
 	v, ok := katexCache[key]
+	katexCacheMu.RUnlock()
+
+	if ok {
+		return v, true
+	}
+
+	if v, ok = DiskCache.Get(katexDiskPrefix + key); ok {
+		katexCacheStore(key, v)
+	}
 
 	return v, ok
 }
 
 func katexCacheSet(key string, html []byte) {
+	katexCacheStore(key, html)
+
+	// The equation is shown even if it cannot be kept on disk
+	_ = DiskCache.Set(katexDiskPrefix+key, html)
+}
+
+// katexCacheStore adds a rendered equation to the cache in memory.
+func katexCacheStore(key string, html []byte) {
 	katexCacheMu.Lock()
 	defer katexCacheMu.Unlock()
 
@@ -40,6 +68,33 @@ func katexCacheSet(key string, html []byte) {
 	katexCache[key] = html
 }
 
+// moduleVersion returns the version of the module at path that mpls is
+// built with, or "unknown".
+func moduleVersion(path string) string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+
+	for _, dep := range info.Deps {
+		if dep.Path != path {
+			continue
+		}
+
+		if dep.Replace != nil {
+			dep = dep.Replace
+		}
+
+		if dep.Sum != "" {
+			return dep.Version + "+" + dep.Sum
+		}
+
+		return dep.Version
+	}
+
+	return "unknown"
+}
+
 // ClearKaTeXCache empties the render cache. Useful for tests.
 func ClearKaTeXCache() {
 	katexCacheMu.Lock()
@@ -8,6 +8,7 @@ import (
 	"sync/atomic"
 	"testing"
 
+	"github.com/mhersson/mpls/pkg/diskcache"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -62,6 +63,45 @@ func TestKaTeXCache_InlineVsDisplayKeySeparation(t *testing.T) { //nolint:parall
 	assert.Equal(t, int32(2), atomic.LoadInt32(&count), "expected zero new renders on second pass")
 }
 
+func TestKaTeXCache_Disk(t *testing.T) { //nolint:paralleltest // uses the DiskCache and testHookRender globals
+	ClearKaTeXCache()
+	resetExtensionsCache()
+
+	cache, err := diskcache.New(diskcache.Options{Dir: t.TempDir()})
+	require.NoError(t, err)
+
+	DiskCache = cache
+
+	var count int32
+
+	testHookRender = func(_ []byte, _ bool) {
+		atomic.AddInt32(&count, 1)
+	}
+
+	defer func() { DiskCache, testHookRender = nil, nil }()
+
+	first, _ := HTML("$a^2 + b^2$", "file:///disk-first.md", 0)
+
+	// After a restart only the disk cache is left
+	ClearKaTeXCache()
+
+	second, _ := HTML("$a^2 + b^2$", "file:///disk-second.md", 0)
+
+	assert.Equal(t, first, second)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&count), "expected the equation to be read from disk")
+
+	// Equations rendered by another KaTeX version are not used
+	assert.NotEqual(t, "katex:unknown:", katexDiskPrefix)
+	require.NoError(t, cache.Set("katex:i:c^2", []byte("old renderer")))
+
+	ClearKaTeXCache()
+
+	third, _ := HTML("$c^2$", "file:///disk-third.md", 0)
+
+	assert.NotContains(t, third, "old renderer")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&count))
+}
+
 func TestKaTeXCache_BoundedEviction(t *testing.T) {
 	t.Parallel()
 
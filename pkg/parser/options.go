@@ -0,0 +1,82 @@
+package parser
+
+import (
+	"bytes"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OptionsKey is the front matter key holding per-document settings.
+const OptionsKey = "mpls"
+
+// Options are the rendering settings of a single document, read from the
+// "mpls" key in its front matter. Settings that are not given follow the
+// global settings.
+type Options struct {
+	Theme          string `yaml:"theme"`
+	CodeStyle      string `yaml:"codeStyle"`
+	Math           *bool  `yaml:"math"`
+	Footnotes      *bool  `yaml:"footnotes"`
+	Emoji          *bool  `yaml:"emoji"`
+	WikiLinks      *bool  `yaml:"wikilinks"`
+	NumberHeadings bool   `yaml:"numberHeadings"`
+	// Presentation opens the document in presentation mode.
+	Presentation bool `yaml:"presentation"`
+	// SlideLevel is the deepest heading level that starts a new slide when
+	// the document has no slide markers.
+	SlideLevel int `yaml:"slideLevel"`
+}
+
+// DocumentOptions reads the per-document settings from the front matter of
+// document. Invalid settings are ignored.
+func DocumentOptions(document string) Options {
+	var fm struct {
+		Options Options `yaml:"mpls"`
+	}
+
+	_ = yaml.Unmarshal(frontMatter([]byte(document)), &fm)
+
+	return fm.Options
+}
+
+// MetaOptions reads the per-document settings from front matter already
+// returned by HTML. Invalid settings are ignored.
+func MetaOptions(meta map[string]any) Options {
+	var opts Options
+
+	if value, ok := meta[OptionsKey]; ok {
+		if data, err := yaml.Marshal(value); err == nil {
+			_ = yaml.Unmarshal(data, &opts)
+		}
+	}
+
+	return opts
+}
+
+// frontMatter returns the YAML between the front matter delimiters, or nil
+// if the document has none.
+func frontMatter(source []byte) []byte {
+	span, ok := frontMatterSpan(source)
+	if !ok {
+		return nil
+	}
+
+	start := nextLine(source, 0)
+
+	// Drop the closing delimiter, and ignore front matter that is not closed
+	stop := bytes.LastIndexByte(source[:span.Stop], '\n')
+	if stop < start || !isDashLine(source[stop+1:span.Stop]) {
+		return nil
+	}
+
+	return source[start:stop]
+}
+
+// renderConfig holds the settings that the goldmark pipeline is built from.
+type renderConfig struct {
+	codeStyle string
+	math      bool
+	footnotes bool
+	emoji     bool
+	wikiLinks bool
+}
@@ -0,0 +1,98 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocumentOptions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		document string
+		expected Options
+	}{
+		{
+			name:     "options",
+			document: "---\ntitle: Deck\nmpls:\n  theme: nord\n  presentation: true\n  slideLevel: 2\n---\n# Deck\n",
+			expected: Options{Theme: "nord", Presentation: true, SlideLevel: 2},
+		},
+		{
+			name:     "no mpls key",
+			document: "---\ntitle: Doc\n---\n# Doc\n",
+		},
+		{
+			name:     "no front matter",
+			document: "# Doc\n\nmpls:\n  theme: nord\n",
+		},
+		{
+			name:     "unclosed front matter",
+			document: "---\nmpls:\n  theme: nord\n",
+		},
+		{
+			name:     "invalid options",
+			document: "---\nmpls:\n  presentation: [1]\n---\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.expected, DocumentOptions(tt.document))
+		})
+	}
+}
+
+func TestMetaOptions(t *testing.T) {
+	t.Parallel()
+
+	// goldmark-meta decodes nested maps with interface{} keys
+	opts := MetaOptions(map[string]any{
+		"title": "Doc",
+		"mpls":  map[any]any{"codeStyle": "github", "footnotes": false, "numberHeadings": true},
+	})
+
+	assert.Equal(t, "github", opts.CodeStyle)
+	require.NotNil(t, opts.Footnotes)
+	assert.False(t, *opts.Footnotes)
+	assert.True(t, opts.NumberHeadings)
+
+	assert.Equal(t, Options{}, MetaOptions(nil))
+}
+
+func TestHTML_DocumentOptions(t *testing.T) { //nolint:paralleltest // Modifies global extensions cache
+	resetExtensionsCache()
+
+	markdown := "Text with footnote[^1] :smile:\n\n[^1]: The footnote.\n"
+
+	html, _ := HTML(markdown, "file:///test/options.md", 0)
+	assert.NotContains(t, html, `class="footnotes"`)
+	assert.Contains(t, html, ":smile:")
+
+	html, _ = HTML("---\nmpls:\n  footnotes: true\n  emoji: true\n---\n"+markdown, "file:///test/options.md", 0)
+	assert.Contains(t, html, `class="footnotes"`)
+	assert.NotContains(t, html, ":smile:")
+
+	// The global settings are not changed by a document
+	html, _ = HTML(markdown, "file:///test/options-other.md", 0)
+	assert.NotContains(t, html, `class="footnotes"`)
+}
+
+func TestHTML_NumberHeadings(t *testing.T) { //nolint:paralleltest // Modifies global extensions cache
+	resetExtensionsCache()
+
+	markdown := "---\nmpls:\n  numberHeadings: true\n---\n# Title\n\n## Intro\n\n## Usage\n\n### Install\n\n## Notes\n"
+
+	html, _ := HTML(markdown, "file:///test/numbers.md", 0)
+
+	assert.Contains(t, html, `">Title</h1>`, "a single title is not numbered")
+	assert.Contains(t, html, `<span class="mpls-heading-number">1</span> Intro`)
+	assert.Contains(t, html, `<span class="mpls-heading-number">2</span> Usage`)
+	assert.Contains(t, html, `<span class="mpls-heading-number">2.1</span> Install`)
+	assert.Contains(t, html, `<span class="mpls-heading-number">3</span> Notes`)
+	assert.Contains(t, html, `<h2 id="usage"`, "heading IDs are not changed")
+}
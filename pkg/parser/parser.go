@@ -1,26 +1,17 @@
 package parser
 
 import (
-	"bytes"
-	"fmt"
-	"html"
+	"context"
 	"net/url"
 	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
-	"sync"
 
-	"github.com/yuin/goldmark"
-	emoji "github.com/yuin/goldmark-emoji"
-	meta "github.com/yuin/goldmark-meta"
+	"github.com/mhersson/mpls/pkg/diskcache"
 	"github.com/yuin/goldmark/ast"
-	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/parser"
-	goldmarkhtml "github.com/yuin/goldmark/renderer/html"
 	"github.com/yuin/goldmark/text"
-	"github.com/yuin/goldmark/util"
-	"go.abhg.dev/goldmark/wikilink"
 )
 
 const (
@@ -29,40 +20,23 @@ const (
 )
 
 var (
-	oldDocContentByURI    map[string]map[string]string // URI -> content map
-	oldDocContentMutex    sync.RWMutex                 // Protects oldDocContentByURI
 	CodeHighlightingStyle string
 	EnableWikiLinks       bool
-	WorkspaceRoot         string
 
 	EnableFootnotes bool
 	EnableEmoji     bool
 
-	// Cached goldmark extensions (initialized once at first use).
-	cachedExtensions []goldmark.Extender
-	extensionsOnce   sync.Once
+	// DiskCache keeps rendered math between sessions. Math is only cached
+	// in memory if nil.
+	DiskCache *diskcache.Cache
 )
 
-// getExtensions returns the cached goldmark extensions.
-// Extensions are initialized once on first call since config is set at startup
-// and never changes during runtime.
-func getExtensions() []goldmark.Extender {
-	extensionsOnce.Do(func() {
-		cachedExtensions = defaultExtensions()
-		if EnableWikiLinks {
-			cachedExtensions = append(cachedExtensions, &wikilink.Extender{})
-		}
-
-		if EnableFootnotes {
-			cachedExtensions = append(cachedExtensions, extension.Footnote)
-		}
-
-		if EnableEmoji {
-			cachedExtensions = append(cachedExtensions, emoji.Emoji)
-		}
-	})
-
-	return cachedExtensions
+// ResetExtensions discards the cached extensions so that the next render
+// picks up changes to CodeHighlightingStyle and the Enable* settings.
+func ResetExtensions() {
+	defaultState.extensionsMutex.Lock()
+	defaultState.extensions = nil
+	defaultState.extensionsMutex.Unlock()
 }
 
 func getDocDir(uri string) string {
@@ -91,8 +65,17 @@ func NormalizePath(uri string) string {
 }
 
 type ScrollIDTransformer struct {
+	ctx        context.Context //nolint:containedctx // Transform has no context parameter
 	currentURI string
-	changeLine int // Source line where change occurred (1-based, 0 = use content diff)
+	changeLine int               // Source line where change occurred (1-based, 0 = use content diff)
+	state      *rendererState    // Holds the content of the previous render
+	pending    map[string]string // Content of this render, stored by commit
+}
+
+// cancelled reports whether ctx, which may be nil, is done, so that the
+// transformers stop early when a render is abandoned.
+func cancelled(ctx context.Context) bool {
+	return ctx != nil && ctx.Err() != nil
 }
 
 // buildLineIndex pre-computes line start offsets for fast lookups.
@@ -126,12 +109,16 @@ func offsetToLineWithIndex(lineIndex []int, offset int) int {
 }
 
 // findBlockAtLine finds the deepest structural block element at the given line.
-func findBlockAtLine(doc *ast.Document, source []byte, targetLine int) ast.Node {
+func findBlockAtLine(ctx context.Context, doc *ast.Document, source []byte, targetLine int) ast.Node {
 	var target ast.Node
 
 	lineIndex := buildLineIndex(source)
 
 	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if cancelled(ctx) {
+			return ast.WalkStop, nil
+		}
+
 		if !entering {
 			return ast.WalkContinue, nil
 		}
@@ -188,9 +175,13 @@ func findBlockAtLine(doc *ast.Document, source []byte, targetLine int) ast.Node
 func (t *ScrollIDTransformer) Transform(doc *ast.Document, reader text.Reader, _ parser.Context) {
 	source := reader.Source()
 
+	if cancelled(t.ctx) {
+		return
+	}
+
 	// If we have a specific change line, use line-based targeting
 	if t.changeLine > 0 {
-		target := findBlockAtLine(doc, source, t.changeLine)
+		target := findBlockAtLine(t.ctx, doc, source, t.changeLine)
 		if target != nil {
 			target.SetAttribute([]byte("data-"+ScrollAnchor), []byte("true"))
 
@@ -203,26 +194,17 @@ func (t *ScrollIDTransformer) Transform(doc *ast.Document, reader text.Reader, _
 	changedNodes := make(map[ast.Node]bool)
 
 	// Get the old content for this specific document (with read lock)
-	oldDocContentMutex.RLock()
-
-	if oldDocContentByURI == nil {
-		oldDocContentMutex.RUnlock()
-		oldDocContentMutex.Lock()
-		// Double-check after acquiring write lock
-		if oldDocContentByURI == nil {
-			oldDocContentByURI = make(map[string]map[string]string)
-		}
-		oldDocContentMutex.Unlock()
-		oldDocContentMutex.RLock()
-	}
-
-	oldDocContent := oldDocContentByURI[t.currentURI]
-
-	oldDocContentMutex.RUnlock()
+	t.state.contentMutex.RLock()
+	oldDocContent := t.state.content[t.currentURI]
+	t.state.contentMutex.RUnlock()
 
 	var walk func(ast.Node, string)
 
 	walk = func(n ast.Node, path string) {
+		if cancelled(t.ctx) {
+			return
+		}
+
 		key := path + ":" + n.Kind().String()
 		content := string(n.Text(reader.Source())) //nolint:staticcheck // Using deprecated API; refactoring would be extensive
 		currentDocContent[key] = content
@@ -266,11 +248,13 @@ func (t *ScrollIDTransformer) Transform(doc *ast.Document, reader text.Reader, _
 
 	walk(doc, "")
 
-	if len(changedNodes) == 0 {
-		oldDocContentMutex.Lock()
-		oldDocContentByURI[t.currentURI] = currentDocContent
-		oldDocContentMutex.Unlock()
+	if cancelled(t.ctx) {
+		return
+	}
+
+	t.pending = currentDocContent
 
+	if len(changedNodes) == 0 {
 		return
 	}
 
@@ -281,6 +265,10 @@ func (t *ScrollIDTransformer) Transform(doc *ast.Document, reader text.Reader, _
 	var lastStructural ast.Node
 
 	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if cancelled(t.ctx) {
+			return ast.WalkStop, nil
+		}
+
 		if !entering {
 			return ast.WalkContinue, nil
 		}
@@ -311,38 +299,62 @@ func (t *ScrollIDTransformer) Transform(doc *ast.Document, reader text.Reader, _
 	if target != nil {
 		target.SetAttribute([]byte("data-"+ScrollAnchor), []byte("true"))
 	}
+}
+
+// commit stores the content of the render for the next render of the
+// document to compare with. It is called once the render has finished, so
+// that abandoned renders leave no content behind.
+func (t *ScrollIDTransformer) commit() {
+	if t.pending == nil {
+		return
+	}
+
+	t.state.contentMutex.Lock()
+	defer t.state.contentMutex.Unlock()
+
+	if t.state.content == nil {
+		t.state.content = make(map[string]map[string]string)
+	}
 
-	oldDocContentMutex.Lock()
-	oldDocContentByURI[t.currentURI] = currentDocContent
+	t.state.content[t.currentURI] = t.pending
 
-	// Evict old entries if cache exceeds limit
-	if len(oldDocContentByURI) > maxDocContentCache {
-		for k := range oldDocContentByURI {
-			delete(oldDocContentByURI, k)
+	// Evict other entries if cache exceeds limit
+	if len(t.state.content) > maxDocContentCache {
+		for k := range t.state.content {
+			if k == t.currentURI {
+				continue
+			}
+
+			delete(t.state.content, k)
 
-			if len(oldDocContentByURI) < maxDocContentCache/2 {
+			if len(t.state.content) < maxDocContentCache/2 {
 				break
 			}
 		}
 	}
-	oldDocContentMutex.Unlock()
 }
 
 type LinkResolverTransformer struct {
+	ctx        context.Context //nolint:containedctx // Transform has no context parameter
 	currentURI string
+	roots      []string
 }
 
 func CleanupDocumentContent(uri string) {
-	oldDocContentMutex.Lock()
-	defer oldDocContentMutex.Unlock()
+	defaultState.contentMutex.Lock()
+	defer defaultState.contentMutex.Unlock()
 
-	if oldDocContentByURI != nil {
-		delete(oldDocContentByURI, uri)
+	if defaultState.content != nil {
+		delete(defaultState.content, uri)
 	}
 }
 
 func (t *LinkResolverTransformer) Transform(doc *ast.Document, _ text.Reader, _ parser.Context) {
 	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if cancelled(t.ctx) {
+			return ast.WalkStop, nil
+		}
+
 		if !entering {
 			return ast.WalkContinue, nil
 		}
@@ -374,8 +386,10 @@ func (t *LinkResolverTransformer) Transform(doc *ast.Document, _ text.Reader, _
 }
 
 func (t *LinkResolverTransformer) resolveRelativeLink(dest string) string {
+	roots := t.roots
+
 	// If no workspace root, can't resolve
-	if WorkspaceRoot == "" {
+	if len(roots) == 0 {
 		return ""
 	}
 
@@ -401,25 +415,11 @@ func (t *LinkResolverTransformer) resolveRelativeLink(dest string) string {
 	absolutePath := filepath.Join(currentDir, path)
 	absolutePath = filepath.Clean(absolutePath)
 
-	// Convert to workspace-relative path
-	normalizedRoot := NormalizePath("file://" + WorkspaceRoot)
-	normalizedPath := NormalizePath("file://" + absolutePath)
-
-	// Check if path is within workspace
-	if !strings.HasPrefix(normalizedPath, normalizedRoot) {
+	relativePath := PreviewPath(roots, absolutePath)
+	if relativePath == "" {
 		return ""
 	}
 
-	relativePath, err := filepath.Rel(normalizedRoot, normalizedPath)
-	if err != nil {
-		return ""
-	}
-
-	// Ensure it starts with /
-	if !strings.HasPrefix(relativePath, "/") {
-		relativePath = "/" + relativePath
-	}
-
 	// Add anchor back if present
 	if anchor != "" {
 		relativePath += anchor
@@ -428,38 +428,34 @@ func (t *LinkResolverTransformer) resolveRelativeLink(dest string) string {
 	return relativePath
 }
 
-func HTML(document, uri string, changeLine int) (string, map[string]any) {
-	source := []byte(document)
-
-	dir := getDocDir(uri)
-
-	markdown := goldmark.New(
-		goldmark.WithExtensions(getExtensions()...),
-		goldmark.WithRendererOptions(
-			goldmarkhtml.WithUnsafe()),
-		goldmark.WithParserOptions(
-			parser.WithAutoHeadingID(),
-			parser.WithASTTransformers(
-				util.Prioritized(&ScrollIDTransformer{currentURI: uri, changeLine: changeLine}, 100),
-				util.Prioritized(&LinkResolverTransformer{currentURI: uri}, 99),
-			),
-		),
-	)
-
-	var buf bytes.Buffer
-
-	ctx := parser.NewContext()
-	if err := markdown.Convert(source, &buf, parser.WithContext(ctx)); err != nil {
-		errorHTML := fmt.Sprintf(
-			`<div class="mpls-error"><strong>Markdown parsing error:</strong><pre>%s</pre></div>`,
-			html.EscapeString(err.Error()),
-		)
-
-		return errorHTML, nil
+// ResolveLink resolves a link destination in the document at currentURI to an
+// absolute file system path and a fragment, the same way links are resolved
+// for navigation in the preview. The path is empty for anchor-only and
+// external destinations.
+func ResolveLink(currentURI, dest string) (string, string) {
+	path, fragment := SplitFragment(dest)
+	if path == "" || IsExternal(path) {
+		return "", fragment
 	}
 
-	// Convert all <img> tags with local paths to base64 data URIs
-	htmlOutput := convertHTMLImages(buf.String(), dir)
+	if decoded, err := url.PathUnescape(path); err == nil {
+		path = decoded
+	}
+
+	return filepath.Clean(filepath.Join(getDocDir(currentURI), path)), fragment
+}
+
+// HTML renders document, located at uri, with the package settings and
+// returns the HTML and the front matter. Errors are rendered as an error
+// message in the HTML.
+func HTML(document, uri string, changeLine int) (string, map[string]any) {
+	htmlOutput, meta, _ := HTMLContext(context.Background(), document, uri, changeLine)
+
+	return htmlOutput, meta
+}
 
-	return htmlOutput, meta.Get(ctx)
+// HTMLContext is HTML with a context. When ctx is done before the document
+// is rendered it returns no HTML and ctx.Err().
+func HTMLContext(ctx context.Context, document, uri string, changeLine int) (string, map[string]any, error) {
+	return DefaultRenderer().HTML(ctx, document, uri, changeLine)
 }
@@ -6,7 +6,6 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
-	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -99,23 +98,23 @@ func TestCleanupDocumentContent(t *testing.T) {
 	t.Parallel()
 
 	// Initialize the map
-	if oldDocContentByURI == nil {
-		oldDocContentByURI = make(map[string]map[string]string)
+	if defaultState.content == nil {
+		defaultState.content = make(map[string]map[string]string)
 	}
 
 	// Add some content
 	testURI := "file:///test/cleanup.md"
-	oldDocContentByURI[testURI] = map[string]string{"key": "value"}
+	defaultState.content[testURI] = map[string]string{"key": "value"}
 
 	// Verify it exists
-	_, exists := oldDocContentByURI[testURI]
+	_, exists := defaultState.content[testURI]
 	require.True(t, exists, "test setup failed: URI not in map")
 
 	// Clean up
 	CleanupDocumentContent(testURI)
 
 	// Verify it's gone
-	_, exists = oldDocContentByURI[testURI]
+	_, exists = defaultState.content[testURI]
 	assert.False(t, exists, "CleanupDocumentContent did not remove URI from map")
 }
 
@@ -123,11 +122,11 @@ func TestCleanupDocumentContent_NilMap(t *testing.T) {
 	t.Parallel()
 
 	// Save original and restore after test
-	original := oldDocContentByURI
-	oldDocContentByURI = nil
+	original := defaultState.content
+	defaultState.content = nil
 
 	defer func() {
-		oldDocContentByURI = original
+		defaultState.content = original
 	}()
 
 	// Should not panic when map is nil
@@ -142,9 +141,9 @@ func TestHTML_BasicMarkdown(t *testing.T) { //nolint:paralleltest // Modifies gl
 
 	html, meta := HTML(markdown, uri, 0)
 
-	assert.Contains(t, html, "<h1 id=\"hello-world\">")
+	assert.Contains(t, html, "<h1 id=\"hello-world\" data-mpls-lines=\"1-1\">")
 	assert.Contains(t, html, "Hello World")
-	assert.Contains(t, html, "<p>")
+	assert.Contains(t, html, "<p data-mpls-lines=\"3-3\">")
 	assert.Contains(t, html, "This is a paragraph")
 
 	// Meta should be returned (may be empty)
@@ -227,7 +226,7 @@ func TestHTML_Lists(t *testing.T) { //nolint:paralleltest // Modifies global ext
 
 	html, _ := HTML(markdown, uri, 0)
 
-	assert.Contains(t, html, "<ul>")
+	assert.Contains(t, html, "<ul data-mpls-lines=\"1-3\">")
 
 	// Count closing li tags (more reliable since opening tags may have attributes)
 	liCount := strings.Count(html, "</li>")
@@ -242,10 +241,25 @@ func TestHTML_Blockquote(t *testing.T) { //nolint:paralleltest // Modifies globa
 
 	html, _ := HTML(markdown, uri, 0)
 
-	assert.Contains(t, html, "<blockquote>")
+	assert.Contains(t, html, "<blockquote data-mpls-lines=\"1-1\">")
 	assert.Contains(t, html, "This is a quote")
 }
 
+func TestHTML_SourceLines(t *testing.T) { //nolint:paralleltest // Modifies global extensions cache
+	resetExtensionsCache()
+
+	markdown := "# Title\n\nFirst line\nsecond line\n\n- One\n- Two\n\n  Nested\n\n```go\ncode\n```\n\n---\n"
+
+	html, _ := HTML(markdown, "file:///test/lines.md", 0)
+
+	assert.Contains(t, html, `<h1 id="title" data-mpls-lines="1-1">`)
+	assert.Contains(t, html, `<p data-mpls-lines="3-4">`)
+	assert.Contains(t, html, `<ul data-mpls-lines="6-9">`)
+	assert.Contains(t, html, `<li data-mpls-lines="7-9">`)
+	assert.Contains(t, html, `<p data-mpls-lines="9-9">Nested</p>`)
+	assert.Contains(t, html, `<hr data-mpls-lines="15-15">`)
+}
+
 func TestHTML_InlineCode(t *testing.T) { //nolint:paralleltest // Modifies global extensions cache
 	resetExtensionsCache()
 
@@ -268,7 +282,7 @@ func TestHTML_Table(t *testing.T) { //nolint:paralleltest // Modifies global ext
 
 	html, _ := HTML(markdown, uri, 0)
 
-	assert.Contains(t, html, "<table>")
+	assert.Contains(t, html, "<table data-mpls-lines=\"1-3\">")
 	assert.Contains(t, html, "<th>")
 	assert.Contains(t, html, "<td>")
 }
@@ -303,12 +317,12 @@ func TestHTML_ScrollAnchor(t *testing.T) { //nolint:paralleltest // Modifies glo
 	resetExtensionsCache()
 
 	// Clear old content to trigger scroll anchor
-	if oldDocContentByURI == nil {
-		oldDocContentByURI = make(map[string]map[string]string)
+	if defaultState.content == nil {
+		defaultState.content = make(map[string]map[string]string)
 	}
 
 	uri := "file:///test/scroll.md"
-	delete(oldDocContentByURI, uri)
+	delete(defaultState.content, uri)
 
 	markdown := "# First\n\nParagraph one.\n\n# Second\n\nParagraph two."
 
@@ -333,7 +347,7 @@ func TestHTML_LineBasedScrollAnchor(t *testing.T) { //nolint:paralleltest // Mod
 	resetExtensionsCache()
 
 	uri := "file:///test/linebased.md"
-	delete(oldDocContentByURI, uri)
+	delete(defaultState.content, uri)
 
 	markdown := "# First Heading\n\nParagraph one.\n\n# Second Heading\n\nParagraph two.\n\n# Third Heading\n\nParagraph three."
 
@@ -352,7 +366,7 @@ func TestHTML_LineBasedScrollAnchor_Paragraph(t *testing.T) { //nolint:parallelt
 	resetExtensionsCache()
 
 	uri := "file:///test/linebased2.md"
-	delete(oldDocContentByURI, uri)
+	delete(defaultState.content, uri)
 
 	markdown := "# Heading\n\nFirst paragraph.\n\nSecond paragraph.\n\nThird paragraph."
 
@@ -367,7 +381,7 @@ func TestHTML_LineBasedScrollAnchor_LineAboveHeading(t *testing.T) { //nolint:pa
 	resetExtensionsCache()
 
 	uri := "file:///test/lineabove.md"
-	delete(oldDocContentByURI, uri)
+	delete(defaultState.content, uri)
 
 	// Line numbers (1-based):
 	// 1: # First Heading
@@ -393,7 +407,7 @@ func TestHTML_LineBasedScrollAnchor_FallbackToDiff(t *testing.T) { //nolint:para
 	resetExtensionsCache()
 
 	uri := "file:///test/fallback.md"
-	delete(oldDocContentByURI, uri)
+	delete(defaultState.content, uri)
 
 	markdown := "# Heading\n\nParagraph text."
 
@@ -414,20 +428,27 @@ func TestHTML_LineBasedScrollAnchor_FallbackToDiff(t *testing.T) { //nolint:para
 func TestGetExtensions_Caching(t *testing.T) { //nolint:paralleltest // Modifies global extensions cache
 	resetExtensionsCache()
 
+	cfg := DefaultRenderer().config(Options{})
+
 	// First call should initialize
-	ext1 := getExtensions()
+	ext1 := defaultState.getExtensions(cfg)
 	assert.NotNil(t, ext1, "expected non-nil extensions")
 
 	// Second call should return same cached value
-	ext2 := getExtensions()
+	ext2 := defaultState.getExtensions(cfg)
 	assert.Len(t, ext2, len(ext1), "expected cached extensions to have same length")
+
+	// Documents with their own options get their own extensions
+	enabled := true
+	ext3 := defaultState.getExtensions(DefaultRenderer().config(Options{Emoji: &enabled}))
+	assert.Len(t, ext3, len(ext1)+1, "expected the emoji extension to be added")
+	assert.Len(t, defaultState.getExtensions(cfg), len(ext1), "expected the cached extensions to be kept")
 }
 
 // resetExtensionsCache resets the extensions cache for testing.
 // This allows tests to run with fresh extension state.
 func resetExtensionsCache() {
-	extensionsOnce = sync.Once{}
-	cachedExtensions = nil
+	ResetExtensions()
 
 	// Reset feature flags to defaults
 	EnableWikiLinks = false
@@ -518,11 +539,11 @@ func TestLinkResolverTransformer_RelativeLinks(t *testing.T) { //nolint:parallel
 	resetExtensionsCache()
 
 	// Set workspace root
-	oldRoot := WorkspaceRoot
-	WorkspaceRoot = "/test"
+	oldRoots := WorkspaceRoots()
+	SetWorkspaceRoots([]string{"/test"})
 
 	defer func() {
-		WorkspaceRoot = oldRoot
+		SetWorkspaceRoots(oldRoots)
 	}()
 
 	markdown := "[Other doc](other.md)"
@@ -628,3 +649,39 @@ func BenchmarkHTML_ContentDiff_LargeDoc(b *testing.B) {
 		HTML(markdown2, uri, 0)
 	}
 }
+
+func TestResolveLink(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix paths")
+	}
+
+	tests := []struct {
+		dest, path, fragment string
+	}{
+		{"other.md", "/docs/other.md", ""},
+		{"../README.md#usage", "/README.md", "usage"},
+		{"my%20notes.md", "/docs/my notes.md", ""},
+		{"#intro", "", "intro"},
+		{"https://example.com/page#top", "", "top"},
+	}
+
+	for _, tt := range tests {
+		path, fragment := ResolveLink("file:///docs/index.md", tt.dest)
+		assert.Equal(t, tt.path, path, tt.dest)
+		assert.Equal(t, tt.fragment, fragment, tt.dest)
+	}
+}
+
+func TestResolveWikiLink(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix paths")
+	}
+
+	assert.Equal(t, "/docs/Design Notes.md", ResolveWikiLink("file:///docs/index.md", "Design Notes"))
+	assert.Equal(t, "/docs/diagram.png", ResolveWikiLink("file:///docs/index.md", "diagram.png"))
+	assert.Empty(t, ResolveWikiLink("file:///docs/index.md", ""))
+}
@@ -0,0 +1,285 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"slices"
+	"sync"
+
+	"github.com/yuin/goldmark"
+	emoji "github.com/yuin/goldmark-emoji"
+	meta "github.com/yuin/goldmark-meta"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	goldmarkhtml "github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+	"go.abhg.dev/goldmark/wikilink"
+)
+
+// RendererOptions configure a Renderer. Per-document settings in the front
+// matter of a document take precedence over them.
+type RendererOptions struct {
+	// CodeStyle is the Chroma style used for code blocks.
+	CodeStyle string
+	// WikiLinks enables [[wikilink]] syntax.
+	WikiLinks bool
+	// Footnotes enables footnote syntax.
+	Footnotes bool
+	// Emoji enables :emoji: shortcodes.
+	Emoji bool
+	// NoMath disables rendering of $math$ with KaTeX.
+	NoMath bool
+	// WorkspaceRoots are the folders that links and wikilinks are resolved
+	// within.
+	WorkspaceRoots []string
+	// Notes returns the Markdown files in the workspace, that wikilinks are
+	// resolved against. When nil, the workspace roots are walked for them.
+	Notes func() []string
+}
+
+// Renderer converts Markdown to the HTML shown in the preview. Each
+// Renderer has its own settings and caches, so several can be used side by
+// side. A Renderer is safe for concurrent use.
+type Renderer struct {
+	options RendererOptions
+	state   *rendererState
+}
+
+// Result is a rendered document.
+type Result struct {
+	HTML     string
+	Meta     map[string]any
+	Headings []Heading
+	Links    []Link
+}
+
+// rendererState is what a Renderer keeps between renders.
+type rendererState struct {
+	extensionsMutex sync.Mutex
+	extensions      map[renderConfig][]goldmark.Extender // extensions for each render configuration
+
+	contentMutex sync.RWMutex
+	content      map[string]map[string]string // URI -> content map, used to place the scroll anchor
+}
+
+// defaultState backs HTML, Parse and the other package level functions,
+// which are configured through the package variables.
+var defaultState = &rendererState{}
+
+// NewRenderer returns a Renderer with the given options.
+func NewRenderer(options RendererOptions) *Renderer {
+	options.WorkspaceRoots = slices.Clone(options.WorkspaceRoots)
+
+	return &Renderer{options: options, state: &rendererState{}}
+}
+
+// DefaultRenderer returns a Renderer for the current package settings,
+// sharing its caches with the package level functions. The settings are
+// copied, so the Renderer can be used on other goroutines while the package
+// variables change.
+func DefaultRenderer() *Renderer {
+	return &Renderer{
+		options: RendererOptions{
+			CodeStyle:      CodeHighlightingStyle,
+			WikiLinks:      EnableWikiLinks,
+			Footnotes:      EnableFootnotes,
+			Emoji:          EnableEmoji,
+			WorkspaceRoots: WorkspaceRoots(),
+			Notes:          notesSource(),
+		},
+		state: defaultState,
+	}
+}
+
+// Render converts the Markdown document source, located at uri, to HTML.
+// Local images are inlined and links to other documents in the workspace
+// are marked for the preview's navigation, exactly as in the preview. When
+// ctx is done before the document is rendered, Render returns ctx.Err().
+func (r *Renderer) Render(ctx context.Context, source, uri string) (*Result, error) {
+	return r.render(ctx, source, uri, 0)
+}
+
+// render is Render with the source line of the latest change, used to place
+// the scroll anchor. A changeLine of 0 compares with the previous render.
+// goldmark cannot be interrupted, so ctx is checked between the phases of
+// the render and by the transformers as they walk the document.
+func (r *Renderer) render(ctx context.Context, document, uri string, changeLine int) (*Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	source := []byte(document)
+
+	opts := DocumentOptions(document)
+	cfg := r.config(opts)
+
+	scrollIDs := &ScrollIDTransformer{ctx: ctx, currentURI: uri, changeLine: changeLine, state: r.state}
+
+	transformers := []util.PrioritizedValue{
+		util.Prioritized(&SourceLinesTransformer{ctx: ctx}, 90),
+		util.Prioritized(scrollIDs, 100),
+		util.Prioritized(&LinkResolverTransformer{ctx: ctx, currentURI: uri, roots: r.options.WorkspaceRoots}, 99),
+	}
+
+	if opts.NumberHeadings {
+		transformers = append(transformers, util.Prioritized(&HeadingNumberTransformer{}, 80))
+	}
+
+	markdown := goldmark.New(
+		goldmark.WithExtensions(r.state.getExtensions(cfg)...),
+		goldmark.WithRendererOptions(
+			goldmarkhtml.WithUnsafe()),
+		goldmark.WithParserOptions(
+			parser.WithAutoHeadingID(),
+			parser.WithASTTransformers(transformers...),
+		),
+	)
+
+	if cfg.wikiLinks {
+		// Takes precedence over the wikilink extension's own renderer
+		markdown.Renderer().AddOptions(renderer.WithNodeRenderers(
+			util.Prioritized(&wikiLinkRenderer{renderer: r, currentURI: uri, docs: make(map[string]*Document)}, 100),
+		))
+	}
+
+	pctx := parser.NewContext()
+	root := markdown.Parser().Parse(text.NewReader(source), parser.WithContext(pctx))
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := markdown.Renderer().Render(&buf, source, root); err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Convert all <img> tags with local paths to base64 data URIs
+	htmlOutput := convertHTMLImages(buf.String(), getDocDir(uri))
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	doc := newDocument(root, source, pctx)
+
+	// Only finished renders are compared with by the next one
+	scrollIDs.commit()
+
+	return &Result{
+		HTML:     htmlOutput,
+		Meta:     meta.Get(pctx),
+		Headings: doc.Headings,
+		Links:    doc.Links,
+	}, nil
+}
+
+// HTML renders document, located at uri, for the preview and returns the
+// HTML and the front matter. changeLine is the source line of the latest
+// change, used to place the scroll anchor, or 0 to compare with the previous
+// render. Errors are rendered as an error message in the HTML. When ctx is
+// done before the document is rendered it returns no HTML and ctx.Err().
+func (r *Renderer) HTML(ctx context.Context, document, uri string, changeLine int) (string, map[string]any, error) {
+	result, err := r.render(ctx, document, uri, changeLine)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return "", nil, ctxErr
+	}
+
+	if err != nil {
+		errorHTML := fmt.Sprintf(
+			`<div class="mpls-error"><strong>Markdown parsing error:</strong><pre>%s</pre></div>`,
+			html.EscapeString(err.Error()),
+		)
+
+		return errorHTML, nil, nil
+	}
+
+	return result.HTML, result.Meta, nil
+}
+
+// Parse parses document with the same extensions and heading IDs used for
+// rendering and returns its structure without producing any HTML.
+func (r *Renderer) Parse(document string) *Document {
+	source := []byte(document)
+
+	markdown := goldmark.New(
+		goldmark.WithExtensions(r.state.getExtensions(r.config(DocumentOptions(document)))...),
+		goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+	)
+
+	pctx := parser.NewContext()
+	root := markdown.Parser().Parse(text.NewReader(source), parser.WithContext(pctx))
+
+	return newDocument(root, source, pctx)
+}
+
+// config combines the renderer's options with per-document options.
+func (r *Renderer) config(opts Options) renderConfig {
+	cfg := renderConfig{
+		codeStyle: r.options.CodeStyle,
+		math:      !r.options.NoMath,
+		footnotes: r.options.Footnotes,
+		emoji:     r.options.Emoji,
+		wikiLinks: r.options.WikiLinks,
+	}
+
+	if opts.CodeStyle != "" {
+		cfg.codeStyle = opts.CodeStyle
+	}
+
+	for _, o := range []struct {
+		dst   *bool
+		value *bool
+	}{
+		{&cfg.math, opts.Math},
+		{&cfg.footnotes, opts.Footnotes},
+		{&cfg.emoji, opts.Emoji},
+		{&cfg.wikiLinks, opts.WikiLinks},
+	} {
+		if o.value != nil {
+			*o.dst = *o.value
+		}
+	}
+
+	return cfg
+}
+
+// getExtensions returns the cached goldmark extensions for cfg, building
+// them if needed.
+func (s *rendererState) getExtensions(cfg renderConfig) []goldmark.Extender {
+	s.extensionsMutex.Lock()
+	defer s.extensionsMutex.Unlock()
+
+	if extensions, ok := s.extensions[cfg]; ok {
+		return extensions
+	}
+
+	extensions := defaultExtensions(cfg)
+	if cfg.wikiLinks {
+		extensions = append(extensions, &wikilink.Extender{})
+	}
+
+	if cfg.footnotes {
+		extensions = append(extensions, extension.Footnote)
+	}
+
+	if cfg.emoji {
+		extensions = append(extensions, emoji.Emoji)
+	}
+
+	if s.extensions == nil {
+		s.extensions = make(map[renderConfig][]goldmark.Extender)
+	}
+
+	s.extensions[cfg] = extensions
+
+	return extensions
+}
@@ -0,0 +1,153 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+func TestRenderer_Render(t *testing.T) {
+	t.Parallel()
+
+	r := NewRenderer(RendererOptions{Footnotes: true, WorkspaceRoots: []string{"/work/docs"}})
+
+	markdown := "---\ntitle: Guide\n---\n# Guide\n\nSee [setup](setup.md) and the note[^1].\n\n## Setup\n\n[^1]: A note.\n"
+
+	result, err := r.Render(context.Background(), markdown, "file:///work/docs/guide.md")
+	require.NoError(t, err)
+
+	assert.Contains(t, result.HTML, `<h1 id="guide"`)
+	assert.Contains(t, result.HTML, `data-mpls-target="/setup.md"`)
+	assert.Contains(t, result.HTML, `class="footnotes"`)
+	assert.Equal(t, "Guide", result.Meta["title"])
+
+	require.Len(t, result.Headings, 2)
+	assert.Equal(t, "guide", result.Headings[0].ID)
+	assert.Equal(t, "Setup", result.Headings[1].Text)
+
+	require.Len(t, result.Links, 1)
+	assert.Equal(t, "setup.md", result.Links[0].Destination)
+}
+
+func TestRenderer_Independent(t *testing.T) {
+	t.Parallel()
+
+	plain := NewRenderer(RendererOptions{})
+	emoji := NewRenderer(RendererOptions{Emoji: true, Footnotes: true})
+
+	markdown := "Text with footnote[^1] :smile:\n\n[^1]: The footnote.\n"
+
+	result, err := plain.Render(context.Background(), markdown, "file:///test/independent.md")
+	require.NoError(t, err)
+	assert.Contains(t, result.HTML, ":smile:")
+	assert.NotContains(t, result.HTML, `class="footnotes"`)
+
+	result, err = emoji.Render(context.Background(), markdown, "file:///test/independent.md")
+	require.NoError(t, err)
+	assert.NotContains(t, result.HTML, ":smile:")
+	assert.Contains(t, result.HTML, `class="footnotes"`)
+}
+
+func TestRenderer_NumberedHeadings(t *testing.T) {
+	t.Parallel()
+
+	markdown := "---\nmpls:\n  numberHeadings: true\n---\n# Title\n\n## Intro\n\n## Usage\n"
+
+	result, err := NewRenderer(RendererOptions{}).Render(context.Background(), markdown, "file:///test/numbered.md")
+	require.NoError(t, err)
+
+	assert.Contains(t, result.HTML, `<span class="mpls-heading-number">2</span> Usage`)
+
+	require.Len(t, result.Headings, 3)
+	assert.Equal(t, "Usage", result.Headings[2].Text, "heading numbers are not part of the text")
+}
+
+func TestRenderer_WikiLinks(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "notes"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "notes", "Ideas.md"), []byte("# Ideas\n"), 0o600))
+
+	r := NewRenderer(RendererOptions{WikiLinks: true, WorkspaceRoots: []string{root}})
+
+	result, err := r.Render(context.Background(), "See [[Ideas]].\n", "file://"+filepath.Join(root, "index.md"))
+	require.NoError(t, err)
+
+	assert.Contains(t, result.HTML, `data-mpls-target="/notes/Ideas.md"`)
+}
+
+func TestRenderer_Canceled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := NewRenderer(RendererOptions{}).Render(ctx, "# Title\n", "file:///test/canceled.md")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestHTMLContext_Canceled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	html, meta, err := HTMLContext(ctx, "# Title\n", "file:///test/html-canceled.md", 0)
+	require.ErrorIs(t, err, context.Canceled)
+
+	assert.Empty(t, html)
+	assert.Nil(t, meta)
+}
+
+func TestScrollIDTransformer_Canceled(t *testing.T) {
+	t.Parallel()
+
+	source := []byte("# Title\n\nText.\n")
+	state := &rendererState{}
+
+	parse := func() *ast.Document {
+		doc, ok := goldmark.New().Parser().Parse(text.NewReader(source)).(*ast.Document)
+		require.True(t, ok)
+
+		return doc
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	canceled := &ScrollIDTransformer{ctx: ctx, currentURI: "file:///test/scroll.md", state: state}
+	canceled.Transform(parse(), text.NewReader(source), nil)
+	canceled.commit()
+
+	assert.Empty(t, state.content, "an abandoned render is not compared with")
+
+	finished := &ScrollIDTransformer{ctx: context.Background(), currentURI: "file:///test/scroll.md", state: state}
+	finished.Transform(parse(), text.NewReader(source), nil)
+	finished.commit()
+
+	assert.Contains(t, state.content, "file:///test/scroll.md")
+}
+
+func TestScrollIDTransformer_EvictionKeepsCurrent(t *testing.T) {
+	t.Parallel()
+
+	state := &rendererState{content: make(map[string]map[string]string)}
+	for i := range maxDocContentCache {
+		state.content[fmt.Sprintf("file:///test/other-%d.md", i)] = map[string]string{}
+	}
+
+	uri := "file:///test/current.md"
+	(&ScrollIDTransformer{currentURI: uri, state: state, pending: map[string]string{}}).commit()
+
+	assert.Less(t, len(state.content), maxDocContentCache)
+	assert.Contains(t, state.content, uri, "the document just rendered is kept")
+}
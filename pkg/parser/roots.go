@@ -0,0 +1,189 @@
+package parser
+
+import (
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	workspaceRoots []string
+	notes          func() []string
+	rootsMutex     sync.RWMutex
+)
+
+// SkippedDirs are the directories that never contain documentation worth
+// indexing. Hidden directories are skipped as well.
+var SkippedDirs = []string{"node_modules", "vendor"}
+
+// SetWorkspaceRoots sets the workspace folders that links are resolved
+// within.
+func SetWorkspaceRoots(roots []string) {
+	rootsMutex.Lock()
+	workspaceRoots = slices.Clone(roots)
+	rootsMutex.Unlock()
+}
+
+// SetNotes sets the function that lists the Markdown files in the
+// workspace for wikilink resolution, so that wikilinks resolve against the
+// same files as the rest of the workspace. When unset, the workspace roots
+// are walked.
+func SetNotes(fn func() []string) {
+	rootsMutex.Lock()
+	notes = fn
+	rootsMutex.Unlock()
+}
+
+func notesSource() func() []string {
+	rootsMutex.RLock()
+	defer rootsMutex.RUnlock()
+
+	return notes
+}
+
+// WalkWorkspace calls fn for every file under the roots, skipping hidden
+// directories and SkippedDirs. Files under nested roots are only visited
+// once.
+func WalkWorkspace(roots []string, fn func(path string, d fs.DirEntry)) {
+	visited := make(map[string]bool)
+
+	for _, root := range roots {
+		if root == "" || visited[root] {
+			continue
+		}
+
+		_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil //nolint:nilerr // Skip unreadable entries
+			}
+
+			if d.IsDir() {
+				if path != root && (visited[path] || strings.HasPrefix(d.Name(), ".") || slices.Contains(SkippedDirs, d.Name())) {
+					return filepath.SkipDir
+				}
+
+				visited[path] = true
+
+				return nil
+			}
+
+			fn(path, d)
+
+			return nil
+		})
+	}
+}
+
+// WorkspaceRoots returns the workspace folders.
+func WorkspaceRoots() []string {
+	rootsMutex.RLock()
+	defer rootsMutex.RUnlock()
+
+	return slices.Clone(workspaceRoots)
+}
+
+// RootNames returns the name of each root in preview URLs: its directory
+// name, with a number added when several roots share one.
+func RootNames(roots []string) []string {
+	names := make([]string, len(roots))
+	seen := make(map[string]int)
+
+	for i, root := range roots {
+		name := filepath.Base(root)
+
+		seen[name]++
+		if n := seen[name]; n > 1 {
+			name += "-" + strconv.Itoa(n)
+		}
+
+		names[i] = name
+	}
+
+	return names
+}
+
+// rootOf returns the index of the root containing path, the innermost one
+// if roots are nested, or -1.
+func rootOf(roots []string, path string) int {
+	found := -1
+
+	for i, root := range roots {
+		if root == "" || !withinDir(root, path) {
+			continue
+		}
+
+		if found == -1 || len(root) > len(roots[found]) {
+			found = i
+		}
+	}
+
+	return found
+}
+
+func withinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// PreviewPath returns the path of the file at absolutePath in preview URLs:
+// relative to the root containing it, with a leading slash. With more than
+// one root the path starts with the root's name, "/<root-name>/path.md".
+// Files outside the roots have no preview path and give "".
+func PreviewPath(roots []string, absolutePath string) string {
+	absolutePath = NormalizePath("file://" + absolutePath)
+
+	i := rootOf(roots, absolutePath)
+	if i == -1 {
+		return ""
+	}
+
+	rel, err := filepath.Rel(roots[i], absolutePath)
+	if err != nil {
+		return ""
+	}
+
+	path := "/" + strings.TrimPrefix(filepath.ToSlash(rel), "/")
+	if path == "/." {
+		path = "/"
+	}
+
+	if len(roots) > 1 {
+		path = "/" + RootNames(roots)[i] + strings.TrimSuffix(path, "/")
+	}
+
+	return path
+}
+
+// PreviewFile returns the file system path of a preview URL path, the
+// reverse of PreviewPath, or "" when it does not lie within a root.
+func PreviewFile(roots []string, urlPath string) string {
+	urlPath = strings.TrimPrefix(filepath.ToSlash(filepath.Clean("/"+urlPath)), "/")
+
+	if len(roots) == 0 {
+		return ""
+	}
+
+	root := roots[0]
+
+	if len(roots) > 1 {
+		name, rest, _ := strings.Cut(urlPath, "/")
+
+		i := slices.Index(RootNames(roots), name)
+		if i == -1 {
+			return ""
+		}
+
+		root, urlPath = roots[i], rest
+	}
+
+	path := filepath.Join(root, filepath.FromSlash(urlPath))
+	if !withinDir(root, path) {
+		return ""
+	}
+
+	return path
+}
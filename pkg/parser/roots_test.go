@@ -0,0 +1,96 @@
+package parser
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRootNames(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []string{"docs", "infra", "docs-2"}, RootNames([]string{"/work/docs", "/work/infra", "/old/docs"}))
+	assert.Empty(t, RootNames(nil))
+}
+
+func TestPreviewPath(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("skipping path tests on Windows")
+	}
+
+	single := []string{"/work/docs"}
+	multi := []string{"/work/docs", "/work/infra", "/work/docs/vendored"}
+
+	tests := []struct {
+		name     string
+		roots    []string
+		path     string
+		expected string
+	}{
+		{"single root", single, "/work/docs/guide/intro.md", "/guide/intro.md"},
+		{"single root outside", single, "/work/infra/README.md", ""},
+		{"sibling with common prefix", single, "/work/docs-old/README.md", ""},
+		{"multiple roots", multi, "/work/infra/README.md", "/infra/README.md"},
+		{"first of multiple roots", multi, "/work/docs/guide/intro.md", "/docs/guide/intro.md"},
+		{"nested root wins", multi, "/work/docs/vendored/api.md", "/vendored/api.md"},
+		{"multiple roots outside", multi, "/tmp/notes.md", ""},
+		{"no roots", nil, "/work/docs/README.md", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.expected, PreviewPath(tt.roots, tt.path))
+		})
+	}
+}
+
+func TestPreviewFile(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("skipping path tests on Windows")
+	}
+
+	single := []string{"/work/docs"}
+	multi := []string{"/work/docs", "/work/infra"}
+
+	tests := []struct {
+		name     string
+		roots    []string
+		urlPath  string
+		expected string
+	}{
+		{"single root", single, "/guide/intro.md", "/work/docs/guide/intro.md"},
+		{"without leading slash", single, "guide/intro.md", "/work/docs/guide/intro.md"},
+		{"traversal stays in root", single, "/../infra/README.md", "/work/docs/infra/README.md"},
+		{"multiple roots", multi, "/infra/README.md", "/work/infra/README.md"},
+		{"unknown root", multi, "/service/README.md", ""},
+		{"no roots", nil, "/README.md", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.expected, PreviewFile(tt.roots, tt.urlPath))
+		})
+	}
+}
+
+func TestHTML_LinksAcrossRoots(t *testing.T) { //nolint:paralleltest // Modifies the global workspace roots
+	resetExtensionsCache()
+
+	oldRoots := WorkspaceRoots()
+	SetWorkspaceRoots([]string{"/work/docs", "/work/infra"})
+
+	t.Cleanup(func() { SetWorkspaceRoots(oldRoots) })
+
+	html, _ := HTML("[Deploy](../infra/deploy.md)", "file:///work/docs/roots.md", 0)
+
+	assert.Contains(t, html, `data-mpls-target="/infra/deploy.md"`)
+}
@@ -0,0 +1,88 @@
+package parser
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// SourceLines is the data attribute holding the 1-based source line range,
+// "start-end", of a rendered block.
+const SourceLines = "mpls-lines"
+
+// SourceLinesTransformer marks every block with the source lines it was
+// parsed from so the preview can scroll to the block under the editor's
+// cursor. Blocks whose renderer does not output attributes, such as fenced
+// code, are covered by the closest marked block before them.
+type SourceLinesTransformer struct {
+	ctx context.Context //nolint:containedctx // Transform has no context parameter
+}
+
+func (t *SourceLinesTransformer) Transform(doc *ast.Document, reader text.Reader, _ parser.Context) {
+	lineIndex := buildLineIndex(reader.Source())
+
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if cancelled(t.ctx) {
+			return ast.WalkStop, nil
+		}
+
+		if !entering || n.Type() != ast.TypeBlock || n.Kind() == ast.KindDocument {
+			return ast.WalkContinue, nil
+		}
+
+		if n.Kind() == east.KindTableCell {
+			// Rows are precise enough
+			return ast.WalkSkipChildren, nil
+		}
+
+		if start, stop, ok := blockOffsets(n); ok {
+			first := offsetToLineWithIndex(lineIndex, start)
+			last := offsetToLineWithIndex(lineIndex, max(start, stop-1))
+
+			n.SetAttribute([]byte("data-"+SourceLines), []byte(strconv.Itoa(first)+"-"+strconv.Itoa(last)))
+		}
+
+		return ast.WalkContinue, nil
+	})
+}
+
+// blockOffsets returns the source range covered by a block's own lines or,
+// for containers like lists and blockquotes, by the blocks inside it.
+func blockOffsets(n ast.Node) (int, int, bool) {
+	if lines := n.Lines(); lines != nil && lines.Len() > 0 {
+		return lines.At(0).Start, lines.At(lines.Len() - 1).Stop, true
+	}
+
+	if !n.HasChildren() {
+		// Leaf blocks without lines, like thematic breaks, only know where
+		// they start
+		if pos := n.Pos(); pos >= 0 {
+			return pos, pos + 1, true
+		}
+
+		return 0, 0, false
+	}
+
+	start, stop, found := 0, 0, false
+
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		if c.Type() != ast.TypeBlock {
+			continue
+		}
+
+		if s, e, ok := blockOffsets(c); ok {
+			if !found || s < start {
+				start = s
+			}
+
+			stop = max(stop, e)
+			found = true
+		}
+	}
+
+	return start, stop, found
+}
@@ -0,0 +1,211 @@
+package parser
+
+import (
+	"html"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+	"go.abhg.dev/goldmark/wikilink"
+)
+
+var (
+	// MarkdownExtensions are the file extensions treated as Markdown documents.
+	MarkdownExtensions = []string{".md", ".markdown", ".mkd", ".mkdn", ".mdwn"}
+	// ImageExtensions are the image formats the preview can embed.
+	ImageExtensions = []string{".png", ".jpg", ".jpeg", ".gif", ".webp", ".svg"}
+)
+
+// ResolveWikiLink resolves the target of a [[wikilink]] in the document at
+// currentURI to a file system path. A target next to the current document
+// wins, then a note anywhere in the workspace whose name, or trailing path,
+// matches the target, first exactly and then ignoring case. Targets without
+// an extension refer to Markdown files. Unresolved targets are returned
+// relative to the current document so callers can report them as missing.
+func ResolveWikiLink(currentURI, target string) string {
+	return DefaultRenderer().ResolveWikiLink(currentURI, target)
+}
+
+// ResolveWikiLink is the package level ResolveWikiLink for the workspace
+// roots of r.
+func (r *Renderer) ResolveWikiLink(currentURI, target string) string {
+	if target == "" {
+		return ""
+	}
+
+	docDir := getDocDir(currentURI)
+	isNote := filepath.Ext(target) == "" || slices.Contains(MarkdownExtensions, filepath.Ext(target))
+
+	fallback := filepath.Clean(filepath.Join(docDir, target))
+	if filepath.Ext(target) == "" {
+		fallback += ".md"
+	}
+
+	if _, err := os.Stat(fallback); err == nil || !isNote {
+		return fallback
+	}
+
+	want := filepath.ToSlash(strings.TrimSuffix(target, filepath.Ext(target)))
+	notes := r.notes()
+
+	for _, matches := range []func(name string) bool{
+		func(name string) bool { return name == want || strings.HasSuffix(name, "/"+want) },
+		func(name string) bool {
+			return strings.EqualFold(name, want) ||
+				strings.HasSuffix(strings.ToLower(name), "/"+strings.ToLower(want))
+		},
+	} {
+		var candidates []string
+
+		for _, note := range notes {
+			name := filepath.ToSlash(strings.TrimSuffix(note, filepath.Ext(note)))
+			if matches(name) {
+				candidates = append(candidates, note)
+			}
+		}
+
+		if len(candidates) > 0 {
+			return closestPath(docDir, candidates)
+		}
+	}
+
+	return fallback
+}
+
+// notes returns the Markdown files in the workspace, from the Notes option
+// or else by walking the workspace roots.
+func (r *Renderer) notes() []string {
+	if r.options.Notes != nil {
+		return r.options.Notes()
+	}
+
+	var notes []string
+
+	WalkWorkspace(r.options.WorkspaceRoots, func(path string, _ fs.DirEntry) {
+		if slices.Contains(MarkdownExtensions, filepath.Ext(path)) {
+			notes = append(notes, path)
+		}
+	})
+
+	return notes
+}
+
+// closestPath returns the candidate with the shortest relative path from dir,
+// so notes in the same part of the tree win over ones further away.
+func closestPath(dir string, candidates []string) string {
+	distance := func(path string) int {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return len(path)
+		}
+
+		return strings.Count(filepath.ToSlash(rel), "/")
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return distance(candidates[i]) < distance(candidates[j])
+	})
+
+	return candidates[0]
+}
+
+// wikiLinkRenderer renders wikilinks as links to the notes they resolve to
+// in the workspace, marked for the preview's internal link navigation like
+// the links handled by LinkResolverTransformer. Embedded images are rendered
+// as <img> tags with an absolute path so they can be inlined.
+type wikiLinkRenderer struct {
+	renderer   *Renderer
+	currentURI string
+	docs       map[string]*Document // notes parsed to look up heading anchors
+}
+
+func (r *wikiLinkRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(wikilink.Kind, r.render)
+}
+
+func (r *wikiLinkRenderer) render(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n, ok := node.(*wikilink.Node)
+	if !ok {
+		return ast.WalkContinue, nil
+	}
+
+	target := string(n.Target)
+	image := n.Embed && slices.Contains(ImageExtensions, strings.ToLower(filepath.Ext(target)))
+
+	if !entering {
+		if !image {
+			_, _ = w.WriteString("</a>")
+		}
+
+		return ast.WalkContinue, nil
+	}
+
+	path := ""
+	if target != "" {
+		path = r.renderer.ResolveWikiLink(r.currentURI, target)
+	}
+
+	if image {
+		_, _ = w.WriteString(`<img src="` + html.EscapeString(path) + `" alt="`)
+		_, _ = w.Write(util.EscapeHTML([]byte(plainText(n, source))))
+		_, _ = w.WriteString(`">`)
+
+		return ast.WalkSkipChildren, nil
+	}
+
+	anchor := r.anchor(path, string(n.Fragment), source)
+
+	if path == "" {
+		_, _ = w.WriteString(`<a href="` + html.EscapeString(anchor) + `">`)
+
+		return ast.WalkContinue, nil
+	}
+
+	href := filepath.ToSlash(path) + anchor
+
+	if relative := PreviewPath(r.renderer.options.WorkspaceRoots, path); relative != "" {
+		href = relative + anchor
+		_, _ = w.WriteString(`<a href="` + html.EscapeString(href) + `" data-mpls-internal="true" data-mpls-target="` +
+			html.EscapeString(href) + `">`)
+
+		return ast.WalkContinue, nil
+	}
+
+	_, _ = w.WriteString(`<a href="` + html.EscapeString(href) + `">`)
+
+	return ast.WalkContinue, nil
+}
+
+// anchor converts a wikilink fragment, usually written as heading text, to
+// the "#slug" of the matching heading in the note at path, or in the
+// current document when path is empty.
+func (r *wikiLinkRenderer) anchor(path, fragment string, source []byte) string {
+	if fragment == "" {
+		return ""
+	}
+
+	doc, ok := r.docs[path]
+	if !ok {
+		if path == "" {
+			doc = r.renderer.Parse(string(source))
+		} else if content, err := os.ReadFile(path); err == nil { //nolint:gosec // Path is resolved within the workspace
+			doc = r.renderer.Parse(string(content))
+		}
+
+		r.docs[path] = doc
+	}
+
+	if doc != nil {
+		if h, ok := doc.FindHeading(fragment); ok {
+			return "#" + h.ID
+		}
+	}
+
+	return "#" + fragment
+}
@@ -0,0 +1,113 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupNotes writes files below a temporary workspace root and points
+// the workspace roots at it.
+func setupNotes(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	root := t.TempDir()
+
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+
+	previousRoots := WorkspaceRoots()
+	SetWorkspaceRoots([]string{root})
+
+	t.Cleanup(func() {
+		SetWorkspaceRoots(previousRoots)
+	})
+
+	return root
+}
+
+func TestResolveWikiLink_Workspace(t *testing.T) { //nolint:paralleltest // Modifies global workspace root
+	root := setupNotes(t, map[string]string{
+		"docs/index.md":              "",
+		"docs/Ideas.md":              "",
+		"docs/sub/Todo.md":           "",
+		"notes/Design Notes.md":      "",
+		"team/alice/Ideas.md":        "",
+		"archive/old/deep/Todo.md":   "",
+		"node_modules/pkg/Readme.md": "",
+	})
+
+	uri := "file://" + filepath.Join(root, "docs/index.md")
+
+	tests := []struct {
+		target, expected string
+	}{
+		{"Design Notes", "notes/Design Notes.md"},
+		{"design notes", "notes/Design Notes.md"},
+		{"Design Notes.md", "notes/Design Notes.md"},
+		{"Ideas", "docs/Ideas.md"},
+		{"alice/Ideas", "team/alice/Ideas.md"},
+		{"ALICE/ideas", "team/alice/Ideas.md"},
+		{"Todo", "docs/sub/Todo.md"},
+		{"Readme", "docs/Readme.md"},
+		{"Missing", "docs/Missing.md"},
+		{"diagram.png", "docs/diagram.png"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, filepath.Join(root, tt.expected), ResolveWikiLink(uri, tt.target), tt.target)
+	}
+}
+
+func TestRenderer_ResolveWikiLinkNotes(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	notes := []string{filepath.Join(root, "listed", "Ideas.md")}
+
+	r := NewRenderer(RendererOptions{
+		WorkspaceRoots: []string{root},
+		Notes:          func() []string { return notes },
+	})
+
+	uri := "file://" + filepath.Join(root, "index.md")
+
+	// Only the listed notes are matched, the roots are not walked
+	assert.Equal(t, notes[0], r.ResolveWikiLink(uri, "Ideas"))
+
+	notes = nil
+
+	assert.Equal(t, filepath.Join(root, "Ideas.md"), r.ResolveWikiLink(uri, "Ideas"))
+}
+
+func TestHTML_WikiLinksResolveInWorkspace(t *testing.T) { //nolint:paralleltest // Modifies global extensions cache
+	resetExtensionsCache()
+
+	EnableWikiLinks = true
+
+	defer resetExtensionsCache()
+
+	root := setupNotes(t, map[string]string{
+		"notes/Design Notes.md": "# Design\n\n## Rollout plan\n",
+		"docs/diagram.svg":      `<svg xmlns="http://www.w3.org/2000/svg"></svg>`,
+	})
+
+	uri := "file://" + filepath.Join(root, "docs/index.md")
+	markdown := "# Local heading\n\n" +
+		"See [[design notes#Rollout Plan|the plan]], [[#Local heading]] and [[Missing]].\n\n" +
+		"![[diagram.svg]]\n"
+
+	html, _ := HTML(markdown, uri, 0)
+
+	assert.Contains(t, html, `<a href="/notes/Design Notes.md#rollout-plan" data-mpls-internal="true" `+
+		`data-mpls-target="/notes/Design Notes.md#rollout-plan">the plan</a>`)
+	assert.Contains(t, html, `<a href="#local-heading">#Local heading</a>`)
+	assert.Contains(t, html, `<a href="/docs/Missing.md" data-mpls-internal="true"`)
+	assert.Contains(t, html, `src="data:image/svg+xml;base64,`)
+}
@@ -0,0 +1,358 @@
+package plantuml
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mhersson/mpls/pkg/diskcache"
+)
+
+// Default settings used when the corresponding Options field is zero.
+const (
+	DefaultTimeout     = 10 * time.Second
+	DefaultConcurrency = 4
+	DefaultFormat      = FormatPNG
+)
+
+// Image formats that diagrams can be requested in.
+const (
+	FormatPNG = "png"
+	FormatSVG = "svg"
+)
+
+// maxErrorMessage is the longest part of an error response kept in a
+// StatusError.
+const maxErrorMessage = 200
+
+// Options configures a Client.
+type Options struct {
+	// BaseURL is the URL of the server including its base path, such as
+	// "https://www.plantuml.com/plantuml".
+	BaseURL string
+	// Format is the image format requested from the server. SVG diagrams
+	// are inlined in the HTML, so they scale and their text can be
+	// selected.
+	Format string
+	// Skinparams are added to every diagram after its @start line, e.g.
+	// "skinparam monochrome reverse" for dark themes.
+	Skinparams string
+	// Command renders diagrams locally instead of on the server. The
+	// diagram source is written to its standard input and the image read
+	// from its standard output, e.g. {"plantuml", "-pipe"}.
+	Command []string
+	// Concurrency is the maximum number of diagrams requested or rendered
+	// with the command at once.
+	Concurrency int
+	// Timeout applies to each request or command.
+	Timeout time.Duration
+	// Header is added to every request, e.g. for authentication.
+	Header http.Header
+	// Client sends the requests. http.DefaultClient is used if nil.
+	Client *http.Client
+	// DiskCache keeps diagrams between sessions, in addition to the cache
+	// in memory. Diagrams are only kept in memory if nil.
+	DiskCache *diskcache.Cache
+}
+
+// Client requests diagrams from a PlantUML server, or renders them with a
+// local command. Diagrams are cached, so each one is only rendered once. A
+// Client is safe for concurrent use.
+type Client struct {
+	opts   Options
+	client *http.Client
+	cache  *diagramCache
+	limit  chan struct{} // limits the diagrams rendered at once
+	// keyPrefix tells apart the cached diagrams of clients that render
+	// them differently
+	keyPrefix string
+}
+
+// StatusError is returned when the server responds to a diagram request
+// with a status other than 2xx.
+type StatusError struct {
+	StatusCode int
+	// Message is the start of a plain text response, which often explains
+	// what went wrong.
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	msg := fmt.Sprintf("plantuml server returned %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+
+	return msg
+}
+
+func NewClient(opts Options) *Client {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultConcurrency
+	}
+
+	if opts.Format == "" {
+		opts.Format = DefaultFormat
+	}
+
+	client := opts.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &Client{
+		opts:      opts,
+		client:    client,
+		cache:     &diagramCache{},
+		limit:     make(chan struct{}, opts.Concurrency),
+		keyPrefix: cacheKeyPrefix(opts),
+	}
+}
+
+// cacheKeyPrefix returns the start of the cache keys of a client: its
+// format and a hash of the server, including its base path, and the
+// command that render the diagrams.
+func cacheKeyPrefix(opts Options) string {
+	h := sha256.New()
+	h.Write([]byte(opts.BaseURL))
+
+	for _, arg := range opts.Command {
+		h.Write([]byte{0})
+		h.Write([]byte(arg))
+	}
+
+	return opts.Format + "/" + hex.EncodeToString(h.Sum(nil)[:8]) + "/"
+}
+
+// The diagram cache and concurrency limit of the package level functions.
+var (
+	defaultCache      = &diagramCache{}
+	defaultLimit      chan struct{}
+	defaultLimitMutex sync.Mutex
+)
+
+// sharedLimit returns the concurrency limit of the package level functions,
+// replacing it when the number of diagrams allowed at once has changed.
+func sharedLimit(n int) chan struct{} {
+	defaultLimitMutex.Lock()
+	defer defaultLimitMutex.Unlock()
+
+	if cap(defaultLimit) != n {
+		defaultLimit = make(chan struct{}, n)
+	}
+
+	return defaultLimit
+}
+
+// DefaultClient returns a Client for the package settings, sharing its cache
+// and concurrency limit with the package level functions. The settings are
+// copied, so the Client can be used on other goroutines while the package
+// variables change.
+func DefaultClient() *Client {
+	scheme := "https"
+	if DisableTLS {
+		scheme = "http"
+	}
+
+	u := url.URL{Scheme: scheme, Host: Server, Path: "/" + strings.TrimPrefix(BasePath, "/")}
+
+	header := make(http.Header)
+	for name, value := range Headers {
+		header.Set(name, value)
+	}
+
+	c := NewClient(Options{
+		BaseURL:     u.String(),
+		Format:      Format,
+		Skinparams:  Skinparams,
+		Command:     strings.Fields(Command),
+		Concurrency: Concurrency,
+		Timeout:     Timeout,
+		Header:      header,
+		DiskCache:   DiskCache,
+	})
+	c.cache, c.limit = defaultCache, sharedLimit(cap(c.limit))
+
+	return c
+}
+
+// Diagram returns the diagram of the encoded UML as HTML, from the cache,
+// the local command or the server. SVG diagrams are inlined and PNG
+// diagrams embedded in an <img> tag.
+func (c *Client) Diagram(ctx context.Context, encodedUML string) (string, error) {
+	// The same diagram differs between formats, servers and commands
+	key := c.keyPrefix + encodedUML
+
+	if cached, ok := c.cache.get(key); ok {
+		return cached, nil
+	}
+
+	if stored, ok := c.opts.DiskCache.Get("plantuml:" + key); ok {
+		c.cache.set(key, string(stored))
+
+		return string(stored), nil
+	}
+
+	select {
+	case c.limit <- struct{}{}:
+		defer func() { <-c.limit }()
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+
+	var (
+		image []byte
+		err   error
+	)
+
+	if len(c.opts.Command) > 0 {
+		image, err = c.run(ctx, encodedUML)
+	} else {
+		image, err = c.fetch(ctx, encodedUML)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	result := diagramHTML(image)
+	c.cache.set(key, result)
+
+	// The diagram is shown even if it cannot be kept on disk
+	_ = c.opts.DiskCache.Set("plantuml:"+key, []byte(result))
+
+	return result, nil
+}
+
+// diagramHTML returns the HTML that shows a rendered diagram. SVG diagrams
+// are sanitized and inlined, so they scale and their text can be selected.
+// PNG diagrams, and SVG diagrams that cannot be parsed, are embedded in an
+// <img> tag.
+func diagramHTML(image []byte) string {
+	if imageType(image) == "image/svg+xml" {
+		if svg, err := sanitizeSVG(image); err == nil {
+			return `<div class="plantuml-diagram">` + svg + `</div>`
+		}
+	}
+
+	var buf bytes.Buffer
+
+	buf.WriteString(`<img src="data:` + imageType(image) + `;base64,`)
+	enc := base64.NewEncoder(base64.StdEncoding, &buf)
+	_, _ = enc.Write(image)
+	_ = enc.Close()
+
+	buf.WriteString(`" alt="plantuml-diagram">`)
+
+	return buf.String()
+}
+
+// fetch requests the image of the encoded UML in the client's format.
+func (c *Client) fetch(ctx context.Context, encodedUML string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	u, err := url.JoinPath(c.opts.BaseURL, c.opts.Format, encodedUML)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, values := range c.opts.Header {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+
+	resp, err := c.client.Do(req) //nolint:gosec // Intentional: PlantUML server URL is user-configurable
+	if err != nil {
+		return nil, fmt.Errorf("failed get diagram: %w", err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		statusErr := &StatusError{StatusCode: resp.StatusCode}
+
+		if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/") {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorMessage))
+			statusErr.Message = strings.TrimSpace(string(body))
+		}
+
+		return nil, statusErr
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	return body, nil
+}
+
+// maxCacheSize is the number of diagrams kept in a cache.
+const maxCacheSize = 20
+
+// diagramCache avoids repeated requests for the same diagram.
+type diagramCache struct {
+	mutex    sync.RWMutex
+	diagrams map[string]string // cache key -> diagram HTML
+}
+
+func (c *diagramCache) get(encodedUML string) (string, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	diagram, ok := c.diagrams[encodedUML]
+
+	return diagram, ok
+}
+
+func (c *diagramCache) set(encodedUML, diagram string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.diagrams == nil {
+		c.diagrams = make(map[string]string)
+	}
+
+	if len(c.diagrams) >= maxCacheSize {
+		// Simple eviction: clear half the cache
+		for k := range c.diagrams {
+			delete(c.diagrams, k)
+
+			if len(c.diagrams) < maxCacheSize/2 {
+				break
+			}
+		}
+	}
+
+	c.diagrams[encodedUML] = diagram
+}
+
+func (c *diagramCache) clear() {
+	c.mutex.Lock()
+	c.diagrams = nil
+	c.mutex.Unlock()
+}
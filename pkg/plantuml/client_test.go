@@ -0,0 +1,306 @@
+package plantuml
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mhersson/mpls/pkg/diskcache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestClient_Diagram(t *testing.T) {
+	t.Parallel()
+
+	var requests atomic.Int32
+
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		assert.Equal(t, "/plantuml/png/"+Encode("@startuml\nA -> B\n@enduml"), r.URL.Path)
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte("png"))
+	})
+
+	c := NewClient(Options{
+		BaseURL: server.URL + "/plantuml",
+		Header:  http.Header{"Authorization": {"Bearer secret"}},
+	})
+
+	diagram, err := c.Diagram(context.Background(), Encode("@startuml\nA -> B\n@enduml"))
+	require.NoError(t, err)
+	assert.Equal(t, `<img src="data:image/png;base64,cG5n" alt="plantuml-diagram">`, diagram)
+
+	_, err = c.Diagram(context.Background(), Encode("@startuml\nA -> B\n@enduml"))
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), requests.Load(), "expected the diagram to be cached")
+}
+
+func TestClient_DiskCache(t *testing.T) {
+	t.Parallel()
+
+	var requests atomic.Int32
+
+	server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte("png"))
+	})
+
+	cache, err := diskcache.New(diskcache.Options{Dir: t.TempDir()})
+	require.NoError(t, err)
+
+	uml := Encode("@startuml\nA -> B\n@enduml")
+
+	diagram, err := NewClient(Options{BaseURL: server.URL, DiskCache: cache}).Diagram(context.Background(), uml)
+	require.NoError(t, err)
+
+	// A new client, as after a restart, starts with an empty memory cache
+	restarted, err := NewClient(Options{BaseURL: server.URL, DiskCache: cache}).Diagram(context.Background(), uml)
+	require.NoError(t, err)
+
+	assert.Equal(t, diagram, restarted)
+	assert.Equal(t, int32(1), requests.Load(), "expected the diagram to be read from disk")
+}
+
+func TestClient_CacheKey(t *testing.T) {
+	t.Parallel()
+
+	imageServer := func(image string) *httptest.Server {
+		return newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write([]byte(image))
+		})
+	}
+	first, second := imageServer("first"), imageServer("second")
+
+	cache, err := diskcache.New(diskcache.Options{Dir: t.TempDir()})
+	require.NoError(t, err)
+
+	uml := Encode("@startuml\nA -> B\n@enduml")
+
+	diagram, err := NewClient(Options{BaseURL: first.URL, DiskCache: cache}).Diagram(context.Background(), uml)
+	require.NoError(t, err)
+	assert.Contains(t, diagram, base64.StdEncoding.EncodeToString([]byte("first")))
+
+	for _, opts := range []Options{
+		{BaseURL: second.URL},
+		{BaseURL: first.URL + "/plantuml"},
+		{BaseURL: first.URL, Command: []string{"plantuml", "-pipe"}},
+	} {
+		a := cacheKeyPrefix(Options{BaseURL: first.URL, Format: FormatPNG})
+		b := cacheKeyPrefix(Options{BaseURL: opts.BaseURL, Command: opts.Command, Format: FormatPNG})
+		assert.NotEqual(t, a, b, "%+v", opts)
+	}
+
+	diagram, err = NewClient(Options{BaseURL: second.URL, DiskCache: cache}).Diagram(context.Background(), uml)
+	require.NoError(t, err)
+	assert.Contains(t, diagram, base64.StdEncoding.EncodeToString([]byte("second")),
+		"diagrams from another server are not taken from the cache")
+}
+
+func TestClient_SVG(t *testing.T) {
+	t.Parallel()
+
+	uml := "@startuml\nA -> B\n@enduml"
+	svg := `<?xml version="1.0" encoding="us-ascii" standalone="no"?>` +
+		`<svg onload="alert(1)"><script>alert(2)</script><g id="elem_A"><text>A</text></g></svg>`
+
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/svg/"+Encode(withSkinparams(uml, "skinparam monochrome reverse")), r.URL.Path)
+
+		w.Header().Set("Content-Type", "image/svg+xml")
+		_, _ = w.Write([]byte(svg))
+	})
+
+	c := NewClient(Options{BaseURL: server.URL, Format: FormatSVG, Skinparams: "skinparam monochrome reverse"})
+
+	result, plantumls, err := c.InsertDiagrams(context.Background(),
+		`<pre><code class="language-plantuml">`+uml+`</code></pre>`, true, nil)
+	require.NoError(t, err)
+	require.Len(t, plantumls, 1)
+
+	// The diagram is inlined without its scripts, and its ids stay apart
+	// from the page
+	assert.Regexp(t, `^<div class="plantuml-diagram"><svg><g id="plantuml-[0-9a-f]{8}-elem_A"><text>A</text></g></svg></div>$`, result)
+}
+
+func TestClient_StatusError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+		message     string
+	}{
+		{"bad request with text", http.StatusBadRequest, "text/plain", "Syntax Error?\n", "Syntax Error?"},
+		{"server error with image", http.StatusInternalServerError, "image/png", "png", ""},
+		{"long message", http.StatusBadGateway, "text/html", strings.Repeat("x", 500), strings.Repeat("x", maxErrorMessage)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			})
+
+			c := NewClient(Options{BaseURL: server.URL})
+
+			_, err := c.Diagram(context.Background(), Encode("@startuml\n"+tt.name+"\n@enduml"))
+
+			var statusErr *StatusError
+			require.ErrorAs(t, err, &statusErr)
+			assert.Equal(t, tt.status, statusErr.StatusCode)
+			assert.Equal(t, tt.message, statusErr.Message)
+		})
+	}
+}
+
+func TestClient_Timeout(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t, func(_ http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+
+	c := NewClient(Options{BaseURL: server.URL, Timeout: 50 * time.Millisecond})
+
+	_, err := c.Diagram(context.Background(), Encode("@startuml\nSlow -> Server\n@enduml"))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestClient_InsertDiagrams(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusBadRequest)
+	})
+
+	c := NewClient(Options{BaseURL: server.URL})
+
+	input := `<pre><code class="language-plantuml">@startuml
+Broken -> Diagram
+@enduml</code></pre>`
+
+	result, _, err := c.InsertDiagrams(context.Background(), input, true, nil)
+	require.Error(t, err)
+
+	assert.Equal(t, input, result, "a failed diagram is not embedded")
+}
+
+func TestClient_InsertDiagramsPartialFailure(t *testing.T) {
+	t.Parallel()
+
+	good := "@startuml\nGood -> Diagram\n@enduml"
+
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if path.Base(r.URL.Path) != Encode(good) {
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte("png"))
+	})
+
+	c := NewClient(Options{BaseURL: server.URL})
+
+	bad := `<pre><code class="language-plantuml">@startuml
+Broken -&gt; Diagram
+@enduml</code></pre>`
+	input := `<pre><code class="language-plantuml">` + good + `</code></pre><p>Text</p>` + bad
+
+	result, plantumls, err := c.InsertDiagrams(context.Background(), input, true, nil)
+
+	var statusErr *StatusError
+	require.ErrorAs(t, err, &statusErr)
+
+	assert.Equal(t, `<img src="data:image/png;base64,cG5n" alt="plantuml-diagram"><p>Text</p>`+bad, result,
+		"the good diagram is embedded and the failed one is kept as code")
+	require.Len(t, plantumls, 2)
+	assert.NotEmpty(t, plantumls[0].Diagram)
+	assert.Empty(t, plantumls[1].Diagram)
+
+	// The failed diagram stays code when the diagrams are reused
+	reused, _, err := c.InsertDiagrams(context.Background(), input, false, plantumls)
+	require.NoError(t, err)
+	assert.Equal(t, result, reused)
+}
+
+func TestClient_InsertDiagramsConcurrently(t *testing.T) {
+	t.Parallel()
+
+	var requests, running, maxRunning atomic.Int32
+
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		n := running.Add(1)
+		defer running.Add(-1)
+
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+
+		time.Sleep(50 * time.Millisecond)
+
+		uml, err := decode(path.Base(r.URL.Path))
+		assert.NoError(t, err)
+
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte(strings.Fields(uml)[1]))
+	})
+
+	c := NewClient(Options{BaseURL: server.URL, Concurrency: 2})
+
+	block := func(name string) string {
+		return `<pre><code class="language-plantuml">@startuml
+` + name + ` -> B
+@enduml</code></pre>`
+	}
+
+	img := func(name string) string {
+		return `<img src="data:image/png;base64,` + base64.StdEncoding.EncodeToString([]byte(name)) + `" alt="plantuml-diagram">`
+	}
+
+	input := "<p>Start</p>" + block("A") + block("B") + block("A") + "<p>End</p>" + block("C")
+
+	result, plantumls, err := c.InsertDiagrams(context.Background(), input, true, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, "<p>Start</p>"+img("A")+img("B")+img("A")+"<p>End</p>"+img("C"), result)
+	assert.Len(t, plantumls, 4)
+	assert.Equal(t, int32(3), requests.Load(), "identical diagrams are requested once")
+	assert.Equal(t, int32(2), maxRunning.Load(), "expected the requests to run concurrently within the limit")
+}
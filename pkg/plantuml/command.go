@@ -0,0 +1,99 @@
+package plantuml
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// waitDelay is how long a command that has been stopped may keep its
+// output open before it is abandoned.
+const waitDelay = time.Second
+
+// CommandError is returned when the local command fails to render a
+// diagram.
+type CommandError struct {
+	Err error
+	// Message is the start of what the command wrote to standard error.
+	Message string
+}
+
+func (e *CommandError) Error() string {
+	msg := "plantuml command failed: " + e.Err.Error()
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+
+	return msg
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// run renders the encoded UML with the local command.
+func (c *Client) run(ctx context.Context, encodedUML string) ([]byte, error) {
+	uml, err := decode(encodedUML)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, c.opts.Command[0], c.opts.Command[1:]...) //nolint:gosec // Intentional: the command is user-configurable
+	cmd.Stdin = strings.NewReader(uml)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = waitDelay
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("plantuml command: %w", ctx.Err())
+		}
+
+		message := stderr.String()
+		if len(message) > maxErrorMessage {
+			message = message[:maxErrorMessage]
+		}
+
+		return nil, &CommandError{Err: err, Message: strings.TrimSpace(message)}
+	}
+
+	if stdout.Len() == 0 {
+		return nil, &CommandError{Err: io.ErrUnexpectedEOF, Message: "no image written"}
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// decode returns the diagram source of encoded UML, the reverse of Encode.
+func decode(encodedUML string) (string, error) {
+	data, err := enc.DecodeString(encodedUML)
+	if err != nil {
+		return "", fmt.Errorf("invalid encoded diagram: %w", err)
+	}
+
+	uml, err := io.ReadAll(flate.NewReader(bytes.NewReader(data)))
+	if err != nil {
+		return "", fmt.Errorf("invalid encoded diagram: %w", err)
+	}
+
+	return string(uml), nil
+}
+
+// imageType returns the media type of a rendered diagram, SVG or PNG.
+func imageType(image []byte) string {
+	if bytes.Contains(image[:min(len(image), 512)], []byte("<svg")) {
+		return "image/svg+xml"
+	}
+
+	return "image/png"
+}
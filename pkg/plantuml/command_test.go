@@ -0,0 +1,109 @@
+package plantuml
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeScript writes a shell script standing in for plantuml and returns
+// its path.
+func writeScript(t *testing.T, body string) string {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("skipping shell script tests on Windows")
+	}
+
+	path := filepath.Join(t.TempDir(), "plantuml")
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0o700)) //nolint:gosec // The script must be executable
+
+	return path
+}
+
+func TestDecode(t *testing.T) {
+	t.Parallel()
+
+	uml := "@startuml\nAlice -> Bob: Hello\n@enduml"
+
+	decoded, err := decode(Encode(uml))
+	require.NoError(t, err)
+	assert.Equal(t, uml, decoded)
+
+	_, err = decode("not encoded!")
+	require.Error(t, err)
+}
+
+func TestClient_Command(t *testing.T) {
+	t.Parallel()
+
+	script := writeScript(t, `printf '<svg>'; cat; printf '</svg>'`)
+	c := NewClient(Options{Command: []string{script, "-pipe"}})
+
+	diagram, err := c.Diagram(context.Background(), Encode("@startuml\nA -> B\n@enduml"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "<div class=\"plantuml-diagram\"><svg>@startuml&#xA;A -&gt; B&#xA;@enduml</svg></div>", diagram)
+}
+
+func TestClient_CommandError(t *testing.T) {
+	t.Parallel()
+
+	script := writeScript(t, `echo "Syntax Error?" >&2; exit 1`)
+	c := NewClient(Options{Command: []string{script}})
+
+	_, err := c.Diagram(context.Background(), Encode("@startuml\nBroken\n@enduml"))
+
+	var cmdErr *CommandError
+	require.ErrorAs(t, err, &cmdErr)
+	assert.Equal(t, "Syntax Error?", cmdErr.Message)
+}
+
+func TestClient_CommandTimeout(t *testing.T) {
+	t.Parallel()
+
+	script := writeScript(t, `sleep 5`)
+	c := NewClient(Options{Command: []string{script}, Timeout: 100 * time.Millisecond})
+
+	start := time.Now()
+
+	_, err := c.Diagram(context.Background(), Encode("@startuml\nSlow\n@enduml"))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 3*time.Second)
+}
+
+func TestClient_CommandConcurrency(t *testing.T) {
+	t.Parallel()
+
+	// The script fails if another copy of it is running
+	lock := filepath.Join(t.TempDir(), "lock")
+	script := writeScript(t, `mkdir "`+lock+`" || exit 1; sleep 0.1; rmdir "`+lock+`"; echo '<svg/>'`)
+	c := NewClient(Options{Command: []string{script}, Concurrency: 1})
+
+	var wg sync.WaitGroup
+
+	errs := make([]error, 3)
+
+	for i := range errs {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, errs[i] = c.Diagram(context.Background(), Encode("@startuml\nDiagram "+string(rune('A'+i))+"\n@enduml"))
+		}()
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+}
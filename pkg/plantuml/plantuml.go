@@ -5,39 +5,50 @@ import (
 	"compress/flate"
 	"context"
 	"encoding/base64"
-	"fmt"
+	"errors"
 	htmlpkg "html"
-	"io"
-	"net/http"
-	"net/url"
 	"regexp"
 	"slices"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/mhersson/mpls/pkg/diskcache"
 	"golang.org/x/net/html"
 )
 
 const plantumlMap = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz-_"
 
+// The server used by the package level functions.
 var (
 	Server     string
 	BasePath   string
 	DisableTLS bool
+	// Timeout limits each diagram request. DefaultTimeout is used if zero.
+	Timeout time.Duration
+	// Headers are added to every diagram request, e.g. for authentication.
+	Headers map[string]string
+	// Command renders diagrams locally instead of on the server, e.g.
+	// "plantuml -pipe". Arguments are separated by spaces.
+	Command string
+	// Concurrency is the maximum number of diagrams requested or rendered
+	// at once. DefaultConcurrency is used if zero.
+	Concurrency int
+	// Format is the image format requested from the server, FormatPNG or
+	// FormatSVG.
+	Format string
+	// Skinparams are added to every diagram, e.g. to match the preview
+	// theme.
+	Skinparams string
+	// DiskCache keeps diagrams between sessions. Diagrams are only kept in
+	// memory if nil.
+	DiskCache *diskcache.Cache
 )
 
 var plantumlMarkerRegex = regexp.MustCompile(`@start\w+`)
 
 var enc *base64.Encoding
 
-// Diagram cache for avoiding repeated HTTP requests.
-var (
-	diagramCache      = make(map[string]string) // encodedUML -> diagram HTML
-	diagramCacheMutex sync.RWMutex
-	maxCacheSize      = 20 // Max cached diagrams
-)
-
 func init() {
 	enc = base64.NewEncoding(plantumlMap)
 }
@@ -57,112 +68,52 @@ func encode(text string) string {
 	return enc.EncodeToString(b.Bytes())
 }
 
-func call(payload string) ([]byte, error) {
-	path, err := url.JoinPath(BasePath, "png", payload)
-	if err != nil {
-		return nil, err
-	}
-
-	scheme := "https"
-	if DisableTLS {
-		scheme = "http"
-	}
-
-	u := url.URL{Host: Server, Scheme: scheme, Path: path}
-
-	timeout := 10 * time.Second
-
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-
-	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
-
-	resp, err := http.DefaultClient.Do(req) //nolint:gosec // Intentional: PlantUML server URL is user-configurable
-	if err != nil {
-		return nil, fmt.Errorf("failed get diagram: %w", err)
-	}
-
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
-	}
-
-	return body, nil
-}
-
-func getDiagram(encodedUML string) (string, error) {
-	// Check cache first
-	diagramCacheMutex.RLock()
-
-	if cached, ok := diagramCache[encodedUML]; ok {
-		diagramCacheMutex.RUnlock()
-
-		return cached, nil
-	}
-
-	diagramCacheMutex.RUnlock()
-
-	// Cache miss - make HTTP request
-	svg, err := call(encodedUML)
-	if err != nil {
-		return "", err
-	}
-
-	var buf bytes.Buffer
-
-	buf.Write([]byte(`<img src="data:image/png;base64,`))
-	enc := base64.NewEncoder(base64.StdEncoding, &buf)
-	_, _ = enc.Write(svg)
-	_ = enc.Close()
-
-	buf.Write([]byte(`" alt="plantuml-diagram">`))
-
-	result := buf.String()
-
-	// Store in cache
-	diagramCacheMutex.Lock()
-	if len(diagramCache) >= maxCacheSize {
-		// Simple eviction: clear half the cache
-		for k := range diagramCache {
-			delete(diagramCache, k)
-
-			if len(diagramCache) < maxCacheSize/2 {
-				break
-			}
-		}
-	}
-
-	diagramCache[encodedUML] = result
-	diagramCacheMutex.Unlock()
-
-	return result, nil
-}
-
 func Encode(uml string) string {
 	return encode(uml)
 }
 
+// GetDiagram requests the diagram from the server in the package settings
+// and returns it as an <img> tag.
 func GetDiagram(encodedUML string) (string, error) {
-	return getDiagram(encodedUML)
+	return DefaultClient().Diagram(context.Background(), encodedUML)
 }
 
 // ClearDiagramCache clears the diagram cache. Useful for testing.
 func ClearDiagramCache() {
-	diagramCacheMutex.Lock()
-	diagramCache = make(map[string]string)
-	diagramCacheMutex.Unlock()
+	defaultCache.clear()
 }
 
 // InsertPlantumlDiagram processes HTML to replace PlantUML code blocks with rendered diagrams.
 // Uses HTML tokenizer for proper parsing, handling nested tags and various attribute formats.
 func InsertPlantumlDiagram(data string, generate bool, plantumls []Plantuml) (string, []Plantuml, error) {
+	return InsertPlantumlDiagramContext(context.Background(), data, generate, plantumls)
+}
+
+// InsertPlantumlDiagramContext is InsertPlantumlDiagram with a context that
+// cancels the requests for new diagrams.
+func InsertPlantumlDiagramContext(ctx context.Context, data string, generate bool, plantumls []Plantuml) (string, []Plantuml, error) {
+	return DefaultClient().InsertDiagrams(ctx, data, generate, plantumls)
+}
+
+// InsertDiagrams replaces the PlantUML code blocks in the HTML data with
+// diagrams, taken from plantumls or, when generate is set, requested from
+// the server. Missing diagrams are requested concurrently, each distinct
+// diagram once, after the whole document has been read. It returns the
+// diagrams of the document in order. Blocks whose diagram could not be
+// requested are kept as code, and the errors are returned joined.
+func (c *Client) InsertDiagrams(ctx context.Context, data string, generate bool, plantumls []Plantuml) (string, []Plantuml, error) {
 	tokenizer := html.NewTokenizer(strings.NewReader(data))
 
 	var result strings.Builder
 
-	var err error
+	// When generating, the HTML is split where diagrams go, and the
+	// diagrams are inserted once they have all been requested
+	var (
+		chunks   []string
+		slots    []int    // the diagram that follows each chunk
+		blocks   []string // the code block each diagram replaces
+		diagrams []Plantuml
+	)
 
 	numDiagrams := 0
 
@@ -192,7 +143,29 @@ func InsertPlantumlDiagram(data string, generate bool, plantumls []Plantuml) (st
 				result.WriteString(preContent.String())
 			}
 
-			return result.String(), plantumls, err
+			if !generate {
+				return result.String(), plantumls, nil
+			}
+
+			chunks = append(chunks, result.String())
+
+			err := c.requestDiagrams(ctx, diagrams)
+
+			var output strings.Builder
+
+			for i, slot := range slots {
+				output.WriteString(chunks[i])
+
+				if diagrams[slot].Diagram != "" {
+					output.WriteString(diagrams[slot].Diagram)
+				} else {
+					output.WriteString(blocks[i])
+				}
+			}
+
+			output.WriteString(chunks[len(slots)])
+
+			return output.String(), diagrams, err
 
 		case html.StartTagToken:
 			token := tokenizer.Token()
@@ -243,36 +216,25 @@ func InsertPlantumlDiagram(data string, generate bool, plantumls []Plantuml) (st
 					continue
 				}
 
-				p := Plantuml{}
-				p.EncodedUML = Encode(uml)
+				numDiagrams++
 
-				generated := false
+				// Kept in case the diagram is missing
+				preContent.WriteString(htmlEncodedUml)
+				preContent.WriteString(token.String())
 
-				for _, enc := range plantumls {
-					if p.EncodedUML == enc.EncodedUML {
-						p.Diagram = enc.Diagram
-						generated = true
+				if generate {
+					p := Plantuml{}
+					p.EncodedUML = Encode(withSkinparams(uml, c.opts.Skinparams))
 
-						break
-					}
-				}
+					for _, enc := range plantumls {
+						if p.EncodedUML == enc.EncodedUML {
+							p.Diagram = enc.Diagram
 
-				if !generated && generate {
-					p.Diagram, err = GetDiagram(p.EncodedUML)
-					if err != nil {
-						return data, plantumls, err
+							break
+						}
 					}
-				}
-
-				numDiagrams++
 
-				if generate {
-					if len(plantumls) < numDiagrams {
-						plantumls = append(plantumls, p)
-					} else {
-						plantumls[numDiagrams-1] = p
-					}
-					// Don't add to preContent - we'll output the diagram when </pre> is reached
+					diagrams = append(diagrams, p)
 				}
 
 				continue
@@ -283,10 +245,19 @@ func InsertPlantumlDiagram(data string, generate bool, plantumls []Plantuml) (st
 
 				if currentPreHasPlantuml {
 					// We had PlantUML in this pre block - output diagram instead
-					if generate {
-						result.WriteString(plantumls[numDiagrams-1].Diagram)
-					} else if len(plantumls) >= numDiagrams {
+					preContent.WriteString(token.String())
+
+					switch {
+					case generate:
+						chunks = append(chunks, result.String())
+						slots = append(slots, numDiagrams-1)
+						blocks = append(blocks, preContent.String())
+
+						result.Reset()
+					case len(plantumls) >= numDiagrams && plantumls[numDiagrams-1].Diagram != "":
 						result.WriteString(plantumls[numDiagrams-1].Diagram)
+					default:
+						result.WriteString(preContent.String())
 					}
 				} else {
 					// Regular pre block - output as-is
@@ -336,6 +307,89 @@ func InsertPlantumlDiagram(data string, generate bool, plantumls []Plantuml) (st
 	}
 }
 
+// requestDiagrams requests the diagrams that are missing, each distinct
+// diagram once, at most c.opts.Concurrency at a time. Diagrams that fail are
+// left empty, and their errors are returned joined, in document order.
+func (c *Client) requestDiagrams(ctx context.Context, diagrams []Plantuml) error {
+	type request struct {
+		diagram  string
+		err      error
+		reported bool
+	}
+
+	requests := make(map[string]*request)
+
+	var wg sync.WaitGroup
+
+	for _, p := range diagrams {
+		if p.Diagram != "" {
+			continue
+		}
+
+		if _, ok := requests[p.EncodedUML]; ok {
+			continue
+		}
+
+		r := &request{}
+		requests[p.EncodedUML] = r
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			r.diagram, r.err = c.Diagram(ctx, p.EncodedUML)
+		}()
+	}
+
+	wg.Wait()
+
+	var errs []error
+
+	for i, p := range diagrams {
+		r, ok := requests[p.EncodedUML]
+		if !ok {
+			continue
+		}
+
+		if r.err != nil {
+			// Repeated diagrams report their error once
+			if !r.reported {
+				errs = append(errs, r.err)
+				r.reported = true
+			}
+
+			continue
+		}
+
+		diagrams[i].Diagram = r.diagram
+	}
+
+	return errors.Join(errs...)
+}
+
+// withSkinparams adds skinparams to the diagram source, after its @start
+// line, so that the diagram's own skinparams take precedence.
+func withSkinparams(uml, skinparams string) string {
+	if skinparams == "" {
+		return uml
+	}
+
+	loc := plantumlMarkerRegex.FindStringIndex(uml)
+	if loc == nil {
+		return uml
+	}
+
+	end := strings.IndexByte(uml[loc[1]:], '\n')
+	if end == -1 {
+		return uml + "\n" + skinparams
+	}
+
+	end += loc[1]
+
+	return uml[:end+1] + skinparams + "\n" + uml[end+1:]
+}
+
 // hasLanguagePlantuml checks if the attributes contain class="language-plantuml".
 func hasLanguagePlantuml(attrs []html.Attribute) bool {
 	for _, attr := range attrs {
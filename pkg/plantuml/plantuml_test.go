@@ -1,6 +1,7 @@
 package plantuml
 
 import (
+	"context"
 	"slices"
 	"strings"
 	"testing"
@@ -35,6 +36,22 @@ func TestInsertPlantumlDiagram_NoStartMarker(t *testing.T) {
 	assert.Empty(t, plantumls)
 }
 
+func TestInsertPlantumlDiagramContext_Canceled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	input := `<pre><code class="language-plantuml">@startuml
+Canceled -> Request
+@enduml</code></pre>`
+
+	result, _, err := InsertPlantumlDiagramContext(ctx, input, true, nil)
+	require.ErrorIs(t, err, context.Canceled)
+
+	assert.Equal(t, input, result)
+}
+
 func TestInsertPlantumlDiagram_MultipleClasses(t *testing.T) {
 	t.Parallel()
 
@@ -238,6 +255,32 @@ func TestEncode(t *testing.T) {
 	assert.NotEmpty(t, encoded1, "encoded string should not be empty")
 }
 
+func TestWithSkinparams(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		uml        string
+		skinparams string
+		expected   string
+	}{
+		{"no skinparams", "@startuml\nA -> B\n@enduml", "", "@startuml\nA -> B\n@enduml"},
+		{"after start line", "@startuml\nA -> B\n@enduml", "skinparam monochrome reverse",
+			"@startuml\nskinparam monochrome reverse\nA -> B\n@enduml"},
+		{"start line with name", "@startmindmap Ideas\n* Root\n@endmindmap", "skinparam backgroundColor transparent",
+			"@startmindmap Ideas\nskinparam backgroundColor transparent\n* Root\n@endmindmap"},
+		{"no start marker", "A -> B", "skinparam monochrome reverse", "A -> B"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.expected, withSkinparams(tt.uml, tt.skinparams))
+		})
+	}
+}
+
 func TestHasLanguagePlantuml_MultipleAttrs(t *testing.T) {
 	t.Parallel()
 
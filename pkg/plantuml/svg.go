@@ -0,0 +1,157 @@
+package plantuml
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Elements that are left out of inlined diagrams, along with their content.
+var unsafeSVGElements = []string{"script", "foreignObject", "iframe", "embed", "object"}
+
+// sanitizeSVG returns an SVG diagram for inlining in the preview. Scripts,
+// event handler attributes and javascript: URLs are removed, and element ids
+// are prefixed with a hash of the diagram, so that they do not clash with
+// the page or other diagrams. The XML declaration, doctype and comments are
+// left out.
+func sanitizeSVG(image []byte) (string, error) {
+	sum := sha256.Sum256(image)
+	prefix := "plantuml-" + hex.EncodeToString(sum[:4]) + "-"
+
+	decoder := xml.NewDecoder(bytes.NewReader(image))
+	decoder.Strict = false
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		// PlantUML writes ASCII, with other characters as references
+		if strings.EqualFold(charset, "us-ascii") {
+			return input, nil
+		}
+
+		return nil, fmt.Errorf("unsupported charset %q", charset)
+	}
+
+	var out strings.Builder
+
+	skipped := 0 // depth inside an unsafe element
+	started := false
+
+	for {
+		token, err := decoder.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return "", err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if skipped > 0 || isUnsafeElement(t.Name) {
+				skipped++
+
+				continue
+			}
+
+			started = true
+
+			out.WriteString("<" + qualifiedName(t.Name))
+
+			for _, attr := range t.Attr {
+				value, ok := sanitizeAttr(attr, prefix)
+				if !ok {
+					continue
+				}
+
+				out.WriteString(" " + qualifiedName(attr.Name) + `="`)
+				_ = xml.EscapeText(&out, []byte(value))
+				out.WriteString(`"`)
+			}
+
+			out.WriteString(">")
+		case xml.EndElement:
+			if skipped > 0 {
+				skipped--
+
+				continue
+			}
+
+			out.WriteString("</" + qualifiedName(t.Name) + ">")
+		case xml.CharData:
+			if skipped > 0 || !started {
+				continue
+			}
+
+			_ = xml.EscapeText(&out, []byte(prefixReferences(string(t), prefix)))
+		}
+	}
+
+	if !started {
+		return "", errors.New("no svg element")
+	}
+
+	return out.String(), nil
+}
+
+func isUnsafeElement(name xml.Name) bool {
+	for _, unsafe := range unsafeSVGElements {
+		if strings.EqualFold(name.Local, unsafe) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sanitizeAttr returns the value of attr in an inlined diagram, or false if
+// the attribute is left out.
+func sanitizeAttr(attr xml.Attr, prefix string) (string, bool) {
+	name := strings.ToLower(attr.Name.Local)
+
+	if strings.HasPrefix(name, "on") {
+		return "", false
+	}
+
+	// Browsers ignore whitespace and control characters in the scheme
+	scheme := strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+
+		return r
+	}, attr.Value)
+	if strings.HasPrefix(strings.ToLower(scheme), "javascript:") {
+		return "", false
+	}
+
+	switch {
+	case name == "id" && attr.Name.Space == "":
+		return prefix + attr.Value, true
+	case name == "href" && strings.HasPrefix(attr.Value, "#"):
+		return "#" + prefix + attr.Value[1:], true
+	default:
+		return prefixReferences(attr.Value, prefix), true
+	}
+}
+
+// prefixReferences prefixes the ids in url(#id) references, as used in fill,
+// filter, marker and style values.
+func prefixReferences(value, prefix string) string {
+	return strings.NewReplacer(
+		"url(#", "url(#"+prefix,
+		"url('#", "url('#"+prefix,
+		`url("#`, `url("#`+prefix,
+	).Replace(value)
+}
+
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+
+	return name.Space + ":" + name.Local
+}
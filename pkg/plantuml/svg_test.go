@@ -0,0 +1,40 @@
+package plantuml
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitizeSVG(t *testing.T) {
+	t.Parallel()
+
+	svg := `<?xml version="1.0"?><!DOCTYPE svg><!-- comment -->` +
+		`<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" viewBox="0 0 10 10">` +
+		`<defs><linearGradient id="g"/></defs>` +
+		`<rect fill="url(#g)" onclick="alert(1)" OnMouseOver="alert(2)"/>` +
+		`<a xlink:href=" java&#x09;script:alert(3)" href="#g"><text>A &amp; B</text></a>` +
+		`<set attributeName="href" to="javascript:alert(4)"/>` +
+		`<foreignObject><div>html</div></foreignObject>` +
+		`<SCRIPT>alert(5)</SCRIPT>` +
+		`</svg>`
+
+	result, err := sanitizeSVG([]byte(svg))
+	require.NoError(t, err)
+
+	sum := sha256.Sum256([]byte(svg))
+	prefix := "plantuml-" + hex.EncodeToString(sum[:4]) + "-"
+
+	assert.Equal(t, `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" viewBox="0 0 10 10">`+
+		`<defs><linearGradient id="`+prefix+`g"></linearGradient></defs>`+
+		`<rect fill="url(#`+prefix+`g)"></rect>`+
+		`<a href="#`+prefix+`g"><text>A &amp; B</text></a>`+
+		`<set attributeName="href"></set>`+
+		`</svg>`, result)
+
+	_, err = sanitizeSVG([]byte("not a diagram"))
+	assert.Error(t, err)
+}